@@ -0,0 +1,188 @@
+package applications
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/bwmarrin/discordgo"
+)
+
+const appsRouteName = "apps"
+
+func RegisterAppsApp(bot *framework.Bot) framework.Route {
+	return framework.NewRoute(bot, appsRouteName,
+		// apps
+		&AppsCommand{}, // [NOP]
+
+		// apps <subcommand>
+		framework.NewRoute(bot, "list", &AppsListSubCommand{bot: bot}),
+		framework.NewRoute(bot, "enable", &AppsToggleSubCommand{bot: bot, enabled: true}),
+		framework.NewRoute(bot, "disable", &AppsToggleSubCommand{bot: bot, enabled: false}),
+
+		// Only server admins can turn apps on and off
+		framework.RequirePermissions(discordgo.PermissionAdministrator),
+		framework.RequireGuild(),
+	)
+}
+
+// AppsCommand lets server admins choose which of Tony's apps are enabled in
+// their server.
+//
+//	/apps list
+//	/apps enable app:<name>
+//	/apps disable app:<name>
+type AppsCommand struct {
+	framework.ApplicationCommand
+}
+
+func (c AppsCommand) GetType() framework.AppType {
+	return framework.AppTypeCommand
+}
+
+// GetDefinition describes the apps command, the subcommand options are
+// generated from the subroutes.
+func (c AppsCommand) GetDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        appsRouteName,
+		Description: "Manage the apps enabled in this server",
+	}
+}
+
+func (c AppsCommand) OnCommand(ctx framework.CommandContext) {
+	// This is a NOP command and should not be executed directly
+}
+
+type AppsListSubCommand struct {
+	framework.ApplicationSubCommand
+	bot *framework.Bot
+}
+
+func (c AppsListSubCommand) GetType() framework.AppType {
+	return framework.AppTypeSubCommand
+}
+
+func (c AppsListSubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "list",
+		Description: "List the apps and whether they are enabled",
+	}
+}
+
+func (c AppsListSubCommand) OnCommand(ctx framework.CommandContext) {
+	list := "Apps:\n\n```\n"
+	for _, route := range c.bot.Routes {
+		status := "enabled"
+		if !c.bot.AppEnabled(ctx.GuildID(), route.Name) {
+			status = "disabled"
+		}
+		list += fmt.Sprintf("%-24s %s\n", route.Name, status)
+	}
+	list += "```"
+
+	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: list,
+		},
+	})
+}
+
+// AppsToggleSubCommand enables or disables an app in the server and then
+// syncs the server's commands so disabled commands disappear.
+type AppsToggleSubCommand struct {
+	framework.ApplicationSubCommand
+	bot     *framework.Bot
+	enabled bool
+}
+
+func (c AppsToggleSubCommand) GetType() framework.AppType {
+	return framework.AppTypeSubCommand | framework.AppTypeAutocomplete
+}
+
+// AppsToggleArgs are the options of "/apps enable" and "/apps disable"
+type AppsToggleArgs struct {
+	App string `option:"app,required,autocomplete" description:"The name of the app"`
+}
+
+func (c AppsToggleSubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	if c.enabled {
+		return &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "enable",
+			Description: "Enable an app in this server",
+			Options:     framework.CommandOptions(AppsToggleArgs{}),
+		}
+	}
+
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "disable",
+		Description: "Disable an app in this server",
+		Options:     framework.CommandOptions(AppsToggleArgs{}),
+	}
+}
+
+func (c AppsToggleSubCommand) OnAutocomplete(ctx framework.AutocompleteContext) {
+	typed := ""
+	if focused := ctx.FocusedOption(); focused != nil {
+		typed = strings.ToLower(fmt.Sprintf("%v", focused.Value))
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	for _, route := range c.bot.Routes {
+		if route.Name == appsRouteName || !strings.Contains(strings.ToLower(route.Name), typed) {
+			continue
+		}
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  route.Name,
+			Value: route.Name,
+		})
+	}
+
+	if err := ctx.RespondChoices(choices...); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to respond with app choices")
+	}
+}
+
+func (c AppsToggleSubCommand) OnCommand(ctx framework.CommandContext) {
+	var args AppsToggleArgs
+	if err := ctx.Bind(&args); err != nil {
+		ctx.Fail(err)
+		return
+	}
+	app := args.App
+
+	// Disabling this command would stop it being turned back on
+	if app == appsRouteName {
+		ctx.Fail(fmt.Errorf("the %s app can not be disabled", appsRouteName))
+		return
+	}
+
+	if err := c.bot.SetAppEnabled(ctx.GuildID(), app, c.enabled); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to set app enabled")
+		ctx.Fail(fmt.Errorf("failed to update `%s`: %s", app, err))
+		return
+	}
+
+	status := "disabled"
+	if c.enabled {
+		status = "enabled"
+	}
+
+	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: fmt.Sprintf("`%s` is now %s in this server", app, status),
+		},
+	})
+
+	// Add or remove the app's commands in this server
+	if _, err := c.bot.SyncGuildCommands(ctx.GuildID(), false); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to sync commands")
+	}
+}
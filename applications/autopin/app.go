@@ -1,9 +1,26 @@
 package autopin
 
-import "github.com/aussiebroadwan/tony/framework"
+import (
+	"sync/atomic"
 
-const autopinThreshold = 5
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/bwmarrin/discordgo"
+)
+
+// autopinThreshold is how many 📌 reactions pin a message, it is set from
+// the config by OnConfig
+var autopinThreshold atomic.Int64
+
+func init() {
+	autopinThreshold.Store(5)
+}
 
 func RegisterAutopinApp(bot *framework.Bot) framework.Route {
 	return framework.NewRoute(bot, "autopin", &AutopinApp{})
 }
+
+func RegisterAutopinMessageApp(bot *framework.Bot) framework.Route {
+	return framework.NewRoute(bot, pinMessageCommandName, &PinMessageCommand{},
+		framework.RequirePermissions(discordgo.PermissionManageMessages),
+	)
+}
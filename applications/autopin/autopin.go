@@ -1,19 +1,34 @@
 package autopin
 
-import "github.com/aussiebroadwan/tony/framework"
+import (
+	"github.com/aussiebroadwan/tony/config"
+	"github.com/aussiebroadwan/tony/framework"
+)
 
 // AutoPinRule is a rule that automatically pins messages that are reacted to
-// with a pin emoji 📌 at least x times.
+// with a pin emoji 📌 at least x times. The count is reset when the message is
+// deleted or all of its reactions are removed.
 type AutopinApp struct {
 	framework.ApplicationReaction
+	framework.ApplicationMessageDelete
+	framework.ApplicationReactionRemoveAll
+	framework.ApplicationConfig
 }
 
 func (a AutopinApp) GetType() framework.AppType {
-	return framework.AppTypeReaction | framework.AppTypeMountable
+	return framework.AppTypeReaction | framework.AppTypeMessageDelete | framework.AppTypeReactionRemoveAll | framework.AppTypeConfig
 }
 
-func (a AutopinApp) OnMount(ctx framework.MountContext) {
-	SetupAutopinDB(ctx.Database())
+// OnConfig sets how many reactions pin a message, messages already pinned
+// are checked again on their next reaction
+func (a AutopinApp) OnConfig(ctx framework.ConfigContext) {
+	var cfg config.Config
+	if err := ctx.Config(&cfg); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to read config")
+		return
+	}
+
+	autopinThreshold.Store(int64(cfg.Autopin.Threshold))
 }
 
 func (a AutopinApp) OnReaction(ctx framework.ReactionContext) {
@@ -26,25 +41,36 @@ func (a AutopinApp) OnReaction(ctx framework.ReactionContext) {
 
 	// Increment or decrement the count
 	if add {
-		IncrementAutopin(db, reaction.ChannelID, reaction.MessageID)
+		IncrementAutopin(db, ctx.GuildID(), reaction.ChannelID, reaction.MessageID)
 	} else {
-		DecrementAutopin(db, reaction.ChannelID, reaction.MessageID)
+		DecrementAutopin(db, ctx.GuildID(), reaction.ChannelID, reaction.MessageID)
 	}
 
 	// Get the count
-	count, pinned, _ := GetAutopin(db, reaction.ChannelID, reaction.MessageID)
+	count, pinned, _ := GetAutopin(db, ctx.GuildID(), reaction.ChannelID, reaction.MessageID)
 
 	// Check if the message should be pinned
-	if count >= autopinThreshold && pinned == nil {
+	threshold := int(autopinThreshold.Load())
+	if count >= threshold && pinned == nil {
 		// Pin the message
 		if err := ctx.Session().ChannelMessagePin(reaction.ChannelID, reaction.MessageID); err == nil {
-			SetAutopinPinned(db, reaction.ChannelID, reaction.MessageID, true)
+			SetAutopinPinned(db, ctx.GuildID(), reaction.ChannelID, reaction.MessageID, true)
 		}
-	} else if count < autopinThreshold && pinned != nil {
+	} else if count < threshold && pinned != nil {
 		// Unpin the message
 		if err := ctx.Session().ChannelMessageUnpin(reaction.ChannelID, reaction.MessageID); err != nil {
-			SetAutopinPinned(db, reaction.ChannelID, reaction.MessageID, false)
+			SetAutopinPinned(db, ctx.GuildID(), reaction.ChannelID, reaction.MessageID, false)
 		}
 	}
 
 }
+
+func (a AutopinApp) OnMessageDelete(ctx framework.MessageContext) {
+	message := ctx.Message()
+	DeleteAutopin(ctx.Database(), ctx.GuildID(), message.ChannelID, message.ID)
+}
+
+func (a AutopinApp) OnReactionRemoveAll(ctx framework.ReactionContext) {
+	reaction, _ := ctx.Reaction()
+	ClearAutopinReacts(ctx.Database(), ctx.GuildID(), reaction.ChannelID, reaction.MessageID)
+}
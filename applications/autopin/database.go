@@ -7,22 +7,13 @@ import (
 	"gorm.io/gorm"
 )
 
-// SetupAutopinDB initializes the database with the Autopin model. It
-// automatically migrates the database schema to match the model, ensuring the
-// table is created or updated as needed.
-func SetupAutopinDB(db *gorm.DB) {
-	if err := db.AutoMigrate(&Autopin{}); err != nil {
-		log.WithField("src", "database.SetupAutoPinDB").WithError(err).Fatal("Failed to auto-migrate autopin table")
-	}
-}
-
-// GetAutopin retrieves the autopin record for a specific channel and message
-// ID. It returns the number of reactions, the pinned timestamp (if any), and
+// GetAutopin retrieves the autopin record for a specific server, channel and
+// message ID. It returns the number of reactions, the pinned timestamp (if any), and
 // any error encountered. If the autopin is not found, gorm.ErrRecordNotFound
 // is returned as the error.
-func GetAutopin(db *gorm.DB, channelId, messageId string) (int, *time.Time, error) {
+func GetAutopin(db *gorm.DB, guildId, channelId, messageId string) (int, *time.Time, error) {
 	var autopin Autopin
-	result := db.Where("channel_id = ? AND message_id = ?", channelId, messageId).Limit(1).Find(&autopin)
+	result := db.Where("guild_id = ? AND channel_id = ? AND message_id = ?", guildId, channelId, messageId).Limit(1).Find(&autopin)
 	if result.Error != nil {
 		return 0, nil, result.Error
 	}
@@ -32,9 +23,9 @@ func GetAutopin(db *gorm.DB, channelId, messageId string) (int, *time.Time, erro
 // IncrementAutopin increases the reaction count for a given channel and message
 // ID. If the autopin record does not exist, it creates a new one with a single
 // reaction. It logs and returns any error encountered during the operation.
-func IncrementAutopin(db *gorm.DB, channelId, messageId string) error {
+func IncrementAutopin(db *gorm.DB, guildId, channelId, messageId string) error {
 	var autopin Autopin
-	result := db.Where("channel_id = ? AND message_id = ?", channelId, messageId).FirstOrCreate(&autopin, Autopin{ChannelID: channelId, MessageID: messageId})
+	result := db.Where("guild_id = ? AND channel_id = ? AND message_id = ?", guildId, channelId, messageId).FirstOrCreate(&autopin, Autopin{GuildID: guildId, ChannelID: channelId, MessageID: messageId})
 
 	if result.Error != nil {
 		log.WithField("src", "database.IncrementAutoPin").WithError(result.Error).Error("Failed to find or create autopin")
@@ -49,9 +40,9 @@ func IncrementAutopin(db *gorm.DB, channelId, messageId string) error {
 // ID. If the resulting reaction count is zero or less, the autopin record is
 // deleted. It returns any error encountered during the find, update, or delete
 // operations.
-func DecrementAutopin(db *gorm.DB, channelId, messageId string) error {
+func DecrementAutopin(db *gorm.DB, guildId, channelId, messageId string) error {
 	var autopin Autopin
-	result := db.Where("channel_id = ? AND message_id = ?", channelId, messageId).Limit(1).Find(&autopin)
+	result := db.Where("guild_id = ? AND channel_id = ? AND message_id = ?", guildId, channelId, messageId).Limit(1).Find(&autopin)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -64,14 +55,40 @@ func DecrementAutopin(db *gorm.DB, channelId, messageId string) error {
 	}
 }
 
+// DeleteAutopin removes the autopin record for a given channel and message ID,
+// used when the message is deleted. It returns any error encountered during
+// the delete operation.
+func DeleteAutopin(db *gorm.DB, guildId, channelId, messageId string) error {
+	return db.Where("guild_id = ? AND channel_id = ? AND message_id = ?", guildId, channelId, messageId).Delete(&Autopin{}).Error
+}
+
+// ClearAutopinReacts resets the reaction count for a given channel and message
+// ID when all of its reactions are removed. The record is deleted unless the
+// message is pinned, which it stays. It returns any error encountered during
+// the find, update, or delete operations.
+func ClearAutopinReacts(db *gorm.DB, guildId, channelId, messageId string) error {
+	var autopin Autopin
+	result := db.Where("guild_id = ? AND channel_id = ? AND message_id = ?", guildId, channelId, messageId).Limit(1).Find(&autopin)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	if autopin.Pinned == nil {
+		return db.Delete(&autopin).Error
+	}
+
+	autopin.Reacts = 0
+	return db.Save(&autopin).Error
+}
+
 // SetAutopinPinned updates the pinned status of an autopin record for a given
 // channel and message ID. If 'pinned' is true, it sets the current timestamp
 // as the pinned time. If 'pinned' is false, it clears the pinned timestamp,
 // effectively marking it as unpinned. It logs and returns any error
 // encountered during the update operation.
-func SetAutopinPinned(db *gorm.DB, channelId, messageId string, pinned bool) error {
+func SetAutopinPinned(db *gorm.DB, guildId, channelId, messageId string, pinned bool) error {
 	var autopin Autopin
-	result := db.Where("channel_id = ? AND message_id = ?", channelId, messageId).Limit(1).Find(&autopin)
+	result := db.Where("guild_id = ? AND channel_id = ? AND message_id = ?", guildId, channelId, messageId).Limit(1).Find(&autopin)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -85,3 +102,18 @@ func SetAutopinPinned(db *gorm.DB, channelId, messageId string, pinned bool) err
 
 	return db.Save(&autopin).Error
 }
+
+// PinAutopin marks a message as pinned, creating the autopin record if the
+// message has no pin reactions yet. It returns any error encountered during
+// the find, create or update operations.
+func PinAutopin(db *gorm.DB, guildId, channelId, messageId string) error {
+	var autopin Autopin
+	result := db.Where("guild_id = ? AND channel_id = ? AND message_id = ?", guildId, channelId, messageId).FirstOrCreate(&autopin, Autopin{GuildID: guildId, ChannelID: channelId, MessageID: messageId})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	now := time.Now()
+	autopin.Pinned = &now
+	return db.Save(&autopin).Error
+}
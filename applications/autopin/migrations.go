@@ -0,0 +1,61 @@
+package autopin
+
+import (
+	"time"
+
+	"github.com/aussiebroadwan/tony/database/migrate"
+	"gorm.io/gorm"
+)
+
+// autopinV1 is the autopins table as it was first created by migrations,
+// later changes are made by the migrations after
+type autopinV1 struct {
+	gorm.Model
+
+	GuildID   string
+	ChannelID string
+	MessageID string
+	Reacts    int
+	Pinned    *time.Time
+}
+
+func (autopinV1) TableName() string { return "autopins" }
+
+// Migrations are the changes to the autopins table, in order
+var Migrations = []migrate.Migration{
+	{
+		Package: "autopin",
+		Version: 1,
+		Name:    "create autopins table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&autopinV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&autopinV1{})
+		},
+	},
+	{
+		Package: "autopin",
+		Version: 2,
+		Name:    "index autopins by message",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("CREATE INDEX IF NOT EXISTS idx_autopins_message ON autopins (guild_id, channel_id, message_id)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP INDEX IF EXISTS idx_autopins_message").Error
+		},
+	},
+	{
+		// Autopins from before they were kept per server have no server ID
+		Package: "autopin",
+		Version: 3,
+		Name:    "give legacy autopins a server",
+		Up: func(tx *gorm.DB) error {
+			return migrate.BackfillGuild(tx, "autopins")
+		},
+		Down: func(tx *gorm.DB) error {
+			// The backfilled server IDs are kept
+			return nil
+		},
+	},
+}
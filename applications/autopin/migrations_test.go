@@ -0,0 +1,29 @@
+package autopin
+
+import (
+	"testing"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"github.com/aussiebroadwan/tony/database/migrate"
+)
+
+const exampleGuildId = "1229977032540573766"
+
+func TestMigrateLegacyAutopins(t *testing.T) {
+	// An autopin from before they were kept per server
+	db := dbtest.Open(t, Migrations[:1])
+	db.Exec("INSERT INTO autopins (channel_id, message_id, reacts) VALUES (?, ?, ?)", "channel", "message", 3)
+
+	migrator, err := migrate.New(db, Migrations)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	migrator.SetLegacyGuild(exampleGuildId)
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	if reacts, _, err := GetAutopin(db, exampleGuildId, "channel", "message"); err != nil || reacts != 3 {
+		t.Errorf("expected the autopin in the legacy server, got %d reacts: %v", reacts, err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 type Autopin struct {
 	gorm.Model // Includes fields like ID, CreatedAt, UpdatedAt, which you may or may not want to use.
+	GuildID    string
 	ChannelID  string
 	MessageID  string
 	Reacts     int
@@ -0,0 +1,52 @@
+package autopin
+
+import (
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/bwmarrin/discordgo"
+)
+
+const pinMessageCommandName = "Pin this for later"
+
+// PinMessageCommand is the message context menu command which lets members
+// who can manage messages pin a message straight away, without waiting for it
+// to reach the autopin threshold.
+//
+//	Right click a message > Apps > Pin this for later
+type PinMessageCommand struct {
+	framework.ApplicationCommand
+}
+
+func (c PinMessageCommand) GetType() framework.AppType {
+	return framework.AppTypeCommand
+}
+
+func (c PinMessageCommand) GetDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name: pinMessageCommandName,
+		Type: discordgo.MessageApplicationCommand,
+	}
+}
+
+func (c PinMessageCommand) OnCommand(ctx framework.CommandContext) {
+	message := ctx.TargetMessage()
+	if message == nil {
+		ctx.Logger().Error("No target message for pin command")
+		return
+	}
+
+	content := "Message pinned"
+	if err := ctx.Session().ChannelMessagePin(message.ChannelID, message.ID); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to pin message")
+		content = "**Error:** Failed to pin message"
+	} else if err := PinAutopin(ctx.Database(), ctx.GuildID(), message.ChannelID, message.ID); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to record pinned message")
+	}
+
+	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: content,
+		},
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"slices"
 	"strconv"
 
+	"github.com/aussiebroadwan/tony/config"
 	"github.com/aussiebroadwan/tony/framework"
 	"github.com/aussiebroadwan/tony/pkg/blackjack"
 	"github.com/aussiebroadwan/tony/pkg/wallet"
@@ -25,10 +26,12 @@ type Blackjack struct {
 	framework.ApplicationCommand
 	framework.ApplicationEvent
 	framework.ApplicationMountable
+	framework.ApplicationShutdown
+	framework.ApplicationConfig
 }
 
 func (b Blackjack) GetType() framework.AppType {
-	return framework.AppTypeCommand | framework.AppTypeEvent | framework.AppTypeMountable
+	return framework.AppTypeCommand | framework.AppTypeEvent | framework.AppTypeMountable | framework.AppTypeShutdown | framework.AppTypeConfig
 }
 
 func (b Blackjack) OnMount(ctx framework.MountContext) {
@@ -36,6 +39,26 @@ func (b Blackjack) OnMount(ctx framework.MountContext) {
 	RegisterCards(ctx.Database())
 }
 
+// OnShutdown waits for the round being played to pay out, or cancels it and
+// refunds the bets if it takes too long
+func (b Blackjack) OnShutdown(ctx framework.ShutdownContext) {
+	if err := blackjack.Shutdown(ctx.Context()); err != nil {
+		ctx.Logger().WithError(err).Warn("Cancelled the blackjack round and refunded its bets")
+	}
+}
+
+// OnConfig sets how many players can join a round, which applies from the
+// next player to join
+func (b Blackjack) OnConfig(ctx framework.ConfigContext) {
+	var cfg config.Config
+	if err := ctx.Config(&cfg); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to read config")
+		return
+	}
+
+	blackjack.SetMaxPlayers(cfg.Blackjack.MaxPlayers)
+}
+
 func (b Blackjack) GetDefinition() *discordgo.ApplicationCommand {
 	return &discordgo.ApplicationCommand{
 		Name:        "blackjack",
@@ -44,7 +67,7 @@ func (b Blackjack) GetDefinition() *discordgo.ApplicationCommand {
 }
 
 func (b Blackjack) OnCommand(ctx framework.CommandContext) {
-	if blackjack.Running() {
+	if blackjack.Running(ctx.GuildID()) {
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
@@ -55,14 +78,20 @@ func (b Blackjack) OnCommand(ctx framework.CommandContext) {
 		return
 	}
 
-	err := blackjack.Host(stateRenderer(ctx))
+	stateCb, achievementCb, channelId, messageId := stateRenderer(ctx)
+	err := blackjack.Host(ctx.GuildID(), stateCb, achievementCb, channelId, messageId)
 	if err != nil {
+		reason := "Failed to start a game"
+		if err == blackjack.ErrShuttingDown {
+			reason = "Tony is restarting, try again shortly"
+		}
+
 		ctx.Logger().WithError(err).Error("Failed to start a game")
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
 				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "Failed to start a game",
+				Content: reason,
 			},
 		})
 		return
@@ -159,26 +188,32 @@ func OnJoin(ctx framework.EventContext) {
 		return
 	}
 
-	err = blackjack.Join(ctx.GetUser().ID, int64(betInt))
+	// A retried join has already been handled, joining again would refund
+	// the bet of a user who is playing
+	key := "blackjack:bet:" + ctx.Interaction().ID
+	applied, err := wallet.Applied(ctx.Database(), ctx.GuildID(), key)
 	if err != nil {
-		reason := "Too many people have joined"
-		if err == blackjack.ErrAlreadyJoined {
-			reason = "You have already joined"
-		}
-
-		ctx.Logger().WithError(err).Error("Failed to join game")
+		ctx.Logger().WithError(err).Error("Failed to check for the bet")
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
 				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "**Error**: " + reason,
+				Content: "**Error**: " + err.Error(),
 			},
 		})
 		return
 	}
+	if applied {
+		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: nil,
+		})
+		return
+	}
 
-	// Charge the user's balance
-	err = wallet.Debit(ctx.Database(), ctx.GetUser().ID, int64(betInt), "Blackjack bet", "blackjack")
+	// Charge the user's balance before joining, so nobody plays without
+	// paying. The bet is only taken once even if the interaction is retried.
+	err = wallet.Debit(ctx.Database(), ctx.GuildID(), ctx.GetUser().ID, int64(betInt), "Blackjack bet", "blackjack", wallet.IdempotencyKey(key))
 	if err != nil {
 		// You can react to button presses with no data and it doesn't error or send a message
 		ctx.Logger().WithError(err).Error("Failed to charge user")
@@ -192,6 +227,30 @@ func OnJoin(ctx framework.EventContext) {
 		return
 	}
 
+	err = blackjack.Join(ctx.GuildID(), ctx.GetUser().ID, int64(betInt))
+	if err != nil {
+		reason := "Too many people have joined"
+		if err == blackjack.ErrAlreadyJoined {
+			reason = "You have already joined"
+		}
+		ctx.Logger().WithError(err).Error("Failed to join game")
+
+		// Give the bet back as the user isn't playing
+		refund := wallet.IdempotencyKey(key + ":refund")
+		if err := wallet.Credit(ctx.Database(), ctx.GuildID(), ctx.GetUser().ID, int64(betInt), "Blackjack bet refund", "blackjack", refund); err != nil {
+			ctx.Logger().WithError(err).Error("Failed to refund bet")
+		}
+
+		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Flags:   discordgo.MessageFlagsEphemeral,
+				Content: "**Error**: " + reason,
+			},
+		})
+		return
+	}
+
 	// You can react to button presses with no data and it doesn't error or send a message
 	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -202,7 +261,7 @@ func OnJoin(ctx framework.EventContext) {
 func OnHit(ctx framework.EventContext) {
 	user := ctx.GetUser()
 
-	err := blackjack.Hit(user.ID)
+	err := blackjack.Hit(ctx.GuildID(), user.ID)
 	if err != nil {
 		ctx.Logger().WithField("user", user.Username).WithError(err).Error("Failed to hit")
 	} else {
@@ -220,7 +279,7 @@ func OnHit(ctx framework.EventContext) {
 func OnStand(ctx framework.EventContext) {
 	user := ctx.GetUser()
 
-	err := blackjack.Stand(user.ID)
+	err := blackjack.Stand(ctx.GuildID(), user.ID)
 	if err != nil {
 		ctx.Logger().WithField("user", user.Username).WithError(err).Error("Failed to stand")
 	} else {
@@ -0,0 +1,88 @@
+package blackjack_app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/aussiebroadwan/tony/framework/frameworktest"
+	"github.com/aussiebroadwan/tony/pkg/blackjack"
+	"github.com/aussiebroadwan/tony/pkg/wallet"
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	exampleGuildId = "1229977032540573766"
+	exampleUserId  = "1060681976622891089"
+)
+
+// joinInteraction builds the join modal submitted with the bet
+func joinInteraction(id, bet string) *discordgo.Interaction {
+	return &discordgo.Interaction{
+		ID:        id,
+		Type:      discordgo.InteractionModalSubmit,
+		ChannelID: "channel",
+		GuildID:   exampleGuildId,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: exampleUserId, Username: "player"}},
+		Data: discordgo.ModalSubmitInteractionData{
+			CustomID: "blackjack:join",
+			Components: []discordgo.MessageComponent{
+				&discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					&discordgo.TextInput{CustomID: "bet", Value: bet},
+				}},
+			},
+		},
+	}
+}
+
+func TestOnJoinRetried(t *testing.T) {
+	db := dbtest.Open(t, wallet.Migrations)
+	wallet.SetupWalletDB(db, log.WithField("src", "wallet"))
+
+	stateCb := func(stage blackjack.GameStage, state blackjack.GameState, messageId, channelId string) {}
+	if err := blackjack.Host(exampleGuildId, stateCb, nil, "message", "channel"); err != nil {
+		t.Fatalf("Failed to host game: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		blackjack.Shutdown(ctx)
+	})
+
+	tests := []struct {
+		name        string
+		interaction string
+		response    string
+		balance     int64
+	}{
+		{"joined", "join1", "", wallet.DefaultBalance - 50},
+		{"retried", "join1", "", wallet.DefaultBalance - 50},
+		{"joined again", "join2", "**Error**: You have already joined", wallet.DefaultBalance - 50},
+	}
+
+	for _, test := range tests {
+		session := frameworktest.NewFakeSession()
+		ctx, err := framework.NewInteractionContext(session, db, log.WithField("src", "test"), joinInteraction(test.interaction, "50"))
+		if err != nil {
+			t.Fatalf("Failed to create context: %v", err)
+		}
+
+		OnJoin(ctx)
+
+		response := session.LastResponse()
+		content := ""
+		if response != nil && response.Data != nil {
+			content = response.Data.Content
+		}
+		if response == nil || content != test.response {
+			t.Errorf("%s: expected response %q, got %+v", test.name, test.response, response)
+		}
+
+		if balance, _ := wallet.Balance(db, exampleGuildId, exampleUserId); balance != test.balance {
+			t.Errorf("%s: expected balance %d, got %d", test.name, test.balance, balance)
+		}
+	}
+}
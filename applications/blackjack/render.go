@@ -28,13 +28,23 @@ func stateRenderer(ctx framework.CommandContext) (blackjack.StateChangeCallback,
 		return nil, nil, "", ""
 	}
 
-	creditUser := func(userId string, amount int64) {
-		if err := wallet.Credit(database, userId, amount, "Blackjack returns", "blackjack"); err != nil {
+	// A round only pays out or refunds each user once, even if its state is
+	// rendered again
+	creditUser := func(roundId, userId string, amount int64) {
+		key := wallet.IdempotencyKey(fmt.Sprintf("blackjack:%s:%s", roundId, userId))
+		if err := wallet.Credit(database, ctx.GuildID(), userId, amount, "Blackjack returns", "blackjack", key); err != nil {
 			ctx.Logger().WithError(err).Error("Failed to credit user")
 		}
 	}
 
-	return createGameStateRenderFunc(ctx, session, creditUser), onAchievement(ctx), interaction.ChannelID, msg.ID
+	refundUser := func(roundId, userId string, amount int64) {
+		key := wallet.IdempotencyKey(fmt.Sprintf("blackjack:%s:%s", roundId, userId))
+		if err := wallet.Credit(database, ctx.GuildID(), userId, amount, "Blackjack refund", "blackjack", key); err != nil {
+			ctx.Logger().WithError(err).Error("Failed to refund user")
+		}
+	}
+
+	return createGameStateRenderFunc(ctx, session, creditUser, refundUser), onAchievement(ctx), interaction.ChannelID, msg.ID
 }
 
 // onAchievement creates a function to handle achievement unlocks. It will
@@ -70,7 +80,7 @@ func onAchievement(ctx framework.CommandContext) blackjack.AchievementCallback {
 }
 
 // createGameStateRenderFunc creates a function to render the game state based on the current stage.
-func createGameStateRenderFunc(ctx framework.CommandContext, session *discordgo.Session, creditUser func(string, int64)) blackjack.StateChangeCallback {
+func createGameStateRenderFunc(ctx framework.CommandContext, session framework.Session, creditUser, refundUser func(string, string, int64)) blackjack.StateChangeCallback {
 	return func(stage blackjack.GameStage, state blackjack.GameState, channelId string, messageId string) {
 		ctx.Logger().WithField("stage", stage).Info("Rendering game state")
 
@@ -85,10 +95,20 @@ func createGameStateRenderFunc(ctx framework.CommandContext, session *discordgo.
 			description, components = roundMessage(state)
 		case blackjack.PayoutStage:
 			description, components = payoutMessage(state, creditUser)
+			ctx.Publish(blackjack.RoundFinished{
+				GuildId:   ctx.GuildID(),
+				ChannelId: channelId,
+				MessageId: messageId,
+				GameId:    state.Id,
+				Hand:      state.Hand,
+				Users:     state.Users,
+			})
 		case blackjack.ReshuffleStage:
 			description, components = reshuffleMessage()
 		case blackjack.FinishedStage:
 			description, components = finishedMessage()
+		case blackjack.CancelledStage:
+			description, components = cancelledMessage(state, refundUser)
 		default:
 			session.ChannelMessageEdit(channelId, messageId, preparingGameMessage)
 			return
@@ -103,7 +123,7 @@ func createGameStateRenderFunc(ctx framework.CommandContext, session *discordgo.
 }
 
 // renderState updates the game message with new state information and interaction components.
-func renderState(session *discordgo.Session, channelId, messageId, title, description string, components []discordgo.MessageComponent) error {
+func renderState(session framework.Session, channelId, messageId, title, description string, components []discordgo.MessageComponent) error {
 	edit := discordgo.NewMessageEdit(channelId, messageId)
 	edit.Embeds = &[]*discordgo.MessageEmbed{{Title: title, Description: description, Color: embedColor}}
 	if components != nil {
@@ -117,7 +137,7 @@ func renderState(session *discordgo.Session, channelId, messageId, title, descri
 func joinMessage(state blackjack.GameState) (string, []discordgo.MessageComponent) {
 	description := "To join place a bet. How much would you like to bet? Min is :coin: 10 and max is :coin: 999"
 	if len(state.Users) > 0 {
-		description += fmt.Sprintf("\n\nPlayers (%d / %d):\n", len(state.Users), blackjack.MaxPlayers)
+		description += fmt.Sprintf("\n\nPlayers (%d / %d):\n", len(state.Users), blackjack.MaxPlayers())
 		for _, user := range state.Users {
 			description += fmt.Sprintf("<@%s> bets :coin: %d\n", user.Id, user.Bet)
 		}
@@ -186,12 +206,12 @@ func roundMessage(state blackjack.GameState) (string, []discordgo.MessageCompone
 }
 
 // payoutMessage generates the payout stage message and components.
-func payoutMessage(state blackjack.GameState, creditUser func(string, int64)) (string, []discordgo.MessageComponent) {
+func payoutMessage(state blackjack.GameState, creditUser func(string, string, int64)) (string, []discordgo.MessageComponent) {
 	description := "The round is over. Here are the results:\n\n"
 	for _, user := range state.Users {
 		description += fmt.Sprintf("<@%s>: :coin: %d\n", user.Id, user.Bet)
 		if user.Bet > 0 {
-			creditUser(user.Id, user.Bet)
+			creditUser(state.RoundId, user.Id, user.Bet)
 		}
 	}
 	description += "\nThe next round will begin shortly."
@@ -232,3 +252,23 @@ func finishedMessage() (string, []discordgo.MessageComponent) {
 		},
 	}
 }
+
+// cancelledMessage generates the message for a round cancelled by the bot
+// shutting down, refunding the bets of the users in the round.
+func cancelledMessage(state blackjack.GameState, refundUser func(string, string, int64)) (string, []discordgo.MessageComponent) {
+	description := "The table has closed as Tony is restarting. Bets have been refunded:\n\n"
+	for _, user := range state.Users {
+		description += fmt.Sprintf("<@%s>: :coin: %d\n", user.Id, user.InitialBet)
+		refundUser(state.RoundId, user.Id, user.InitialBet)
+	}
+	description += "\nStart a new game with `/blackjack` once Tony is back."
+
+	return description, []discordgo.MessageComponent{
+		discordgo.Button{
+			Label:    "Join",
+			Style:    discordgo.SuccessButton,
+			CustomID: "blackjack:host",
+			Disabled: true,
+		},
+	}
+}
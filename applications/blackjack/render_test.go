@@ -0,0 +1,102 @@
+package blackjack_app
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/aussiebroadwan/tony/framework/frameworktest"
+	"github.com/aussiebroadwan/tony/pkg/blackjack"
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const otherUserId = "169015299834642432"
+
+// publishContext records the events published while rendering
+type publishContext struct {
+	framework.CommandContext
+	events []any
+}
+
+func (c *publishContext) Publish(event any) {
+	c.events = append(c.events, event)
+}
+
+func TestRenderEvents(t *testing.T) {
+	state := blackjack.GameState{
+		Id:      "game",
+		RoundId: "round",
+		Hand:    blackjack.Hand{},
+		Users: []blackjack.User{
+			{Id: exampleUserId, InitialBet: 50, Bet: 100},
+			{Id: otherUserId, InitialBet: 20, Bet: 0},
+		},
+	}
+
+	tests := []struct {
+		stage    blackjack.GameStage
+		finished bool
+		credits  map[string]int64
+		refunds  map[string]int64
+	}{
+		{blackjack.JoinStage, false, map[string]int64{}, map[string]int64{}},
+		{blackjack.RoundStage, false, map[string]int64{}, map[string]int64{}},
+		{blackjack.PayoutStage, true, map[string]int64{exampleUserId: 100}, map[string]int64{}},
+		{blackjack.ReshuffleStage, false, map[string]int64{}, map[string]int64{}},
+		{blackjack.FinishedStage, false, map[string]int64{}, map[string]int64{}},
+		{blackjack.CancelledStage, false, map[string]int64{}, map[string]int64{exampleUserId: 50, otherUserId: 20}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.stage), func(t *testing.T) {
+			session := frameworktest.NewFakeSession()
+			message, _ := session.ChannelMessageSend("channel", preparingGameMessage)
+
+			interaction := &discordgo.Interaction{
+				ID:        "interaction",
+				Type:      discordgo.InteractionApplicationCommand,
+				ChannelID: "channel",
+				GuildID:   exampleGuildId,
+				Member:    &discordgo.Member{User: &discordgo.User{ID: exampleUserId, Username: "host"}},
+				Data:      discordgo.ApplicationCommandInteractionData{Name: "blackjack"},
+			}
+			base, err := framework.NewInteractionContext(session, nil, log.WithField("src", "test"), interaction)
+			if err != nil {
+				t.Fatalf("Failed to create context: %v", err)
+			}
+			ctx := &publishContext{CommandContext: base}
+
+			credits, refunds := map[string]int64{}, map[string]int64{}
+			creditUser := func(roundId, userId string, amount int64) { credits[userId] += amount }
+			refundUser := func(roundId, userId string, amount int64) { refunds[userId] += amount }
+
+			render := createGameStateRenderFunc(ctx, session, creditUser, refundUser)
+			render(test.stage, state, "channel", message.ID)
+
+			if test.finished {
+				if len(ctx.events) != 1 {
+					t.Fatalf("Expected a RoundFinished event, got %+v", ctx.events)
+				}
+				event, ok := ctx.events[0].(blackjack.RoundFinished)
+				if !ok || event.GuildId != exampleGuildId || event.MessageId != message.ID || event.GameId != "game" || len(event.Users) != 2 {
+					t.Errorf("Expected the round to be published, got %+v", ctx.events[0])
+				}
+			} else if len(ctx.events) != 0 {
+				t.Errorf("Expected no events, got %+v", ctx.events)
+			}
+
+			if !maps.Equal(credits, test.credits) {
+				t.Errorf("Expected credits %v, got %v", test.credits, credits)
+			}
+			if !maps.Equal(refunds, test.refunds) {
+				t.Errorf("Expected refunds %v, got %v", test.refunds, refunds)
+			}
+
+			if embeds := session.LastMessage().Embeds; len(embeds) != 1 || embeds[0].Title != "Blackjack: "+string(test.stage) {
+				t.Errorf("Expected the message to show the stage, got %+v", embeds)
+			}
+		})
+	}
+}
@@ -3,7 +3,9 @@ package applications
 import (
 	"errors"
 	"strings"
+	"sync/atomic"
 
+	"github.com/aussiebroadwan/tony/config"
 	"github.com/aussiebroadwan/tony/framework"
 	"github.com/bwmarrin/discordgo"
 )
@@ -32,9 +34,19 @@ func RegisterNewsModeration(bot *framework.Bot) framework.Route {
 //
 // If the message does not match the format, the bot will delete the message and
 // send a message to the user to let them know that the message was deleted and
-// why.
+// why. Edited messages are checked again.
 type ModerateNewsRule struct {
 	framework.ApplicationMessage
+	framework.ApplicationMessageUpdate
+	framework.ApplicationConfig
+}
+
+// newsChannel is the name of the moderated channel, set from the config by
+// OnConfig
+var newsChannel atomic.Value
+
+func init() {
+	newsChannel.Store("tech-news")
 }
 
 var (
@@ -44,13 +56,35 @@ var (
 )
 
 func (r ModerateNewsRule) GetType() framework.AppType {
-	return framework.AppTypeMessage
+	return framework.AppTypeMessage | framework.AppTypeMessageUpdate | framework.AppTypeConfig
+}
+
+// OnConfig sets which channel is moderated
+func (r ModerateNewsRule) OnConfig(ctx framework.ConfigContext) {
+	var cfg config.Config
+	if err := ctx.Config(&cfg); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to read config")
+		return
+	}
+
+	newsChannel.Store(cfg.Moderation.NewsChannel)
 }
 
 func (r ModerateNewsRule) OnMessage(ctx framework.MessageContext, channel *discordgo.Channel) {
+	r.moderate(ctx, channel)
+}
+
+func (r ModerateNewsRule) OnMessageUpdate(ctx framework.MessageUpdateContext, channel *discordgo.Channel) {
+	if contentEdited(ctx) {
+		r.moderate(ctx, channel)
+	}
+}
+
+// Deletes the message if it is in the #tech-news channel and breaks the rule
+func (r ModerateNewsRule) moderate(ctx framework.MessageContext, channel *discordgo.Channel) {
 
 	// Check if the message is in the correct channel
-	if channel.Name != "tech-news" {
+	if channel.Name != newsChannel.Load().(string) {
 		return
 	}
 
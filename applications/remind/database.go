@@ -1,20 +1,20 @@
 package remind
 
 import (
+	"fmt"
 	"time"
 
-	"github.com/bwmarrin/discordgo"
+	"github.com/aussiebroadwan/tony/framework"
 
 	log "github.com/sirupsen/logrus"
 
 	"gorm.io/gorm"
 )
 
-func SetupRemindersDB(db *gorm.DB, session *discordgo.Session) {
-	// AutoMigrate will create or update the reminders table to match the Reminder struct
-	if err := db.AutoMigrate(&Reminder{}); err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
-	}
+// SetupRemindersDB sends the reminders with the scheduler, scheduling those
+// which have not been sent. The table is created and updated by Migrations.
+func SetupRemindersDB(db *gorm.DB, scheduler *framework.Scheduler) {
+	scheduler.Handle(remindJob, sendReminder)
 
 	// Load all reminders from the database
 	reminders, err := LoadReminders(db)
@@ -22,15 +22,12 @@ func SetupRemindersDB(db *gorm.DB, session *discordgo.Session) {
 		log.Fatalf("Failed to load reminders: %v", err)
 	}
 
-	// Iterate over each reminder
+	// Schedule each reminder, which replaces the job if it was already
+	// scheduled and adds it for reminders from before the scheduler
 	for _, reminder := range reminders {
-		// If the reminder has already been reminded, skip it
-		if reminder.Reminded {
-			continue
+		if err := scheduleReminder(scheduler, reminder); err != nil {
+			log.Errorf("Failed to schedule reminder %d: %v", reminder.ID, err)
 		}
-
-		// Add the reminder to scheduler
-		Add(reminder.ID, reminder)
 	}
 }
 
@@ -40,8 +37,9 @@ func LoadReminders(db *gorm.DB) ([]Reminder, error) {
 	return reminders, result.Error
 }
 
-func AddReminder(db *gorm.DB, createdBy string, triggerTime time.Time, session *discordgo.Session, channelId string, message string) (uint, error) {
+func AddReminder(db *gorm.DB, scheduler *framework.Scheduler, guildId, createdBy string, triggerTime time.Time, channelId string, message string) (uint, error) {
 	reminder := Reminder{
+		GuildID:     guildId,
 		CreatedBy:   createdBy,
 		ChannelID:   channelId,
 		TriggerTime: triggerTime,
@@ -49,21 +47,66 @@ func AddReminder(db *gorm.DB, createdBy string, triggerTime time.Time, session *
 		Reminded:    false,
 	}
 
-	result := db.Create(&reminder)
-	if result.Error != nil {
-		return 0, result.Error
+	if err := db.Create(&reminder).Error; err != nil {
+		return 0, err
+	}
+
+	// Only keep the reminder if it could be scheduled. It is scheduled once
+	// created rather than in the same transaction, as the scheduler uses its
+	// own connection which SQLite would make wait for the transaction.
+	if err := scheduleReminder(scheduler, reminder); err != nil {
+		if err := db.Unscoped().Delete(&reminder).Error; err != nil {
+			log.WithField("src", "remind").WithError(err).Errorf("Failed to delete unscheduled reminder %d", reminder.ID)
+		}
+		return 0, err
 	}
 
-	// Add the reminder to the reminders package
-	Add(reminder.ID, reminder)
 	return reminder.ID, nil
 }
 
-func DeleteReminder(db *gorm.DB, id uint, user string) error {
-	result := db.Delete(&Reminder{}, id)
-	if result.Error != nil {
-		return result.Error
+func DeleteReminder(db *gorm.DB, scheduler *framework.Scheduler, guildId string, id uint, user string) error {
+	reminder, err := getReminder(db, guildId, id)
+	if err != nil {
+		return err
+	}
+
+	if reminder.CreatedBy != user {
+		return fmt.Errorf("reminder with ID %d does not belong to user %s", id, user)
+	}
+
+	// The job may have already run
+	if err := scheduler.Unschedule(reminderKey(id)); err != nil && err != framework.ErrNoJob {
+		return err
+	}
+
+	return db.Delete(&reminder).Error
+}
+
+// List returns a slice of upcoming reminders in the server.
+func List(db *gorm.DB, guildId string) []Reminder {
+	var upcoming []Reminder
+	db.Where("guild_id = ? AND reminded = ? AND trigger_time > ?", guildId, false, time.Now()).Order("trigger_time").Find(&upcoming)
+	return upcoming
+}
+
+// Status returns the time left for a reminder in the server.
+func Status(db *gorm.DB, guildId string, id uint) (time.Duration, error) {
+	reminder, err := getReminder(db, guildId, id)
+	if err != nil {
+		return 0, err
 	}
+	return time.Until(reminder.TriggerTime), nil
+}
 
-	return Delete(id, user)
+// getReminder returns the reminder in the server which has not been sent yet
+func getReminder(db *gorm.DB, guildId string, id uint) (Reminder, error) {
+	var reminders []Reminder
+	if err := db.Where("id = ? AND guild_id = ? AND reminded = ?", id, guildId, false).Limit(1).Find(&reminders).Error; err != nil {
+		return Reminder{}, err
+	}
+
+	if len(reminders) == 0 {
+		return Reminder{}, fmt.Errorf("reminder with ID %d not found", id)
+	}
+	return reminders[0], nil
 }
@@ -0,0 +1,102 @@
+package remind
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/aussiebroadwan/tony/framework/frameworktest"
+	"gorm.io/gorm"
+)
+
+const (
+	exampleGuildId = "1229977032540573766"
+	exampleUserId  = "<@1060681976622891089>"
+)
+
+func setupTestDB(t *testing.T) (*gorm.DB, *framework.Scheduler) {
+	db := dbtest.Open(t, framework.Migrations, Migrations)
+
+	bot, err := framework.NewBot("token", []string{exampleGuildId}, db)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	return db, bot.Scheduler()
+}
+
+func scheduledJobs(db *gorm.DB, id uint) int64 {
+	var count int64
+	db.Model(&framework.ScheduledJob{}).Where("key = ?", reminderKey(id)).Count(&count)
+	return count
+}
+
+func TestAddReminder(t *testing.T) {
+	db, scheduler := setupTestDB(t)
+
+	id, err := AddReminder(db, scheduler, exampleGuildId, exampleUserId, time.Now().Add(time.Hour), "channel", "stretch")
+	if err != nil {
+		t.Fatalf("Failed to add reminder: %v", err)
+	}
+
+	if upcoming := List(db, exampleGuildId); len(upcoming) != 1 || upcoming[0].ID != id {
+		t.Errorf("Expected the reminder to be listed, got %+v", upcoming)
+	}
+	if count := scheduledJobs(db, id); count != 1 {
+		t.Errorf("Expected the reminder to be scheduled, got %d jobs", count)
+	}
+
+	// Other users can't delete it
+	if err := DeleteReminder(db, scheduler, exampleGuildId, id, "<@169015299834642432>"); err == nil {
+		t.Errorf("Expected another user not to be able to delete the reminder")
+	}
+
+	if err := DeleteReminder(db, scheduler, exampleGuildId, id, exampleUserId); err != nil {
+		t.Fatalf("Failed to delete reminder: %v", err)
+	}
+	if upcoming := List(db, exampleGuildId); len(upcoming) != 0 {
+		t.Errorf("Expected no reminders, got %+v", upcoming)
+	}
+	if count := scheduledJobs(db, id); count != 0 {
+		t.Errorf("Expected the job to be unscheduled, got %d jobs", count)
+	}
+}
+
+func TestAddReminderNotScheduled(t *testing.T) {
+	db, scheduler := setupTestDB(t)
+
+	// A reminder without a time can't be scheduled, so it isn't kept
+	if _, err := AddReminder(db, scheduler, exampleGuildId, exampleUserId, time.Time{}, "channel", "stretch"); err != framework.ErrInvalidJob {
+		t.Fatalf("Expected ErrInvalidJob, got %v", err)
+	}
+
+	var count int64
+	db.Unscoped().Model(&Reminder{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected the reminder to be removed, got %d", count)
+	}
+}
+
+func TestReminderAction(t *testing.T) {
+	db, scheduler := setupTestDB(t)
+
+	id, _ := AddReminder(db, scheduler, exampleGuildId, exampleUserId, time.Now().Add(time.Hour), "channel", "stretch")
+	reminder, err := getReminder(db, exampleGuildId, id)
+	if err != nil {
+		t.Fatalf("Failed to get reminder: %v", err)
+	}
+
+	session := frameworktest.NewFakeSession()
+	if err := reminder.Action(db, session); err != nil {
+		t.Fatalf("Failed to send reminder: %v", err)
+	}
+
+	if message := session.LastMessage(); message == nil || message.ChannelID != "channel" || message.Content != exampleUserId+" stretch" {
+		t.Errorf("Expected the reminder to be sent, got %+v", message)
+	}
+
+	// Sent reminders aren't sent again
+	if _, err := getReminder(db, exampleGuildId, id); err == nil {
+		t.Errorf("Expected the reminder to be marked as sent")
+	}
+}
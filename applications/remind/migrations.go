@@ -0,0 +1,51 @@
+package remind
+
+import (
+	"time"
+
+	"github.com/aussiebroadwan/tony/database/migrate"
+	"gorm.io/gorm"
+)
+
+// reminderV1 is the reminders table as it was first created by migrations,
+// later changes are made by the migrations after
+type reminderV1 struct {
+	gorm.Model
+
+	GuildID     string
+	CreatedBy   string
+	ChannelID   string
+	TriggerTime time.Time
+	Message     string
+	Reminded    bool
+}
+
+func (reminderV1) TableName() string { return "reminders" }
+
+// Migrations are the changes to the reminders table, in order
+var Migrations = []migrate.Migration{
+	{
+		Package: "remind",
+		Version: 1,
+		Name:    "create reminders table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&reminderV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&reminderV1{})
+		},
+	},
+	{
+		// Reminders from before they were kept per server have no server ID
+		Package: "remind",
+		Version: 2,
+		Name:    "give legacy reminders a server",
+		Up: func(tx *gorm.DB) error {
+			return migrate.BackfillGuild(tx, "reminders")
+		},
+		Down: func(tx *gorm.DB) error {
+			// Nothing to undo, the reminders keep their server
+			return nil
+		},
+	},
+}
@@ -0,0 +1,28 @@
+package remind
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"github.com/aussiebroadwan/tony/database/migrate"
+)
+
+func TestMigrateLegacyReminders(t *testing.T) {
+	// A reminder from before they were kept per server
+	db := dbtest.Open(t, Migrations[:1])
+	db.Create(&reminderV1{CreatedBy: exampleUserId, ChannelID: "channel", TriggerTime: time.Now().Add(time.Hour), Message: "stretch"})
+
+	migrator, err := migrate.New(db, Migrations)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	migrator.SetLegacyGuild(exampleGuildId)
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if upcoming := List(db, exampleGuildId); len(upcoming) != 1 || upcoming[0].Message != "stretch" {
+		t.Errorf("Expected the reminder in the legacy server, got %+v", upcoming)
+	}
+}
@@ -4,13 +4,13 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/bwmarrin/discordgo"
-	log "github.com/sirupsen/logrus"
+	"github.com/aussiebroadwan/tony/framework"
 	"gorm.io/gorm"
 )
 
 type Reminder struct {
 	gorm.Model  // Includes fields ID, CreatedAt, UpdatedAt, DeletedAt
+	GuildID     string
 	CreatedBy   string
 	ChannelID   string
 	TriggerTime time.Time
@@ -18,13 +18,13 @@ type Reminder struct {
 	Reminded    bool
 }
 
-func (r Reminder) Action(db *gorm.DB, session *discordgo.Session) {
+func (r Reminder) Action(db *gorm.DB, session framework.Session) error {
 	// Send the reminder message
-	session.ChannelMessageSend(r.ChannelID, fmt.Sprintf("%s %s", r.CreatedBy, r.Message))
+	if _, err := session.ChannelMessageSend(r.ChannelID, fmt.Sprintf("%s %s", r.CreatedBy, r.Message)); err != nil {
+		return err
+	}
 
 	// Set the reminder as reminded
 	r.Reminded = true
-	if err := db.Save(&r).Error; err != nil {
-		log.Errorf("Failed to mark reminder %d as reminded: %v", r.ID, err)
-	}
+	return db.Save(&r).Error
 }
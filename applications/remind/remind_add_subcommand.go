@@ -24,38 +24,40 @@ func (c RemindAddSubCommand) GetType() framework.AppType {
 	return framework.AppTypeSubCommand
 }
 
+func (c RemindAddSubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "add",
+		Description: "Add a reminder",
+		Options:     framework.CommandOptions(RemindAddArgs{}),
+	}
+}
+
+// RemindAddArgs are the options of "/remind add"
+type RemindAddArgs struct {
+	Message string `option:"message,required" description:"The message to remind you about"`
+	Time    string `option:"time,required" description:"The time to remind you"`
+}
+
 func (c RemindAddSubCommand) OnCommand(ctx framework.CommandContext) {
 	interaction := ctx.Interaction()
 	db := ctx.Database()
-	commandOptions := interaction.ApplicationCommandData().Options[0].Options
 
 	// Get the time and message from the interaction
-	triggerTimeStr, err := framework.GetOption(commandOptions, "time")
-	if err != nil {
-		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "**Error:** Time is required",
-			},
-		})
-		return
-	}
-
-	message, err := framework.GetOption(commandOptions, "message")
-	if err != nil {
+	var args RemindAddArgs
+	if err := ctx.Bind(&args); err != nil {
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
 				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "**Error:** Message is required",
+				Content: "**Error:** " + err.Error(),
 			},
 		})
 		return
 	}
 
 	// Check if the time is valid
-	triggerTime, err := time.ParseInLocation(time.DateTime, triggerTimeStr.StringValue(), time.Local)
+	triggerTime, err := time.ParseInLocation(time.DateTime, args.Time, time.Local)
 	if err != nil {
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -76,11 +78,12 @@ func (c RemindAddSubCommand) OnCommand(ctx framework.CommandContext) {
 	// Add the reminder
 	id, err := AddReminder(
 		db,
+		ctx.Scheduler(),
+		ctx.GuildID(),
 		user.Mention(),
 		triggerTime,
-		ctx.Session(),
 		interaction.ChannelID,
-		message.StringValue(),
+		args.Message,
 	)
 
 	if err != nil {
@@ -0,0 +1,47 @@
+package remind
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/bwmarrin/discordgo"
+)
+
+// suggestReminders autocompletes a reminder ID option with the reminders owned
+// by the user, filtered by the ID the user has typed so far.
+func suggestReminders(ctx framework.AutocompleteContext) {
+	user := ctx.GetUser()
+
+	typed := ""
+	if focused := ctx.FocusedOption(); focused != nil {
+		typed = fmt.Sprintf("%v", focused.Value)
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	for _, reminder := range List(ctx.Database(), ctx.GuildID()) {
+		if reminder.CreatedBy != user.Mention() {
+			continue
+		}
+
+		id := fmt.Sprintf("%d", reminder.ID)
+		if !strings.HasPrefix(id, typed) {
+			continue
+		}
+
+		// Choice names are limited to 100 characters
+		name := fmt.Sprintf("[%s] %s", id, reminder.Message)
+		if len(name) > 100 {
+			name = name[:97] + "..."
+		}
+
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: reminder.ID,
+		})
+	}
+
+	if err := ctx.RespondChoices(choices...); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to respond with reminder choices")
+	}
+}
@@ -15,70 +15,18 @@ func (c RemindCommand) GetType() framework.AppType {
 
 func (c RemindCommand) OnMount(ctx framework.MountContext) {
 
-	// Setup reminders
-	SetupRemindersDB(ctx.Database(), ctx.Session())
-	go Run(ctx.Database(), ctx.Session())
+	// Setup reminders, which are sent by the scheduler
+	SetupRemindersDB(ctx.Database(), ctx.Scheduler())
 }
 
 // Register is responsible for registering the "remind" command with
 // Discord's API. It defines the command name and description that
-// appear in the Discord user interface.
+// appear in the Discord user interface, the subcommands are generated from
+// the subroutes.
 func (c RemindCommand) GetDefinition() *discordgo.ApplicationCommand {
 	return &discordgo.ApplicationCommand{
 		Name:        "remind",
 		Description: "Allows users to set reminders",
-		Options: []*discordgo.ApplicationCommandOption{
-			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Name:        "add",
-				Description: "Add a reminder",
-				Options: []*discordgo.ApplicationCommandOption{
-					{
-						Type:        discordgo.ApplicationCommandOptionString,
-						Name:        "message",
-						Description: "The message to remind you about",
-						Required:    true,
-					},
-					{
-						Type:        discordgo.ApplicationCommandOptionString,
-						Name:        "time",
-						Description: "The time to remind you",
-						Required:    true,
-					},
-				},
-			},
-			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Name:        "del",
-				Description: "Delete a reminder",
-				Options: []*discordgo.ApplicationCommandOption{
-					{
-						Type:        discordgo.ApplicationCommandOptionInteger,
-						Name:        "id",
-						Description: "The ID of the reminder to delete",
-						Required:    true,
-					},
-				},
-			},
-			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Name:        "list",
-				Description: "List all reminders",
-			},
-			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Name:        "status",
-				Description: "Get the status of a reminder",
-				Options: []*discordgo.ApplicationCommandOption{
-					{
-						Type:        discordgo.ApplicationCommandOptionInteger,
-						Name:        "id",
-						Description: "The ID of the reminder to check",
-						Required:    true,
-					},
-				},
-			},
-		},
 	}
 }
 
@@ -20,22 +20,39 @@ type RemindDeleteSubCommand struct {
 }
 
 func (c RemindDeleteSubCommand) GetType() framework.AppType {
-	return framework.AppTypeSubCommand
+	return framework.AppTypeSubCommand | framework.AppTypeAutocomplete
+}
+
+func (c RemindDeleteSubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "del",
+		Description: "Delete a reminder",
+		Options:     framework.CommandOptions(RemindDeleteArgs{}),
+	}
+}
+
+func (c RemindDeleteSubCommand) OnAutocomplete(ctx framework.AutocompleteContext) {
+	suggestReminders(ctx)
+}
+
+// RemindDeleteArgs are the options of "/remind del"
+type RemindDeleteArgs struct {
+	ID int64 `option:"id,required,autocomplete" description:"The ID of the reminder to delete"`
 }
 
 func (c RemindDeleteSubCommand) OnCommand(ctx framework.CommandContext) {
 	interaction := ctx.Interaction()
 	db := ctx.Database()
-	commandOptions := interaction.ApplicationCommandData().Options[0].Options
 
 	// Get the reminder ID from the interaction
-	id, err := framework.GetOption(commandOptions, "id")
-	if err != nil {
+	var args RemindDeleteArgs
+	if err := ctx.Bind(&args); err != nil {
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
 				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "**Error:** id is required",
+				Content: "**Error:** " + err.Error(),
 			},
 		})
 		return
@@ -47,13 +64,13 @@ func (c RemindDeleteSubCommand) OnCommand(ctx framework.CommandContext) {
 	}
 
 	// Delete the reminder
-	err = DeleteReminder(db, uint(id.IntValue()), user.Mention())
+	err := DeleteReminder(db, ctx.Scheduler(), ctx.GuildID(), uint(args.ID), user.Mention())
 	if err != nil {
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
 				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: fmt.Sprintf("**Error:** reminder `[%d]` not found", id.IntValue()),
+				Content: fmt.Sprintf("**Error:** reminder `[%d]` not found", args.ID),
 			},
 		})
 	}
@@ -63,7 +80,7 @@ func (c RemindDeleteSubCommand) OnCommand(ctx framework.CommandContext) {
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
 			Flags:   discordgo.MessageFlagsEphemeral,
-			Content: fmt.Sprintf("Reminder `[%d]` deleted", id.IntValue()),
+			Content: fmt.Sprintf("Reminder `[%d]` deleted", args.ID),
 		},
 	})
 }
@@ -34,7 +34,7 @@ func (c RemindListSubCommand) OnCommand(ctx framework.CommandContext) {
 	interaction := ctx.Interaction()
 
 	// Get all reminders
-	reminderList := List()
+	reminderList := List(ctx.Database(), ctx.GuildID())
 
 	// Get the user who created the reminder
 	user := interaction.User
@@ -0,0 +1,128 @@
+package remind
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/bwmarrin/discordgo"
+)
+
+const remindMessageCommandName = "Remind me about this"
+
+func RegisterRemindMessageApp(bot *framework.Bot) framework.Route {
+	return framework.NewRoute(bot, remindMessageCommandName, &RemindMessageCommand{})
+}
+
+// This is the message context menu command for setting a reminder about a
+// message. The user is asked for the time in a modal and will be reminded with
+// a link back to the message.
+//
+//	Right click a message > Apps > Remind me about this
+type RemindMessageCommand struct {
+	framework.ApplicationCommand
+	framework.ApplicationEvent
+}
+
+func (c RemindMessageCommand) GetType() framework.AppType {
+	return framework.AppTypeCommand | framework.AppTypeEvent
+}
+
+func (c RemindMessageCommand) GetDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name: remindMessageCommandName,
+		Type: discordgo.MessageApplicationCommand,
+	}
+}
+
+func (c RemindMessageCommand) OnCommand(ctx framework.CommandContext) {
+	message := ctx.TargetMessage()
+	if message == nil {
+		ctx.Logger().Error("No target message for remind message command")
+		return
+	}
+
+	// Ask the user when they want to be reminded
+	err := ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: fmt.Sprintf("%s:%s:%s", remindMessageCommandName, message.ChannelID, message.ID),
+			Title:    "Remind me about this",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "time",
+							Label:       "Time",
+							Style:       discordgo.TextInputShort,
+							Placeholder: "eg. 2022-01-01 15:04:05",
+							Value:       time.Now().Add(time.Hour).Format(time.DateTime),
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		ctx.Logger().WithError(err).Error("Failed to respond to interaction")
+	}
+}
+
+func (c RemindMessageCommand) OnEvent(ctx framework.EventContext, eventType discordgo.InteractionType) {
+	interaction := ctx.Interaction()
+
+	if eventType != discordgo.InteractionModalSubmit {
+		ctx.Logger().Error("Invalid event type")
+		return
+	}
+
+	channelId, messageId, _ := strings.Cut(ctx.EventValue(), ":")
+
+	// Check if the time is valid
+	data := interaction.ModalSubmitData()
+	triggerTimeStr := data.Components[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+	triggerTime, err := time.ParseInLocation(time.DateTime, triggerTimeStr, time.Local)
+	if err != nil {
+		ctx.Session().InteractionRespond(interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Flags:   discordgo.MessageFlagsEphemeral,
+				Content: "**Error:** Invalid time format eg. 2022-01-01 15:04:05",
+			},
+		})
+		return
+	}
+
+	// Add the reminder with a link back to the message
+	id, err := AddReminder(
+		ctx.Database(),
+		ctx.Scheduler(),
+		ctx.GuildID(),
+		ctx.GetUser().Mention(),
+		triggerTime,
+		interaction.ChannelID,
+		fmt.Sprintf("https://discord.com/channels/%s/%s/%s", interaction.GuildID, channelId, messageId),
+	)
+	if err != nil {
+		ctx.Logger().WithError(err).Error("Failed to add reminder")
+		ctx.Session().InteractionRespond(interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Flags:   discordgo.MessageFlagsEphemeral,
+				Content: "**Error:** Failed to add reminder",
+			},
+		})
+		return
+	}
+
+	// Respond with the reminder ID
+	ctx.Session().InteractionRespond(interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: fmt.Sprintf("Reminder added `[%d]`", id),
+		},
+	})
+}
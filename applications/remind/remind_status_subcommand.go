@@ -20,34 +20,49 @@ type RemindStatusSubCommand struct {
 }
 
 func (c RemindStatusSubCommand) GetType() framework.AppType {
-	return framework.AppTypeSubCommand
+	return framework.AppTypeSubCommand | framework.AppTypeAutocomplete
 }
 
-func (c RemindStatusSubCommand) OnCommand(ctx framework.CommandContext) {
-	interaction := ctx.Interaction()
-	commandOptions := interaction.ApplicationCommandData().Options[0].Options
+func (c RemindStatusSubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "status",
+		Description: "Get the status of a reminder",
+		Options:     framework.CommandOptions(RemindStatusArgs{}),
+	}
+}
+
+func (c RemindStatusSubCommand) OnAutocomplete(ctx framework.AutocompleteContext) {
+	suggestReminders(ctx)
+}
 
+// RemindStatusArgs are the options of "/remind status"
+type RemindStatusArgs struct {
+	ID int64 `option:"id,required,autocomplete" description:"The ID of the reminder to check"`
+}
+
+func (c RemindStatusSubCommand) OnCommand(ctx framework.CommandContext) {
 	// Get the reminder ID from the interaction
-	id, err := framework.GetOption(commandOptions, "id")
-	if err != nil {
+	var args RemindStatusArgs
+	if err := ctx.Bind(&args); err != nil {
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
 				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "**Error:** id is required",
+				Content: "**Error:** " + err.Error(),
 			},
 		})
 		return
 	}
 
 	// Get the reminder status
-	timeLeft, err := Status(uint(id.IntValue()))
+	timeLeft, err := Status(ctx.Database(), ctx.GuildID(), uint(args.ID))
 	if err != nil {
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
 				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: fmt.Sprintf("**Error:** reminder `[%d]` not found", id.IntValue()),
+				Content: fmt.Sprintf("**Error:** reminder `[%d]` not found", args.ID),
 			},
 		})
 		return
@@ -58,7 +73,7 @@ func (c RemindStatusSubCommand) OnCommand(ctx framework.CommandContext) {
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
 			Flags:   discordgo.MessageFlagsEphemeral,
-			Content: fmt.Sprintf("Time left for `[%d]`: `%s`", id.IntValue(), timeLeft.String()),
+			Content: fmt.Sprintf("Time left for `[%d]`: `%s`", args.ID, timeLeft.String()),
 		},
 	})
 }
@@ -2,79 +2,50 @@ package remind
 
 import (
 	"fmt"
-	"time"
 
-	"github.com/bwmarrin/discordgo"
-	"gorm.io/gorm"
+	"github.com/aussiebroadwan/tony/framework"
 )
 
-var reminderScheduler = make(map[uint]Reminder)
-var reminderStop = false
+// remindJob is the name of the scheduled job which sends a reminder
+const remindJob = "remind"
 
-// Run periodically checks for due reminders and executes their actions
-// This function should be run in a goroutine
-func Run(db *gorm.DB, session *discordgo.Session) {
-	ticker := time.NewTicker(10 * time.Second) // Adjust the interval as needed
-	defer ticker.Stop()
-
-	// Run the reminder loop
-	for range ticker.C {
-		if reminderStop {
-			break
-		}
-
-		now := time.Now()
-		for _, r := range reminderScheduler {
-			// Check if the reminder is due
-			if r.TriggerTime.Before(now) {
-				r.Action(db, session)                     // Execute the reminder action
-				_ = DeleteReminder(db, r.ID, r.CreatedBy) // Remove the reminder
-			}
-		}
-	}
-
-	// Clear the reminder store
-	reminderScheduler = make(map[uint]Reminder)
-	reminderStop = false
+// reminderJob is the payload of the job which sends a reminder
+type reminderJob struct {
+	ID uint
 }
 
-// Add creates a new reminder and returns its ID
-func Add(id uint, r Reminder) uint {
-	reminderScheduler[id] = r
-	return id
+// reminderKey is the key of the job which sends the reminder
+func reminderKey(id uint) string {
+	return fmt.Sprintf("remind:%d", id)
 }
 
-// Delete removes a reminder by its ID
-func Delete(id uint, user string) error {
-	if _, ok := reminderScheduler[id]; !ok {
-		return fmt.Errorf("reminder with ID %d not found", id)
-	}
-
-	if reminderScheduler[id].CreatedBy != user {
-		return fmt.Errorf("reminder with ID %d does not belong to user %s", id, user)
-	}
-
-	delete(reminderScheduler, id)
-	return nil
+// scheduleReminder schedules the job which sends the reminder at its trigger
+// time
+func scheduleReminder(scheduler *framework.Scheduler, r Reminder) error {
+	_, err := scheduler.Schedule(framework.Job{
+		Name:    remindJob,
+		Key:     reminderKey(r.ID),
+		GuildID: r.GuildID,
+		Payload: reminderJob{ID: r.ID},
+		At:      r.TriggerTime,
+	})
+	return err
 }
 
-// List returns a slice of upcoming reminders.
-func List() []Reminder {
-	var upcoming []Reminder
-	now := time.Now()
-	for _, r := range reminderScheduler {
-		if r.TriggerTime.After(now) && !r.Reminded {
-			upcoming = append(upcoming, r)
-		}
+// sendReminder sends the reminder of the job. A reminder which was deleted or
+// already sent is skipped, so it is only sent again if marking it as sent
+// fails.
+func sendReminder(ctx framework.JobContext) error {
+	var job reminderJob
+	if err := ctx.Payload(&job); err != nil {
+		return err
 	}
-	return upcoming
-}
 
-// Status returns the time left for a reminder.
-func Status(id uint) (time.Duration, error) {
-	r, ok := reminderScheduler[id]
-	if !ok {
-		return 0, fmt.Errorf("reminder with ID %d not found", id)
+	reminder, err := getReminder(ctx.Database(), ctx.GuildID(), job.ID)
+	if err != nil {
+		ctx.Logger().WithError(err).Warn("Reminder no longer exists")
+		return nil
 	}
-	return time.Until(r.TriggerTime), nil
+
+	return reminder.Action(ctx.Database(), ctx.Session())
 }
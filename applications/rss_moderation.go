@@ -3,7 +3,9 @@ package applications
 import (
 	"errors"
 	"regexp"
+	"sync/atomic"
 
+	"github.com/aussiebroadwan/tony/config"
 	"github.com/aussiebroadwan/tony/framework"
 	"github.com/bwmarrin/discordgo"
 )
@@ -33,9 +35,19 @@ func RegisterRSSModeration(bot *framework.Bot) framework.Route {
 //
 // If the message does not match the format, the bot will delete the message and
 // send a message to the user to let them know that the message was deleted and
-// why.
+// why. Edited messages are checked again.
 type ModerateRSSRule struct {
 	framework.ApplicationMessage
+	framework.ApplicationMessageUpdate
+	framework.ApplicationConfig
+}
+
+// rssChannel is the name of the moderated channel, set from the config by
+// OnConfig
+var rssChannel atomic.Value
+
+func init() {
+	rssChannel.Store("rss")
 }
 
 var (
@@ -44,13 +56,35 @@ var (
 )
 
 func (r ModerateRSSRule) GetType() framework.AppType {
-	return framework.AppTypeMessage
+	return framework.AppTypeMessage | framework.AppTypeMessageUpdate | framework.AppTypeConfig
+}
+
+// OnConfig sets which channel is moderated
+func (r ModerateRSSRule) OnConfig(ctx framework.ConfigContext) {
+	var cfg config.Config
+	if err := ctx.Config(&cfg); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to read config")
+		return
+	}
+
+	rssChannel.Store(cfg.Moderation.RSSChannel)
 }
 
 func (r ModerateRSSRule) OnMessage(ctx framework.MessageContext, channel *discordgo.Channel) {
+	r.moderate(ctx, channel)
+}
+
+func (r ModerateRSSRule) OnMessageUpdate(ctx framework.MessageUpdateContext, channel *discordgo.Channel) {
+	if contentEdited(ctx) {
+		r.moderate(ctx, channel)
+	}
+}
+
+// Deletes the message if it is in the #rss channel and breaks the rule
+func (r ModerateRSSRule) moderate(ctx framework.MessageContext, channel *discordgo.Channel) {
 
 	// Check if the message is in the correct channel
-	if channel.Name != "rss" {
+	if channel.Name != rssChannel.Load().(string) {
 		return
 	}
 
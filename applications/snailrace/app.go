@@ -1,6 +1,7 @@
 package snailrace_app
 
 import (
+	"github.com/aussiebroadwan/tony/config"
 	"github.com/aussiebroadwan/tony/framework"
 	"github.com/aussiebroadwan/tony/pkg/snailrace"
 	"github.com/bwmarrin/discordgo"
@@ -21,27 +22,44 @@ func RegisterSnailraceApp(bot *framework.Bot) framework.Route {
 type Snailrace struct {
 	framework.ApplicationCommand
 	framework.ApplicationMountable
+	framework.ApplicationShutdown
+	framework.ApplicationConfig
 }
 
 func (s Snailrace) GetType() framework.AppType {
-	return framework.AppTypeCommand | framework.AppTypeMountable
+	return framework.AppTypeCommand | framework.AppTypeMountable | framework.AppTypeShutdown | framework.AppTypeConfig
 }
 
 func (s Snailrace) OnMount(ctx framework.MountContext) {
 	snailrace.SetupSnailraceDB(ctx.Database())
 }
 
+// OnShutdown waits for the running races to finish, or cancels them and
+// refunds their bets if they take too long
+func (s Snailrace) OnShutdown(ctx framework.ShutdownContext) {
+	if err := snailrace.Shutdown(ctx.Context()); err != nil {
+		ctx.Logger().WithError(err).Warn("Cancelled the running races and refunded their bets")
+	}
+}
+
+// OnConfig sets how long snails can join a race, which applies to the next
+// race hosted
+func (s Snailrace) OnConfig(ctx framework.ConfigContext) {
+	var cfg config.Config
+	if err := ctx.Config(&cfg); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to read config")
+		return
+	}
+
+	snailrace.SetJoinDelay(cfg.Snailrace.JoinDelay)
+}
+
+// GetDefinition describes the snailrace command, the subcommand options are
+// generated from the subroutes.
 func (s Snailrace) GetDefinition() *discordgo.ApplicationCommand {
 	return &discordgo.ApplicationCommand{
 		Name:        "snailrace",
 		Description: "Let's race snails!",
-		Options: []*discordgo.ApplicationCommandOption{
-			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Name:        "host",
-				Description: "Host a snailrace",
-			},
-		},
 	}
 }
 
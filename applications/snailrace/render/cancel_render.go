@@ -5,9 +5,26 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
-func cancelledMessage(state snailrace.RaceState) (string, []discordgo.MessageComponent) {
+func cancelledMessage(state snailrace.RaceState, refundUser func(string, string, int64)) (string, []discordgo.MessageComponent) {
 
 	description := "Race has been cancelled due to not enough players.\n"
+	if state.Interrupted {
+		description = "Race has been cancelled as Tony is restarting, bets have been refunded.\n"
+	}
+
+	// Refund the bets placed on the race, adding up each user's bets so they
+	// are refunded once
+	refunds := map[string]int64{}
+	users := []string{}
+	for _, userBet := range state.Race.UserBets {
+		if _, ok := refunds[userBet.UserId]; !ok {
+			users = append(users, userBet.UserId)
+		}
+		refunds[userBet.UserId] += userBet.Amount
+	}
+	for _, userId := range users {
+		refundUser(state.Race.Id, userId, refunds[userId])
+	}
 
 	return description, []discordgo.MessageComponent{
 		discordgo.Button{
@@ -7,7 +7,7 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
-func finishedMessage(state snailrace.RaceState, creditUser func(string, int64)) (string, []discordgo.MessageComponent) {
+func finishedMessage(state snailrace.RaceState, creditUser func(string, string, int64)) (string, []discordgo.MessageComponent) {
 
 	description := fmt.Sprintf("```\nRace ID: %s\n\n%s\n", state.Race.Id, buildTrack(state))
 	entrants := "Results:\n"
@@ -29,16 +29,25 @@ func finishedMessage(state snailrace.RaceState, creditUser func(string, int64))
 	}
 	description += entrants + "```"
 
-	// Payout the winners
+	// Payout the winners, adding up the winnings of each user's bets so they
+	// are paid once
+	winnings := map[string]int64{}
+	winners := []string{}
 	for _, userBet := range state.Race.UserBets {
 		if place, ok := state.Place[userBet.SnailIndex]; ok {
 			if place == 1 {
 				odds := snailrace.CalculateOdds(state.Race.Pool, state.Race.Snails[userBet.SnailIndex].Pool)
 				win := int64(float64(userBet.Amount) * odds)
-				creditUser(userBet.UserId, win)
+				if _, ok := winnings[userBet.UserId]; !ok {
+					winners = append(winners, userBet.UserId)
+				}
+				winnings[userBet.UserId] += win
 			}
 		}
 	}
+	for _, userId := range winners {
+		creditUser(state.Race.Id, userId, winnings[userId])
+	}
 
 	return description, []discordgo.MessageComponent{
 		discordgo.Button{
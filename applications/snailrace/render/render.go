@@ -33,13 +33,23 @@ func StateRenderer(ctx framework.CommandContext) (snailrace.StateChangeCallback,
 		return nil, nil, "", ""
 	}
 
-	creditUser := func(userId string, amount int64) {
-		if err := wallet.Credit(database, userId, amount, "Snailrace returns", "snailrace"); err != nil {
+	// A race only pays out or refunds each user once, even if its state is
+	// rendered again
+	creditUser := func(raceId, userId string, amount int64) {
+		key := wallet.IdempotencyKey(fmt.Sprintf("snailrace:%s:%s", raceId, userId))
+		if err := wallet.Credit(database, ctx.GuildID(), userId, amount, "Snailrace returns", "snailrace", key); err != nil {
 			ctx.Logger().WithError(err).Error("Failed to credit user")
 		}
 	}
 
-	return createGameStateRenderFunc(ctx, session, creditUser), onAchievement(ctx), msg.ID, interaction.ChannelID
+	refundUser := func(raceId, userId string, amount int64) {
+		key := wallet.IdempotencyKey(fmt.Sprintf("snailrace:%s:%s", raceId, userId))
+		if err := wallet.Credit(database, ctx.GuildID(), userId, amount, "Snailrace refund", "snailrace", key); err != nil {
+			ctx.Logger().WithError(err).Error("Failed to refund user")
+		}
+	}
+
+	return createGameStateRenderFunc(ctx, session, creditUser, refundUser), onAchievement(ctx), msg.ID, interaction.ChannelID
 }
 
 // onAchievement creates a function to handle achievement unlocks. It will
@@ -54,7 +64,7 @@ func onAchievement(ctx framework.CommandContext) snailrace.AchievementCallback {
 }
 
 // createGameStateRenderFunc creates a function to render the game state based on the current stage.
-func createGameStateRenderFunc(ctx framework.CommandContext, session *discordgo.Session, creditUser func(string, int64)) snailrace.StateChangeCallback {
+func createGameStateRenderFunc(ctx framework.CommandContext, session framework.Session, creditUser, refundUser func(string, string, int64)) snailrace.StateChangeCallback {
 	return func(raceState snailrace.RaceState, messageId, channelId string) {
 		ctx.Logger().WithFields(logrus.Fields{
 			"state":   raceState.State,
@@ -75,8 +85,17 @@ func createGameStateRenderFunc(ctx framework.CommandContext, session *discordgo.
 			description, components = raceMessage(raceState)
 		case snailrace.StateFinished:
 			description, components = finishedMessage(raceState, creditUser)
+			ctx.Publish(snailrace.RaceFinished{
+				GuildId:   ctx.GuildID(),
+				ChannelId: channelId,
+				MessageId: messageId,
+				RaceId:    raceState.Race.Id,
+				Snails:    raceState.Snails,
+				Place:     raceState.Place,
+				UserBets:  raceState.Race.UserBets,
+			})
 		case snailrace.StateCancelled:
-			description, components = cancelledMessage(raceState)
+			description, components = cancelledMessage(raceState, refundUser)
 		default:
 			session.ChannelMessageEdit(channelId, messageId, preparingGameMessage)
 			return
@@ -91,7 +110,7 @@ func createGameStateRenderFunc(ctx framework.CommandContext, session *discordgo.
 }
 
 // renderState updates the game message with new state information and interaction components.
-func renderState(session *discordgo.Session, channelId, messageId, title, description string, components []discordgo.MessageComponent) error {
+func renderState(session framework.Session, channelId, messageId, title, description string, components []discordgo.MessageComponent) error {
 	edit := discordgo.NewMessageEdit(channelId, messageId)
 	emptyString := ""
 	edit.Content = &emptyString
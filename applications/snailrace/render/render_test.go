@@ -0,0 +1,93 @@
+package render
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/aussiebroadwan/tony/pkg/snailrace"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	exampleUserId = "1060681976622891089"
+	otherUserId   = "169015299834642432"
+)
+
+// raceState builds a finished race where the first snail won and the second
+// came second, with the bets placed on them
+func raceState(interrupted bool, bets ...snailrace.UserBet) snailrace.RaceState {
+	return snailrace.RaceState{
+		Race: &snailrace.Race{
+			Id:       "race",
+			Pool:     100,
+			Snails:   []snailrace.SnailRaceLink{{Pool: 50}, {Pool: 50}},
+			UserBets: bets,
+		},
+		Snails:      []*snailrace.Snail{{Name: "Speedy"}, {Name: "Slowpoke"}},
+		Place:       map[int]int{0: 1, 1: 2},
+		Interrupted: interrupted,
+	}
+}
+
+func TestRenderPayments(t *testing.T) {
+	tests := []struct {
+		name        string
+		render      func(snailrace.RaceState, func(string, string, int64)) (string, []discordgo.MessageComponent)
+		state       snailrace.RaceState
+		description string
+		payments    map[string]int64
+	}{
+		{
+			name:        "winning bets are added up",
+			render:      finishedMessage,
+			state:       raceState(false, snailrace.UserBet{UserId: exampleUserId, Amount: 10}, snailrace.UserBet{UserId: exampleUserId, Amount: 20}, snailrace.UserBet{UserId: otherUserId, Amount: 5, SnailIndex: 1}),
+			description: "[0]: Speedy 🥇",
+			payments:    map[string]int64{exampleUserId: 60},
+		},
+		{
+			name:        "losing bets are not paid",
+			render:      finishedMessage,
+			state:       raceState(false, snailrace.UserBet{UserId: otherUserId, Amount: 5, SnailIndex: 1}),
+			description: "[1]: Slowpoke 🥈",
+			payments:    map[string]int64{},
+		},
+		{
+			name:        "cancelled bets are refunded",
+			render:      cancelledMessage,
+			state:       raceState(false, snailrace.UserBet{UserId: exampleUserId, Amount: 10}, snailrace.UserBet{UserId: exampleUserId, Amount: 20, SnailIndex: 1}, snailrace.UserBet{UserId: otherUserId, Amount: 5}),
+			description: "not enough players",
+			payments:    map[string]int64{exampleUserId: 30, otherUserId: 5},
+		},
+		{
+			name:        "interrupted bets are refunded",
+			render:      cancelledMessage,
+			state:       raceState(true, snailrace.UserBet{UserId: otherUserId, Amount: 5}),
+			description: "Tony is restarting",
+			payments:    map[string]int64{otherUserId: 5},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payments := map[string]int64{}
+			pay := func(raceId, userId string, amount int64) {
+				if _, ok := payments[userId]; ok {
+					t.Errorf("Expected %s to be paid once", userId)
+				}
+				payments[userId] = amount
+			}
+
+			description, components := test.render(test.state, pay)
+			if !strings.Contains(description, test.description) {
+				t.Errorf("Expected the description to contain %q, got %q", test.description, description)
+			}
+			if len(components) != 1 || !components[0].(discordgo.Button).Disabled {
+				t.Errorf("Expected a disabled button, got %+v", components)
+			}
+			if !maps.Equal(payments, test.payments) {
+				t.Errorf("Expected payments %v, got %v", test.payments, payments)
+			}
+		})
+	}
+}
@@ -1,9 +1,9 @@
 package snailrace_app
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aussiebroadwan/tony/framework"
 	"github.com/aussiebroadwan/tony/pkg/snailrace"
@@ -11,6 +11,15 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
+// betModalTTL is how long the user has to enter the amount of their bet
+const betModalTTL = 5 * time.Minute
+
+// betState is the state of the bet modal, the race and snail being bet on
+type betState struct {
+	RaceID     string
+	SnailIndex int
+}
+
 type SnailraceBetSubCommand struct {
 	framework.ApplicationEvent
 }
@@ -24,7 +33,19 @@ func (c SnailraceBetSubCommand) OnEvent(ctx framework.EventContext, eventType di
 
 	switch values[0] {
 	case "win":
-		handleWinBet(ctx, values[1], values[2])
+		var state betState
+		if err := ctx.State(&state); err != nil {
+			ctx.Logger().WithError(err).Error("Failed to get bet state")
+			ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Flags:   discordgo.MessageFlagsEphemeral,
+					Content: "**Error**: " + err.Error(),
+				},
+			})
+			return
+		}
+		handleWinBet(ctx, state.RaceID, state.SnailIndex)
 	case "win_request":
 		handleWinRequest(ctx, values[1])
 	default:
@@ -34,13 +55,24 @@ func (c SnailraceBetSubCommand) OnEvent(ctx framework.EventContext, eventType di
 
 func handleWinRequest(ctx framework.EventContext, raceID string) {
 	data := ctx.Interaction().MessageComponentData()
-	snailIndex := data.Values[0]
+	snailIndex, err := strconv.Atoi(data.Values[0])
+	if err != nil {
+		ctx.Logger().WithError(err).Error("Invalid snail index")
+		return
+	}
+
+	// The race and snail are kept with the modal so they can't be changed
+	customId, err := ctx.NewCustomID("snailrace.bet:win", betState{RaceID: raceID, SnailIndex: snailIndex}, betModalTTL)
+	if err != nil {
+		ctx.Logger().WithError(err).Error("Failed to create bet modal")
+		return
+	}
 
 	// You can react to button presses with no data and it doesn't error or send a message
-	err := ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+	err = ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseModal,
 		Data: &discordgo.InteractionResponseData{
-			CustomID: fmt.Sprintf("snailrace.bet:win:%s:%s", raceID, snailIndex),
+			CustomID: customId,
 			Title:    "Quickbet: How much would you like to bet?",
 			Components: []discordgo.MessageComponent{
 				discordgo.ActionsRow{
@@ -64,9 +96,8 @@ func handleWinRequest(ctx framework.EventContext, raceID string) {
 	}
 }
 
-func handleWinBet(ctx framework.EventContext, raceId string, snailIndex string) {
+func handleWinBet(ctx framework.EventContext, raceId string, snailIdx int) {
 	user := ctx.GetUser()
-	snailIdx, _ := strconv.Atoi(snailIndex)
 
 	// Fetch the user required data
 	data := ctx.Interaction().ModalSubmitData()
@@ -98,8 +129,9 @@ func handleWinBet(ctx framework.EventContext, raceId string, snailIndex string)
 		return
 	}
 
-	// Charge the user's balance
-	err = wallet.Debit(ctx.Database(), user.ID, int64(betInt), "Snailrace Quickbet", "snailrace")
+	// Charge the user's balance, once even if the interaction is retried
+	key := wallet.IdempotencyKey("snailrace:bet:" + ctx.Interaction().ID)
+	err = wallet.Debit(ctx.Database(), ctx.GuildID(), user.ID, int64(betInt), "Snailrace Quickbet", "snailrace", key)
 	if err != nil {
 		// You can react to button presses with no data and it doesn't error or send a message
 		ctx.Logger().WithError(err).Error("Failed to charge user")
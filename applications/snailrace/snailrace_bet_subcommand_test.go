@@ -0,0 +1,90 @@
+package snailrace_app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/aussiebroadwan/tony/framework/frameworktest"
+	"github.com/aussiebroadwan/tony/pkg/snailrace"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	exampleGuildId = "1229977032540573766"
+	exampleUserId  = "1060681976622891089"
+)
+
+// betInteraction builds an interaction from the user in the example server
+func betInteraction(interactionType discordgo.InteractionType, data discordgo.InteractionData) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        "interaction",
+		Type:      interactionType,
+		ChannelID: "channel",
+		GuildID:   exampleGuildId,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: exampleUserId, Username: "punter"}},
+		Data:      data,
+	}}
+}
+
+// betModal builds the submitted bet modal with the custom ID
+func betModal(customId string) *discordgo.InteractionCreate {
+	return betInteraction(discordgo.InteractionModalSubmit, discordgo.ModalSubmitInteractionData{
+		CustomID: customId,
+		Components: []discordgo.MessageComponent{
+			&discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				&discordgo.TextInput{CustomID: "bet", Value: "50"},
+			}},
+		},
+	})
+}
+
+func TestBetModalState(t *testing.T) {
+	db := dbtest.Open(t, framework.Migrations, snailrace.Migrations)
+	if err := snailrace.SetupSnailraceDB(db); err != nil {
+		t.Fatalf("Failed to set up snailrace: %v", err)
+	}
+
+	bot, err := framework.NewBot("token", []string{exampleGuildId}, db)
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+	bot.Register(RegisterSnailraceApp(bot))
+
+	// Choosing a snail shows the modal with the race and snail in its state
+	session := frameworktest.NewFakeSession()
+	bot.HandleInteraction(session, betInteraction(discordgo.InteractionMessageComponent, discordgo.MessageComponentInteractionData{
+		CustomID:      "snailrace.bet:win_request:race",
+		ComponentType: discordgo.SelectMenuComponent,
+		Values:        []string{"1"},
+	}))
+
+	modal := session.LastResponse()
+	if modal == nil || modal.Type != discordgo.InteractionResponseModal {
+		t.Fatalf("Expected the bet modal, got %+v", modal)
+	}
+	customId := modal.Data.CustomID
+	if !strings.HasPrefix(customId, "snailrace.bet:win:") || strings.Contains(customId, "race:1") {
+		t.Errorf("Expected the race and snail to be kept out of the custom ID, got %q", customId)
+	}
+
+	tests := []struct {
+		name     string
+		customId string
+		err      error
+	}{
+		{"signed", customId, snailrace.ErrRaceNotFound},
+		{"plain text", "snailrace.bet:win:race:1", framework.ErrNoComponentState},
+	}
+
+	for _, test := range tests {
+		session := frameworktest.NewFakeSession()
+		bot.HandleInteraction(session, betModal(test.customId))
+
+		response := session.LastResponse()
+		if response == nil || response.Data == nil || response.Data.Content != "**Error**: "+test.err.Error() {
+			t.Errorf("%s: expected %q, got %+v", test.name, test.err, response)
+		}
+	}
+}
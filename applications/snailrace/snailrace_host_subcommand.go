@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aussiebroadwan/tony/applications/snailrace/render"
 
@@ -24,15 +25,28 @@ func (c SnailraceHostSubCommand) GetType() framework.AppType {
 	return framework.AppTypeSubCommand | framework.AppTypeEvent
 }
 
+func (c SnailraceHostSubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "host",
+		Description: "Host a snailrace",
+	}
+}
+
 func (c SnailraceHostSubCommand) OnCommand(ctx framework.CommandContext) {
 
 	err := snailrace.HostRace(render.StateRenderer(ctx))
 	if err != nil {
+		reason := "Failed to host snailrace"
+		if err == snailrace.ErrShuttingDown {
+			reason = "Tony is restarting, try again shortly"
+		}
+
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
 				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: "Failed to host snailrace",
+				Content: reason,
 			},
 		})
 		return
@@ -68,7 +82,12 @@ func (c SnailraceHostSubCommand) OnEvent(ctx framework.EventContext, eventType d
 	case "join_request":
 		handleJoinRequest(ctx, values[1])
 	case "join_select":
-		handleJoin(ctx, values[1])
+		var state joinState
+		if err := ctx.State(&state); err != nil {
+			ctx.Logger().WithError(err).Error("Failed to get join state")
+			return
+		}
+		handleJoin(ctx, state.RaceID)
 	default:
 		ctx.Logger().Error("Invalid event key: " + values[0])
 	}
@@ -115,10 +134,18 @@ func OnNewSnail(ctx framework.EventContext) func(snailrace.Snail) {
 	}
 }
 
+// joinSelectTTL is how long the user has to pick a snail to join a race with
+const joinSelectTTL = 5 * time.Minute
+
+// joinState is the state of the snail select menu for joining a race
+type joinState struct {
+	RaceID string
+}
+
 func handleJoinRequest(ctx framework.EventContext, raceId string) {
 	user := ctx.GetUser()
 
-	snails, err := snailrace.GetSnails(user.ID, OnNewSnail(ctx))
+	snails, err := snailrace.GetSnails(ctx.GuildID(), user.ID, OnNewSnail(ctx))
 	if err != nil {
 		ctx.Logger().WithError(err).Error("Failed to get snails")
 		return
@@ -136,19 +163,25 @@ func handleJoinRequest(ctx framework.EventContext, raceId string) {
 		}
 	}
 
+	// The race to join is kept with the select menu
+	selectId, err := ctx.NewCustomID("snailrace.host:join_select", joinState{RaceID: raceId}, joinSelectTTL)
+	if err != nil {
+		ctx.Logger().WithError(err).Error("Failed to create join select")
+		return
+	}
+
 	// Handle join request
 	err = ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Flags:    discordgo.MessageFlagsEphemeral,
-			CustomID: "snailrace.host:join:" + raceId,
-			Title:    "Join Snailrace",
-			Content:  "Select a snail from your deck to join in the race",
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Title:   "Join Snailrace",
+			Content: "Select a snail from your deck to join in the race",
 			Components: []discordgo.MessageComponent{
 				discordgo.ActionsRow{
 					Components: []discordgo.MessageComponent{
 						discordgo.SelectMenu{
-							CustomID:    "snailrace.host:join_select:" + raceId,
+							CustomID:    selectId,
 							Placeholder: "Select a snail",
 							Options:     menuOptions,
 						},
@@ -166,7 +199,7 @@ func handleJoin(ctx framework.EventContext, raceId string) {
 	data := ctx.Interaction().MessageComponentData()
 	snail := data.Values[0]
 
-	err := snailrace.JoinRace(ctx.GetUser().ID, raceId, snail)
+	err := snailrace.JoinRace(ctx.GuildID(), ctx.GetUser().ID, raceId, snail)
 	if err != nil {
 		ctx.Logger().WithError(err).Errorf("User %s has failed to join race %s using snail %s", ctx.GetUser().Username, raceId, snail)
 		ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
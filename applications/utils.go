@@ -21,3 +21,10 @@ func sendOriginalMessageAsCodeblock(ctx framework.MessageContext, channelId, con
 	// Send a copy of the original content to the user
 	ctx.Session().ChannelMessageSend(channelId, content)
 }
+
+// contentEdited reports whether the content of an updated message changed, or
+// might have if the message was not cached
+func contentEdited(ctx framework.MessageUpdateContext) bool {
+	before := ctx.BeforeUpdate()
+	return before == nil || before.Content != ctx.Message().Content
+}
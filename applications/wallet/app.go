@@ -1,8 +1,12 @@
 package walletApp
 
 import (
+	"github.com/aussiebroadwan/tony/config"
 	"github.com/aussiebroadwan/tony/framework"
+	"github.com/aussiebroadwan/tony/pkg/wallet"
 	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
 )
 
 func RegisterWalletApp(bot *framework.Bot) framework.Route {
@@ -13,47 +17,45 @@ func RegisterWalletApp(bot *framework.Bot) framework.Route {
 		// Subcommands
 		framework.NewRoute(bot, "balance", &WalletBalanceSubCommand{}),
 		framework.NewRoute(bot, "pay", &WalletPaySubCommand{}),
+
+		// Only server admins can reconcile the wallets and reverse transactions
+		framework.NewRoute(bot, "reconcile", &WalletReconcileSubCommand{},
+			framework.RequirePermissions(discordgo.PermissionAdministrator),
+			framework.RequireGuild(),
+		),
+		framework.NewRoute(bot, "reverse", &WalletReverseSubCommand{},
+			framework.RequirePermissions(discordgo.PermissionAdministrator),
+			framework.RequireGuild(),
+		),
 	)
 }
 
 type WalletAppCommand struct {
 	framework.ApplicationMessage
+	framework.ApplicationConfig
 }
 
 func (c WalletAppCommand) GetType() framework.AppType {
-	return framework.AppTypeCommand
+	return framework.AppTypeCommand | framework.AppTypeConfig
 }
 
+// OnConfig sets the balance new users start with
+func (c WalletAppCommand) OnConfig(ctx framework.ConfigContext) {
+	var cfg config.Config
+	if err := ctx.Config(&cfg); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to read config")
+		return
+	}
+
+	wallet.SetDefaultBalance(cfg.Wallet.DefaultBalance)
+}
+
+// GetDefinition describes the wallet command, the subcommand options are
+// generated from the subroutes.
 func (c WalletAppCommand) GetDefinition() *discordgo.ApplicationCommand {
 	return &discordgo.ApplicationCommand{
 		Name:        "wallet",
 		Description: "Allows users to manage their wallet",
-		Options: []*discordgo.ApplicationCommandOption{
-			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Name:        "balance",
-				Description: "Check your wallet balance",
-			},
-			{
-				Type:        discordgo.ApplicationCommandOptionSubCommand,
-				Name:        "pay",
-				Description: "Pay another user",
-				Options: []*discordgo.ApplicationCommandOption{
-					{
-						Type:        discordgo.ApplicationCommandOptionUser,
-						Name:        "user",
-						Description: "The user to pay",
-						Required:    true,
-					},
-					{
-						Type:        discordgo.ApplicationCommandOptionInteger,
-						Name:        "amount",
-						Description: "The amount to pay",
-						Required:    true,
-					},
-				},
-			},
-		},
 	}
 }
 
@@ -61,8 +63,17 @@ func (c WalletAppCommand) OnCommand(ctx framework.CommandContext) {
 	// [NOP]
 }
 
+// interactionContext is the part of the command and event contexts needed to
+// respond to an interaction, so the responses can be shared by the slash and
+// context menu commands.
+type interactionContext interface {
+	Session() framework.Session
+	Interaction() *discordgo.Interaction
+	Logger() *log.Entry
+}
+
 // sendEmbedResponse sends an embedded message as a response to a Discord interaction.
-func sendEmbedResponse(ctx framework.CommandContext, embed *discordgo.MessageEmbed) {
+func sendEmbedResponse(ctx interactionContext, embed *discordgo.MessageEmbed) {
 	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -72,7 +83,7 @@ func sendEmbedResponse(ctx framework.CommandContext, embed *discordgo.MessageEmb
 }
 
 // sendErrorResponse sends an error message as an ephemeral response to a Discord interaction.
-func sendErrorResponse(ctx framework.CommandContext, message string) {
+func sendErrorResponse(ctx interactionContext, message string) {
 	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -83,7 +94,7 @@ func sendErrorResponse(ctx framework.CommandContext, message string) {
 }
 
 // sendSuccessResponse sends a success or informational message as an ephemeral response to a Discord interaction.
-func sendSuccessResponse(ctx framework.CommandContext, message string) {
+func sendSuccessResponse(ctx interactionContext, message string) {
 	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -16,13 +16,21 @@ func (c WalletBalanceSubCommand) GetType() framework.AppType {
 	return framework.AppTypeSubCommand
 }
 
+func (c WalletBalanceSubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "balance",
+		Description: "Check your wallet balance",
+	}
+}
+
 func (c WalletBalanceSubCommand) OnCommand(ctx framework.CommandContext) {
-	db := ctx.Database()
+	db := ctx.Database().WithContext(ctx.Context())
 
 	user := ctx.GetUser()
 
 	// Get the user's balance
-	balance, err := wallet.Balance(db, user.ID)
+	balance, err := wallet.Balance(db, ctx.GuildID(), user.ID)
 	if err != nil {
 		ctx.Logger().Errorf("Failed to get balance: %v", err)
 		sendErrorResponse(ctx, "**Error:** Failed to get balance")
@@ -30,7 +38,7 @@ func (c WalletBalanceSubCommand) OnCommand(ctx framework.CommandContext) {
 	}
 
 	// Get last 5 transactions
-	transactions, err := wallet.History(db, user.ID, 5)
+	transactions, err := wallet.History(db, ctx.GuildID(), user.ID, 5)
 	if err != nil {
 		ctx.Logger().Errorf("Failed to get transaction history: %v", err)
 		sendErrorResponse(ctx, "**Error:** Failed to get transaction history")
@@ -63,23 +71,23 @@ func createWalletBalanceEmbed(balance int64, transactions []wallet.Transaction)
 }
 
 // formatTransactions formats a list of transactions into a human-readable
-// string. Example:
+// string, with their IDs for admins to reverse them by. Example:
 //
 // ```
 //
-//	 -5 | Payment to uqcs-tony
-//	-30 | Payment to uqcs-tony
-//	 30 | Payment from lcox74
-//	-30 | Payment to lcox74
-//	-10 | Payment to uqcs-tony
+//	#41    -5 | Payment to uqcs-tony
+//	#38   -30 | Payment to uqcs-tony
+//	#35    30 | Payment from lcox74
+//	#31   -30 | Payment to lcox74
+//	#27   -10 | Payment to uqcs-tony
 //
 // ```
 func formatTransactions(transactions []wallet.Transaction) string {
 	body := ""
 	for _, transaction := range transactions {
-		description := wordWrap(transaction.Description, 32, "      | ")
+		description := wordWrap(transaction.Description, 32, "             | ")
 		amount := formatAmount(transaction.Amount, transaction.Type)
-		body += fmt.Sprintf("%5s | %s\n", amount, description)
+		body += fmt.Sprintf("%6s %5s | %s\n", fmt.Sprintf("#%d", transaction.ID), amount, description)
 	}
 	return body
 }
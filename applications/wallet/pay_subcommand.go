@@ -17,20 +17,39 @@ func (c WalletPaySubCommand) GetType() framework.AppType {
 	return framework.AppTypeSubCommand
 }
 
+// WalletPayArgs are the options of "/wallet pay"
+type WalletPayArgs struct {
+	User   *discordgo.User `option:"user,required" description:"The user to pay"`
+	Amount int64           `option:"amount,required,min=1" description:"The amount to pay"`
+}
+
+func (c WalletPaySubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "pay",
+		Description: "Pay another user",
+		Options:     framework.CommandOptions(WalletPayArgs{}),
+	}
+}
+
 func (c WalletPaySubCommand) OnCommand(ctx framework.CommandContext) {
 	session := ctx.Session()
-	db := ctx.Database()
+	db := ctx.Database().WithContext(ctx.Context())
 
-	user := ctx.GetUser()
-	targetUser := ctx.GetOption("user").UserValue(session)
-	amount := int64(ctx.GetOption("amount").IntValue())
-
-	if !validateAmount(ctx, amount) {
-		ctx.Logger().Error("Invalid amount")
+	var args WalletPayArgs
+	if err := ctx.Bind(&args); err != nil {
+		ctx.Logger().WithError(err).Error("Invalid options")
+		sendErrorResponse(ctx, "**Error:** "+err.Error())
 		return
 	}
 
-	if err := processPayment(db, user, targetUser, amount); err != nil {
+	user := ctx.GetUser()
+	targetUser, amount := args.User, args.Amount
+	if u, err := session.User(targetUser.ID); err == nil {
+		targetUser = u
+	}
+
+	if err := processPayment(db, ctx.GuildID(), ctx.Interaction().ID, user, targetUser, amount); err != nil {
 		ctx.Logger().Errorf("Failed to process payment: %v", err)
 		sendErrorResponse(ctx, "**Error:** "+err.Error())
 		return
@@ -41,7 +60,7 @@ func (c WalletPaySubCommand) OnCommand(ctx framework.CommandContext) {
 }
 
 // validateAmount checks if the provided amount is a valid transaction amount.
-func validateAmount(ctx framework.CommandContext, amount int64) bool {
+func validateAmount(ctx interactionContext, amount int64) bool {
 	if amount <= 0 {
 		sendErrorResponse(ctx, "**Error:** Amount must be greater than 0")
 		return false
@@ -50,14 +69,16 @@ func validateAmount(ctx framework.CommandContext, amount int64) bool {
 }
 
 // processPayment handles the transaction logic, including database operations
-// to transfer funds.
-func processPayment(db *gorm.DB, user *discordgo.User, targetUser *discordgo.User, amount int64) error {
-	err := wallet.Trasfer(db,
+// to transfer funds. The payment is only made once for the interaction, even
+// if it is retried.
+func processPayment(db *gorm.DB, guildId, interactionId string, user *discordgo.User, targetUser *discordgo.User, amount int64) error {
+	err := wallet.Trasfer(db, guildId,
 		user.ID, targetUser.ID,
 		amount,
 		fmt.Sprintf("Payment to %s", targetUser.Username),
 		fmt.Sprintf("Payment from %s", user.Username),
 		"wallet.pay",
+		wallet.IdempotencyKey("wallet.pay:"+interactionId),
 	)
 
 	if err != nil {
@@ -68,7 +89,7 @@ func processPayment(db *gorm.DB, user *discordgo.User, targetUser *discordgo.Use
 
 // notifyTargetUser sends a direct message to the recipient to notify them of
 // the received payment.
-func notifyTargetUser(ctx framework.CommandContext, targetUser *discordgo.User, amount int64, sender *discordgo.User) {
+func notifyTargetUser(ctx interactionContext, targetUser *discordgo.User, amount int64, sender *discordgo.User) {
 	dmChannel, err := ctx.Session().UserChannelCreate(targetUser.ID)
 	if err != nil {
 		ctx.Logger().Errorf("Failed to create DM channel with user %s", targetUser.ID)
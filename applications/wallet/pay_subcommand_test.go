@@ -0,0 +1,112 @@
+package walletApp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/aussiebroadwan/tony/framework/frameworktest"
+	"github.com/aussiebroadwan/tony/pkg/wallet"
+	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const ExampleUserId1 = "1060681976622891089"
+const ExampleUserId2 = "169015299834642432"
+const ExampleGuildId = "1229977032540573766"
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db := dbtest.Open(t, wallet.Migrations)
+	wallet.SetupWalletDB(db, log.WithField("src", "wallet"))
+	return db
+}
+
+// payInteraction builds the interaction for "/wallet pay user:<to> amount:<amount>"
+func payInteraction(from, to string, amount int64) *discordgo.Interaction {
+	return &discordgo.Interaction{
+		ID:        "interaction",
+		Type:      discordgo.InteractionApplicationCommand,
+		ChannelID: "channel",
+		GuildID:   ExampleGuildId,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: from, Username: "from"}},
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name: "wallet",
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{
+					Name: "pay",
+					Type: discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{
+						{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: to},
+						{Name: "amount", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(amount)},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestWalletPaySubCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      int64
+		response    string
+		fromBalance int64
+		toBalance   int64
+	}{
+		{"successful payment", 50, "Payment successful", wallet.DefaultBalance - 50, wallet.DefaultBalance + 50},
+		{"zero amount", 0, "**Error:** amount must be at least 1", wallet.DefaultBalance, wallet.DefaultBalance},
+		{"insufficient balance", wallet.DefaultBalance + 1, "**Error:** failed to process payment", wallet.DefaultBalance, wallet.DefaultBalance},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := setupTestDB(t)
+
+			session := frameworktest.NewFakeSession()
+			session.Users[ExampleUserId2] = &discordgo.User{ID: ExampleUserId2, Username: "to"}
+
+			ctx, err := framework.NewInteractionContext(session, db, log.WithField("src", "test"), payInteraction(ExampleUserId1, ExampleUserId2, test.amount))
+			if err != nil {
+				t.Fatalf("Failed to create context: %v", err)
+			}
+
+			WalletPaySubCommand{}.OnCommand(ctx)
+
+			response := session.LastResponse()
+			if response == nil || !strings.HasPrefix(response.Data.Content, test.response) {
+				t.Errorf("Expected response %q, got %+v", test.response, response)
+			}
+
+			if balance, _ := wallet.Balance(db, ExampleGuildId, ExampleUserId1); balance != test.fromBalance {
+				t.Errorf("Expected sender balance %d, got %d", test.fromBalance, balance)
+			}
+
+			if balance, _ := wallet.Balance(db, ExampleGuildId, ExampleUserId2); balance != test.toBalance {
+				t.Errorf("Expected recipient balance %d, got %d", test.toBalance, balance)
+			}
+		})
+	}
+}
+
+func TestWalletPayRetried(t *testing.T) {
+	db := setupTestDB(t)
+
+	// Discord retrying the interaction sends the same one again
+	interaction := payInteraction(ExampleUserId1, ExampleUserId2, 50)
+	for i := 0; i < 2; i++ {
+		session := frameworktest.NewFakeSession()
+		ctx, err := framework.NewInteractionContext(session, db, log.WithField("src", "test"), interaction)
+		if err != nil {
+			t.Fatalf("Failed to create context: %v", err)
+		}
+
+		WalletPaySubCommand{}.OnCommand(ctx)
+	}
+
+	if balance, _ := wallet.Balance(db, ExampleGuildId, ExampleUserId1); balance != wallet.DefaultBalance-50 {
+		t.Errorf("Expected the payment to be made once, got sender balance %d", balance)
+	}
+}
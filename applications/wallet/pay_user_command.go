@@ -0,0 +1,153 @@
+package walletApp
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/bwmarrin/discordgo"
+)
+
+const payUserCommandName = "Pay this user"
+
+// payUserTTL is how long the user has to fill in the amount to pay
+const payUserTTL = 15 * time.Minute
+
+// payUserState is the state of the amount modal
+type payUserState struct {
+	TargetID string
+}
+
+func RegisterWalletUserApp(bot *framework.Bot) framework.Route {
+	return framework.NewRoute(bot, payUserCommandName, &WalletPayUserCommand{})
+}
+
+// WalletPayUserCommand is the user context menu command for paying another
+// user. The amount is asked for in a modal and then paid the same way as
+// /wallet pay.
+//
+//	Right click a user > Apps > Pay this user
+type WalletPayUserCommand struct {
+	framework.ApplicationCommand
+	framework.ApplicationEvent
+}
+
+func (c WalletPayUserCommand) GetType() framework.AppType {
+	return framework.AppTypeCommand | framework.AppTypeEvent
+}
+
+func (c WalletPayUserCommand) GetDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name: payUserCommandName,
+		Type: discordgo.UserApplicationCommand,
+	}
+}
+
+func (c WalletPayUserCommand) OnCommand(ctx framework.CommandContext) {
+	targetUser := ctx.TargetUser()
+	if targetUser == nil {
+		ctx.Logger().Error("No target user for pay user command")
+		return
+	}
+
+	// The user being paid is kept with the modal so it can't be changed
+	customId, err := ctx.NewCustomID(payUserCommandName, payUserState{TargetID: targetUser.ID}, payUserTTL)
+	if err != nil {
+		ctx.Logger().WithError(err).Error("Failed to create pay modal")
+		return
+	}
+
+	// Ask the user how much they want to pay
+	err = ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: customId,
+			Title:    fmt.Sprintf("Pay %s", targetUser.Username),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "amount",
+							Label:       "Amount",
+							Style:       discordgo.TextInputShort,
+							Placeholder: "eg. 15",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		ctx.Logger().WithError(err).Error("Failed to respond to interaction")
+	}
+}
+
+func (c WalletPayUserCommand) OnEvent(ctx framework.EventContext, eventType discordgo.InteractionType) {
+	if eventType != discordgo.InteractionModalSubmit {
+		ctx.Logger().Error("Invalid event type")
+		return
+	}
+
+	var state payUserState
+	if err := ctx.State(&state); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to get pay state")
+		sendErrorResponse(ctx, "**Error:** "+err.Error())
+		return
+	}
+
+	user := ctx.GetUser()
+	targetUser, err := ctx.Session().User(state.TargetID)
+	if err != nil {
+		ctx.Logger().WithError(err).Error("Failed to get target user")
+		sendErrorResponse(ctx, "**Error:** User not found")
+		return
+	}
+
+	value, ok := amountValue(ctx.Interaction().ModalSubmitData())
+	if !ok {
+		ctx.Logger().Error("Pay modal is missing the amount")
+		sendErrorResponse(ctx, "**Error:** Amount not found")
+		return
+	}
+
+	amount, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		sendErrorResponse(ctx, "**Error:** Amount must be a number")
+		return
+	}
+
+	if !validateAmount(ctx, amount) {
+		ctx.Logger().Error("Invalid amount")
+		return
+	}
+
+	if err := processPayment(ctx.Database(), ctx.GuildID(), ctx.Interaction().ID, user, targetUser, amount); err != nil {
+		ctx.Logger().Errorf("Failed to process payment: %v", err)
+		sendErrorResponse(ctx, "**Error:** "+err.Error())
+		return
+	}
+
+	sendSuccessResponse(ctx, "Payment successful")
+	notifyTargetUser(ctx, targetUser, amount, user)
+}
+
+// amountValue returns the amount entered in the submitted modal, or false if
+// the modal doesn't have it.
+func amountValue(data discordgo.ModalSubmitInteractionData) (string, bool) {
+	if len(data.Components) == 0 {
+		return "", false
+	}
+
+	row, ok := data.Components[0].(*discordgo.ActionsRow)
+	if !ok || len(row.Components) == 0 {
+		return "", false
+	}
+
+	input, ok := row.Components[0].(*discordgo.TextInput)
+	if !ok {
+		return "", false
+	}
+	return input.Value, true
+}
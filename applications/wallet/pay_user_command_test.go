@@ -0,0 +1,33 @@
+package walletApp
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestAmountValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []discordgo.MessageComponent
+		value      string
+		ok         bool
+	}{
+		{"amount", []discordgo.MessageComponent{
+			&discordgo.ActionsRow{Components: []discordgo.MessageComponent{&discordgo.TextInput{CustomID: "amount", Value: "15"}}},
+		}, "15", true},
+		{"no rows", nil, "", false},
+		{"empty row", []discordgo.MessageComponent{&discordgo.ActionsRow{}}, "", false},
+		{"not a row", []discordgo.MessageComponent{&discordgo.TextInput{Value: "15"}}, "", false},
+		{"not a text input", []discordgo.MessageComponent{
+			&discordgo.ActionsRow{Components: []discordgo.MessageComponent{&discordgo.Button{CustomID: "amount"}}},
+		}, "", false},
+	}
+
+	for _, test := range tests {
+		value, ok := amountValue(discordgo.ModalSubmitInteractionData{Components: test.components})
+		if value != test.value || ok != test.ok {
+			t.Errorf("%s: expected %q, %v, got %q, %v", test.name, test.value, test.ok, value, ok)
+		}
+	}
+}
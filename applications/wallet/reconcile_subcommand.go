@@ -0,0 +1,90 @@
+package walletApp
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/aussiebroadwan/tony/pkg/wallet"
+	"github.com/bwmarrin/discordgo"
+)
+
+// WalletReconcileSubCommand lets server admins check the wallets in the
+// server against the ledger.
+//
+//	/wallet reconcile
+type WalletReconcileSubCommand struct {
+	framework.ApplicationSubCommand
+}
+
+func (c WalletReconcileSubCommand) GetType() framework.AppType {
+	return framework.AppTypeSubCommand
+}
+
+func (c WalletReconcileSubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "reconcile",
+		Description: "Check the wallet balances against the ledger (admin only)",
+	}
+}
+
+func (c WalletReconcileSubCommand) OnCommand(ctx framework.CommandContext) {
+	db := ctx.Database().WithContext(ctx.Context())
+
+	report, err := wallet.Reconcile(db, ctx.GuildID())
+	if err != nil {
+		ctx.Logger().WithError(err).Error("Failed to reconcile wallets")
+		sendErrorResponse(ctx, "**Error:** Failed to reconcile wallets")
+		return
+	}
+
+	sendSuccessResponse(ctx, formatReconciliation(report))
+}
+
+// formatReconciliation formats the report of a reconciliation, with the
+// house account balances and anything which doesn't match. Example:
+//
+//	Checked 12 wallets, the ledger doesn't match:
+//	```
+//	house:blackjack       -1200
+//	house:mint            -6000
+//	```
+//	<@1060681976622891089> balance 900, ledger 500
+func formatReconciliation(report wallet.Reconciliation) string {
+	summary := fmt.Sprintf("Checked %d wallets, they all match the ledger.", report.Wallets)
+	if !report.Balanced() {
+		summary = fmt.Sprintf("Checked %d wallets, the ledger doesn't match:", report.Wallets)
+	}
+
+	accounts := make([]string, 0, len(report.House))
+	for account := range report.House {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	body := ""
+	for _, account := range accounts {
+		body += fmt.Sprintf("%-18s %8d\n", account, report.House[account])
+	}
+
+	mismatches := ""
+	for _, mismatch := range report.Mismatches {
+		mismatches += fmt.Sprintf("<@%s> balance %d, ledger %d\n", mismatch.UserId, mismatch.Balance, mismatch.Ledger)
+	}
+	if len(report.UnbalancedEntries) > 0 {
+		mismatches += fmt.Sprintf("Unbalanced ledger entries: %v\n", report.UnbalancedEntries)
+	}
+	if report.Unposted > 0 {
+		mismatches += fmt.Sprintf("%d transactions aren't in the ledger\n", report.Unposted)
+	}
+
+	message := summary
+	if body != "" {
+		message += "\n```\n" + body + "```"
+	}
+	if mismatches != "" {
+		message += "\n" + mismatches
+	}
+	return message
+}
@@ -0,0 +1,57 @@
+package walletApp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/aussiebroadwan/tony/framework/frameworktest"
+	"github.com/aussiebroadwan/tony/pkg/wallet"
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestWalletReconcileSubCommand(t *testing.T) {
+	db := setupTestDB(t)
+
+	wallet.Credit(db, ExampleGuildId, ExampleUserId1, 100, "Blackjack returns", "blackjack")
+	wallet.Balance(db, ExampleGuildId, ExampleUserId2)
+
+	interaction := payInteraction(ExampleUserId1, ExampleUserId2, 0)
+	interaction.Data = discordgo.ApplicationCommandInteractionData{
+		Name: "wallet",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{Name: "reconcile", Type: discordgo.ApplicationCommandOptionSubCommand},
+		},
+	}
+
+	reconcile := func() string {
+		session := frameworktest.NewFakeSession()
+		ctx, err := framework.NewInteractionContext(session, db, log.WithField("src", "test"), interaction)
+		if err != nil {
+			t.Fatalf("Failed to create context: %v", err)
+		}
+
+		WalletReconcileSubCommand{}.OnCommand(ctx)
+
+		response := session.LastResponse()
+		if response == nil {
+			t.Fatalf("Expected a response")
+		}
+		return response.Data.Content
+	}
+
+	content := reconcile()
+	if !strings.HasPrefix(content, "Checked 2 wallets, they all match the ledger.") || !strings.Contains(content, "house:blackjack") {
+		t.Errorf("Expected the wallets to match, got %q", content)
+	}
+
+	// A balance changed outside the ledger is reported
+	db.Model(&wallet.WalletUser{}).Where(wallet.WalletUser{GuildId: ExampleGuildId, UserId: ExampleUserId2}).Update("balance", 1)
+
+	content = reconcile()
+	if !strings.Contains(content, "doesn't match") || !strings.Contains(content, "<@"+ExampleUserId2+"> balance 1, ledger 500") {
+		t.Errorf("Expected the mismatch to be reported, got %q", content)
+	}
+}
@@ -0,0 +1,62 @@
+package walletApp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/aussiebroadwan/tony/pkg/wallet"
+	"github.com/bwmarrin/discordgo"
+)
+
+// WalletReverseSubCommand lets server admins undo a transaction, such as to
+// refund a mistake. The reversal is recorded alongside the original.
+//
+//	/wallet reverse transaction:<id> reason:<reason>
+type WalletReverseSubCommand struct {
+	framework.ApplicationSubCommand
+}
+
+func (c WalletReverseSubCommand) GetType() framework.AppType {
+	return framework.AppTypeSubCommand
+}
+
+// WalletReverseArgs are the options of "/wallet reverse"
+type WalletReverseArgs struct {
+	Transaction int64  `option:"transaction,required,min=1" description:"The ID of the transaction to reverse"`
+	Reason      string `option:"reason,required" description:"Why the transaction is being reversed"`
+}
+
+func (c WalletReverseSubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        "reverse",
+		Description: "Reverse a transaction (admin only)",
+		Options:     framework.CommandOptions(WalletReverseArgs{}),
+	}
+}
+
+func (c WalletReverseSubCommand) OnCommand(ctx framework.CommandContext) {
+	db := ctx.Database().WithContext(ctx.Context())
+
+	var args WalletReverseArgs
+	if err := ctx.Bind(&args); err != nil {
+		ctx.Logger().WithError(err).Error("Invalid options")
+		sendErrorResponse(ctx, "**Error:** "+err.Error())
+		return
+	}
+
+	err := wallet.Reverse(db, ctx.GuildID(), uint(args.Transaction), args.Reason)
+	switch {
+	case errors.Is(err, wallet.ErrTransactionNotFound), errors.Is(err, wallet.ErrAlreadyReversed), errors.Is(err, wallet.ErrInsufficientBalance):
+		sendErrorResponse(ctx, "**Error:** "+err.Error())
+		return
+	case err != nil:
+		ctx.Logger().WithError(err).Error("Failed to reverse transaction")
+		sendErrorResponse(ctx, "**Error:** Failed to reverse transaction")
+		return
+	}
+
+	ctx.Logger().WithField("transaction_id", args.Transaction).Infof("Transaction reversed by %s: %s", ctx.GetUser().ID, args.Reason)
+	sendSuccessResponse(ctx, fmt.Sprintf("Transaction #%d has been reversed", args.Transaction))
+}
@@ -0,0 +1,68 @@
+package walletApp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aussiebroadwan/tony/framework"
+	"github.com/aussiebroadwan/tony/framework/frameworktest"
+	"github.com/aussiebroadwan/tony/pkg/wallet"
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reverseInteraction builds the interaction for "/wallet reverse transaction:<id> reason:<reason>"
+func reverseInteraction(transactionId uint, reason string) *discordgo.Interaction {
+	interaction := payInteraction(ExampleUserId1, ExampleUserId2, 0)
+	interaction.Data = discordgo.ApplicationCommandInteractionData{
+		Name: "wallet",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{
+				Name: "reverse",
+				Type: discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{Name: "transaction", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(transactionId)},
+					{Name: "reason", Type: discordgo.ApplicationCommandOptionString, Value: reason},
+				},
+			},
+		},
+	}
+	return interaction
+}
+
+func TestWalletReverseSubCommand(t *testing.T) {
+	db := setupTestDB(t)
+
+	wallet.Debit(db, ExampleGuildId, ExampleUserId1, 100, "Blackjack bet", "blackjack")
+	history, _ := wallet.History(db, ExampleGuildId, ExampleUserId1, 1)
+
+	tests := []struct {
+		name          string
+		transactionId uint
+		response      string
+	}{
+		{"reversed", history[0].ID, fmt.Sprintf("Transaction #%d has been reversed", history[0].ID)},
+		{"already reversed", history[0].ID, "**Error:** transaction has already been reversed"},
+		{"not found", 10000, "**Error:** transaction not found"},
+	}
+
+	for _, test := range tests {
+		session := frameworktest.NewFakeSession()
+		ctx, err := framework.NewInteractionContext(session, db, log.WithField("src", "test"), reverseInteraction(test.transactionId, "bet taken by mistake"))
+		if err != nil {
+			t.Fatalf("Failed to create context: %v", err)
+		}
+
+		WalletReverseSubCommand{}.OnCommand(ctx)
+
+		response := session.LastResponse()
+		if response == nil || response.Data.Content != test.response {
+			t.Errorf("%s: expected response %q, got %+v", test.name, test.response, response)
+		}
+	}
+
+	if balance, _ := wallet.Balance(db, ExampleGuildId, ExampleUserId1); balance != wallet.DefaultBalance {
+		t.Errorf("Expected the bet to be refunded, got balance %d", balance)
+	}
+}
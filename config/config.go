@@ -0,0 +1,201 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aussiebroadwan/tony/database"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Config holds the settings server admins can change without recompiling.
+// It is loaded from a YAML file with environment variables overriding the
+// file, see Load.
+type Config struct {
+	// Database is only read on startup, changing it needs a restart
+	Database DatabaseConfig `yaml:"database"`
+
+	// StartupChannel is the name of the channel Tony announces itself in
+	StartupChannel string `yaml:"startup_channel"`
+
+	Autopin    AutopinConfig    `yaml:"autopin"`
+	Blackjack  BlackjackConfig  `yaml:"blackjack"`
+	Snailrace  SnailraceConfig  `yaml:"snailrace"`
+	Wallet     WalletConfig     `yaml:"wallet"`
+	Moderation ModerationConfig `yaml:"moderation"`
+}
+
+type DatabaseConfig struct {
+	// Driver is "postgres" or "sqlite", see database.Open for the DSN of each
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
+}
+
+type AutopinConfig struct {
+	// Threshold is how many 📌 reactions pin a message
+	Threshold int `yaml:"threshold"`
+}
+
+type BlackjackConfig struct {
+	// MaxPlayers is how many players can join a round
+	MaxPlayers int `yaml:"max_players"`
+}
+
+type SnailraceConfig struct {
+	// JoinDelay is how long snails can join a race before betting opens
+	JoinDelay time.Duration `yaml:"join_delay"`
+}
+
+type WalletConfig struct {
+	// DefaultBalance is the balance a new user starts with
+	DefaultBalance int64 `yaml:"default_balance"`
+}
+
+type ModerationConfig struct {
+	// NewsChannel and RSSChannel are the names of the moderated channels
+	NewsChannel string `yaml:"news_channel"`
+	RSSChannel  string `yaml:"rss_channel"`
+}
+
+// Default returns the configuration used when nothing is set
+func Default() Config {
+	return Config{
+		Database:       DatabaseConfig{Driver: database.SQLite, DSN: "tony.db"},
+		StartupChannel: "tony-dev",
+		Autopin:        AutopinConfig{Threshold: 5},
+		Blackjack:      BlackjackConfig{MaxPlayers: 7},
+		Snailrace:      SnailraceConfig{JoinDelay: 30 * time.Second},
+		Wallet:         WalletConfig{DefaultBalance: 500},
+		Moderation: ModerationConfig{
+			NewsChannel: "tech-news",
+			RSSChannel:  "rss",
+		},
+	}
+}
+
+// Load reads the configuration from the YAML file at path over the
+// defaults, an empty path only uses the defaults. The environment variables
+// are then applied and the result is validated.
+func Load(path string) (Config, error) {
+	config := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+
+		// Misspelt settings are rejected rather than silently ignored
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&config); err != nil && err != io.EOF {
+			return Config{}, fmt.Errorf("%w: %s", ErrInvalidConfig, err)
+		}
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return Config{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// applyEnv overrides the settings which have an environment variable set
+func (c *Config) applyEnv() error {
+	// A full DSN is used over the separate Postgres settings
+	if host := os.Getenv("DB_HOST"); host != "" {
+		c.Database.Driver = database.Postgres
+		c.Database.DSN = database.PostgresDSN(host, os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"), os.Getenv("DB_SSLMODE"))
+	}
+	if value := os.Getenv("DB_DRIVER"); value != "" {
+		c.Database.Driver = value
+	}
+	if value := os.Getenv("DB_DSN"); value != "" {
+		c.Database.DSN = value
+	}
+
+	if value := os.Getenv("DISCORD_STARTUP_CHANNEL"); value != "" {
+		c.StartupChannel = value
+	}
+	if value := os.Getenv("NEWS_CHANNEL"); value != "" {
+		c.Moderation.NewsChannel = value
+	}
+	if value := os.Getenv("RSS_CHANNEL"); value != "" {
+		c.Moderation.RSSChannel = value
+	}
+
+	if err := envInt("AUTOPIN_THRESHOLD", &c.Autopin.Threshold); err != nil {
+		return err
+	}
+	if err := envInt("BLACKJACK_MAX_PLAYERS", &c.Blackjack.MaxPlayers); err != nil {
+		return err
+	}
+
+	if value := os.Getenv("SNAILRACE_JOIN_DELAY"); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%w: SNAILRACE_JOIN_DELAY: %s", ErrInvalidConfig, err)
+		}
+		c.Snailrace.JoinDelay = delay
+	}
+
+	if value := os.Getenv("WALLET_DEFAULT_BALANCE"); value != "" {
+		balance, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: WALLET_DEFAULT_BALANCE: %s", ErrInvalidConfig, err)
+		}
+		c.Wallet.DefaultBalance = balance
+	}
+
+	return nil
+}
+
+func envInt(name string, dst *int) error {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidConfig, name, err)
+	}
+
+	*dst = n
+	return nil
+}
+
+// Validate checks every setting is usable, returning the first which isn't
+func (c Config) Validate() error {
+	switch {
+	case c.Database.Driver != database.Postgres && c.Database.Driver != database.SQLite:
+		return fmt.Errorf("%w: database.driver must be %s or %s", ErrInvalidConfig, database.Postgres, database.SQLite)
+	case c.Database.DSN == "":
+		return fmt.Errorf("%w: database.dsn must be set", ErrInvalidConfig)
+	case c.StartupChannel == "":
+		return fmt.Errorf("%w: startup_channel must be set", ErrInvalidConfig)
+	case c.Autopin.Threshold < 1:
+		return fmt.Errorf("%w: autopin.threshold must be at least 1", ErrInvalidConfig)
+	case c.Blackjack.MaxPlayers < 1:
+		return fmt.Errorf("%w: blackjack.max_players must be at least 1", ErrInvalidConfig)
+	case c.Snailrace.JoinDelay < time.Second:
+		return fmt.Errorf("%w: snailrace.join_delay must be at least 1s", ErrInvalidConfig)
+	case c.Wallet.DefaultBalance < 0:
+		return fmt.Errorf("%w: wallet.default_balance can't be negative", ErrInvalidConfig)
+	case c.Moderation.NewsChannel == "" || c.Moderation.RSSChannel == "":
+		return fmt.Errorf("%w: moderation channels must be set", ErrInvalidConfig)
+	}
+
+	return nil
+}
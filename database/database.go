@@ -1,42 +1,86 @@
 package database
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"strings"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+)
 
-	log "github.com/sirupsen/logrus"
+// The database drivers Tony can use
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
 )
 
-func NewDatabase() *gorm.DB {
-	host := os.Getenv("DB_HOST")
-	dbname := os.Getenv("DB_NAME")
-	user := os.Getenv("DB_USER")
-	password := os.Getenv("DB_PASSWORD")
+var ErrUnknownDriver = errors.New("unknown database driver")
 
-	if host == "" {
-		log.Fatal("No host provided. Please set DB_HOST environment variable.")
-	}
+// Open connects to the database with the driver. Postgres takes a DSN such
+// as "host=db user=tony password=... dbname=tony sslmode=require" or a
+// postgres:// URL. SQLite takes a file path, or "file::memory:" for a
+// database which only lasts as long as the process.
+func Open(driver, dsn string) (*gorm.DB, error) {
+	switch driver {
+	case Postgres:
+		return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+
+	case SQLite:
+		db, err := gorm.Open(sqlite.Open(sqliteDSN(dsn)), &gorm.Config{})
+		if err != nil {
+			return nil, err
+		}
 
-	if dbname == "" {
-		log.Fatal("No database name provided. Please set DB_NAME environment variable.")
+		// SQLite only allows one writer at a time, and each connection to an
+		// in memory database would have its own database
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+
+		return db, nil
 	}
 
-	if user == "" {
-		log.Fatal("No user provided. Please set DB_USER environment variable.")
+	return nil, fmt.Errorf("%w %q, expected %s or %s", ErrUnknownDriver, driver, Postgres, SQLite)
+}
+
+// sqliteDSN sets the transactions in the DSN to lock the database when they
+// begin rather than when they first write, and to wait for another process's
+// lock instead of failing straight away, unless the DSN already sets them
+func sqliteDSN(dsn string) string {
+	params := []string{}
+	if !strings.Contains(dsn, "_txlock=") {
+		params = append(params, "_txlock=immediate")
+	}
+	if !strings.Contains(dsn, "busy_timeout") {
+		params = append(params, "_pragma=busy_timeout(5000)")
+	}
+	if len(params) == 0 {
+		return dsn
 	}
 
-	if password == "" {
-		log.Fatal("No password provided. Please set DB_PASSWORD environment variable.")
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
 	}
+	return dsn + separator + strings.Join(params, "&")
+}
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=disable", host, user, password, dbname)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+// PostgresDSN builds a Postgres DSN from its parts, sslmode defaults to
+// disable when it is empty
+func PostgresDSN(host, user, password, dbname, sslmode string) string {
+	if sslmode == "" {
+		sslmode = "disable"
 	}
 
-	return db
+	parts := []string{}
+	for _, part := range [][2]string{{"host", host}, {"user", user}, {"password", password}, {"dbname", dbname}, {"sslmode", sslmode}} {
+		if part[1] != "" {
+			parts = append(parts, part[0]+"="+part[1])
+		}
+	}
+	return strings.Join(parts, " ")
 }
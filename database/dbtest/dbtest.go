@@ -0,0 +1,120 @@
+// Package dbtest opens the database which package tests run against.
+//
+// Tests use a SQLite database in a temporary directory unless TEST_DB_DRIVER and
+// TEST_DB_DSN are set, for example to run them against Postgres:
+//
+//	TEST_DB_DRIVER=postgres TEST_DB_DSN="host=localhost user=tony password=tony dbname=tony_test" go test -p 1 ./...
+package dbtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aussiebroadwan/tony/database"
+	"github.com/aussiebroadwan/tony/database/migrate"
+	"gorm.io/gorm"
+)
+
+// connections are the driver and DSN each database opened by Open was
+// connected with, so Connect can connect to it again
+var connections sync.Map
+
+type connection struct {
+	driver, dsn string
+}
+
+// Open opens a new database for the test and applies the migrations. Each
+// test has its own database, on Postgres it is a schema which is dropped when
+// the test ends.
+func Open(t testing.TB, migrations ...[]migrate.Migration) *gorm.DB {
+	t.Helper()
+
+	driver, dsn := os.Getenv("TEST_DB_DRIVER"), os.Getenv("TEST_DB_DSN")
+	if driver == "" {
+		driver, dsn = database.SQLite, filepath.Join(t.TempDir(), "test.db")
+	}
+
+	if driver == database.Postgres {
+		dsn = openSchema(t, dsn)
+	}
+
+	db := connect(t, connection{driver, dsn})
+
+	migrator, err := migrate.New(db, migrations...)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	return db
+}
+
+// Connect opens another connection to a database opened by Open, as a second
+// instance of Tony sharing the database would.
+func Connect(t testing.TB, db *gorm.DB) *gorm.DB {
+	t.Helper()
+
+	conn, ok := connections.Load(db)
+	if !ok {
+		t.Fatalf("database was not opened by dbtest.Open")
+	}
+	return connect(t, conn.(connection))
+}
+
+// connect connects to the database, closing the connection when the test
+// ends
+func connect(t testing.TB, conn connection) *gorm.DB {
+	db, err := database.Open(conn.driver, conn.dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	connections.Store(db, conn)
+
+	t.Cleanup(func() {
+		connections.Delete(db)
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// openSchema creates a schema for the test to keep its tables in, returning
+// the DSN to connect to it with
+func openSchema(t testing.TB, dsn string) string {
+	id := make([]byte, 6)
+	rand.Read(id)
+	schema := "test_" + hex.EncodeToString(id)
+
+	db, err := database.Open(database.Postgres, dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	if err := db.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+
+	// Registered first so it runs after the test's connection is closed
+	t.Cleanup(func() {
+		db.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if strings.Contains(dsn, "://") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		return dsn + separator + "search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
@@ -0,0 +1,260 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSchemaBehind     = errors.New("database schema is behind")
+	ErrInvalidMigration = errors.New("invalid migration")
+	ErrIrreversible     = errors.New("migration can't be rolled back")
+	ErrNoLegacyGuild    = errors.New("no server set for records from before servers were kept")
+)
+
+// Migration is a versioned change to a package's tables. Versions start at 1
+// for each package and are applied in order. Up and Down are run in a
+// transaction, Down may be nil for a migration which can't be undone.
+type Migration struct {
+	Package string
+	Version int
+	Name    string
+
+	Up   func(tx *gorm.DB) error
+	Down func(tx *gorm.DB) error
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%s %d %s", m.Package, m.Version, m.Name)
+}
+
+// SchemaMigration records a migration which has been applied
+type SchemaMigration struct {
+	ID        uint   `gorm:"primarykey"`
+	Package   string `gorm:"uniqueIndex:idx_schema_migrations_version"`
+	Version   int    `gorm:"uniqueIndex:idx_schema_migrations_version"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// Status is whether a migration has been applied, and when
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and rolls back the migrations of each package, keeping
+// track of them in the schema_migrations table
+type Migrator struct {
+	db          *gorm.DB
+	migrations  []Migration
+	legacyGuild string
+}
+
+// legacyGuildKey is the context key the legacy server is passed to the
+// migrations with
+type legacyGuildKey struct{}
+
+// New creates a migrator for the migrations of each package, which are
+// applied in the order the packages are given
+func New(db *gorm.DB, packages ...[]Migration) (*Migrator, error) {
+	m := &Migrator{db: db}
+
+	for _, migrations := range packages {
+		for i, migration := range migrations {
+			if migration.Package == "" || migration.Up == nil {
+				return nil, fmt.Errorf("%w: %s needs a package and an up step", ErrInvalidMigration, migration)
+			}
+			if migration.Version != i+1 || migration.Package != migrations[0].Package {
+				return nil, fmt.Errorf("%w: %s is out of order", ErrInvalidMigration, migration)
+			}
+		}
+		m.migrations = append(m.migrations, migrations...)
+	}
+
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// SetLegacyGuild sets the server which records from before data was kept
+// per server are given by BackfillGuild
+func (m *Migrator) SetLegacyGuild(guildId string) {
+	m.legacyGuild = guildId
+}
+
+// BackfillGuild gives the rows of each table without a guild_id the legacy
+// server set on the migrator. It returns ErrNoLegacyGuild when there are rows
+// to backfill and no server has been set.
+func BackfillGuild(tx *gorm.DB, tables ...string) error {
+	guildId, _ := tx.Statement.Context.Value(legacyGuildKey{}).(string)
+
+	for _, table := range tables {
+		legacy := tx.Table(table).Where("guild_id IS NULL OR guild_id = ''")
+
+		if guildId == "" {
+			var count int64
+			if err := legacy.Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: %d rows in %s", ErrNoLegacyGuild, count, table)
+			}
+			continue
+		}
+
+		if err := legacy.Update("guild_id", guildId).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// session returns the database the migrations are run with, which passes
+// them the legacy server
+func (m *Migrator) session() *gorm.DB {
+	return m.db.WithContext(context.WithValue(context.Background(), legacyGuildKey{}, m.legacyGuild))
+}
+
+// applied returns the migrations which have been applied, in the order they
+// were applied
+func (m *Migrator) applied() ([]SchemaMigration, error) {
+	var applied []SchemaMigration
+	err := m.db.Order("id").Find(&applied).Error
+	return applied, err
+}
+
+// Status returns every migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	when := make(map[string]time.Time)
+	for _, record := range applied {
+		when[fmt.Sprintf("%s/%d", record.Package, record.Version)] = record.AppliedAt
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := when[fmt.Sprintf("%s/%d", migration.Package, migration.Version)]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations which have not been applied
+func (m *Migrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Check returns ErrSchemaBehind if any migration has not been applied
+func (m *Migrator) Check() error {
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d migrations pending, starting with %s", ErrSchemaBehind, len(pending), pending[0])
+	}
+	return nil
+}
+
+// Up applies the pending migrations in order, stopping at the first which
+// fails. It returns the migrations which were applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0, len(pending))
+	for _, migration := range pending {
+		err := m.session().Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+
+			return tx.Create(&SchemaMigration{
+				Package:   migration.Package,
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("%s: %w", migration, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the last steps migrations to be applied, newest first. It
+// returns the migrations which were rolled back.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0, steps)
+	for i := len(applied) - 1; i >= 0 && len(done) < steps; i-- {
+		record := applied[i]
+
+		migration, ok := m.find(record.Package, record.Version)
+		if !ok {
+			return done, fmt.Errorf("%w: %s %d %s is not known", ErrInvalidMigration, record.Package, record.Version, record.Name)
+		}
+		if migration.Down == nil {
+			return done, fmt.Errorf("%s: %w", migration, ErrIrreversible)
+		}
+
+		err := m.session().Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&record).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("%s: %w", migration, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+func (m *Migrator) find(pkg string, version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Package == pkg && migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
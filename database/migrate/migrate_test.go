@@ -0,0 +1,186 @@
+package migrate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type note struct {
+	ID   uint
+	Text string
+}
+
+// notesMigrations creates a notes table, indexes it then adds a row which
+// can't be removed
+var notesMigrations = []Migration{
+	{
+		Package: "notes",
+		Version: 1,
+		Name:    "create notes table",
+		Up:      func(tx *gorm.DB) error { return tx.AutoMigrate(&note{}) },
+		Down:    func(tx *gorm.DB) error { return tx.Migrator().DropTable(&note{}) },
+	},
+	{
+		Package: "notes",
+		Version: 2,
+		Name:    "index notes by text",
+		Up:      func(tx *gorm.DB) error { return tx.Exec("CREATE INDEX idx_notes_text ON notes (text)").Error },
+		Down:    func(tx *gorm.DB) error { return tx.Exec("DROP INDEX idx_notes_text").Error },
+	},
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	db := newTestDB(t)
+
+	migrator, err := New(db, notesMigrations)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	if err := migrator.Check(); !errors.Is(err, ErrSchemaBehind) {
+		t.Errorf("Expected ErrSchemaBehind, got %v", err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("Expected 2 migrations to be applied, got %v: %v", applied, err)
+	}
+	if !db.Migrator().HasIndex(&note{}, "idx_notes_text") {
+		t.Errorf("Expected the index to be created")
+	}
+	if err := migrator.Check(); err != nil {
+		t.Errorf("Expected the schema to be up to date, got %v", err)
+	}
+
+	// Running again does nothing
+	if applied, err := migrator.Up(); err != nil || len(applied) != 0 {
+		t.Errorf("Expected nothing to be applied, got %v: %v", applied, err)
+	}
+
+	// Rolling back undoes the newest migration first
+	rolledBack, err := migrator.Down(1)
+	if err != nil || len(rolledBack) != 1 || rolledBack[0].Version != 2 {
+		t.Fatalf("Expected the index to be rolled back, got %v: %v", rolledBack, err)
+	}
+	if db.Migrator().HasIndex(&note{}, "idx_notes_text") || !db.Migrator().HasTable(&note{}) {
+		t.Errorf("Expected only the index to be removed")
+	}
+
+	statuses, _ := migrator.Status()
+	if len(statuses) != 2 || !statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("Expected only the first migration to be applied, got %+v", statuses)
+	}
+
+	if rolledBack, err := migrator.Down(5); err != nil || len(rolledBack) != 1 {
+		t.Fatalf("Expected the table to be rolled back, got %v: %v", rolledBack, err)
+	}
+	if db.Migrator().HasTable(&note{}) {
+		t.Errorf("Expected the table to be dropped")
+	}
+}
+
+func TestMigrateFailure(t *testing.T) {
+	db := newTestDB(t)
+
+	failing := append([]Migration{}, notesMigrations...)
+	failing = append(failing, Migration{
+		Package: "notes",
+		Version: 3,
+		Name:    "add a broken column",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("ALTER TABLE notes ADD COLUMN author TEXT").Error; err != nil {
+				return err
+			}
+			return errors.New("backfill failed")
+		},
+	})
+
+	migrator, _ := New(db, failing)
+	applied, err := migrator.Up()
+	if err == nil || len(applied) != 2 {
+		t.Fatalf("Expected the third migration to fail, applied %v: %v", applied, err)
+	}
+
+	// The failed migration is rolled back and left pending
+	if db.Migrator().HasColumn(&note{}, "author") {
+		t.Errorf("Expected the failed migration's changes to be rolled back")
+	}
+	if pending, _ := migrator.Pending(); len(pending) != 1 || pending[0].Version != 3 {
+		t.Errorf("Expected the failed migration to be pending, got %v", pending)
+	}
+
+	// Migrations without a down step can't be rolled back
+	db.Exec("ALTER TABLE notes ADD COLUMN author TEXT")
+	failing[2].Up = func(tx *gorm.DB) error { return nil }
+	migrator, _ = New(db, failing)
+	migrator.Up()
+	if _, err := migrator.Down(1); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("Expected ErrIrreversible, got %v", err)
+	}
+}
+
+func TestMigrateInvalid(t *testing.T) {
+	db := newTestDB(t)
+
+	outOfOrder := []Migration{notesMigrations[1], notesMigrations[0]}
+	if _, err := New(db, outOfOrder); !errors.Is(err, ErrInvalidMigration) {
+		t.Errorf("Expected ErrInvalidMigration, got %v", err)
+	}
+
+	noUp := []Migration{{Package: "notes", Version: 1, Name: "nothing"}}
+	if _, err := New(db, noUp); !errors.Is(err, ErrInvalidMigration) {
+		t.Errorf("Expected ErrInvalidMigration, got %v", err)
+	}
+}
+
+func TestBackfillGuild(t *testing.T) {
+	type ownedNote struct {
+		ID      uint
+		GuildID *string
+	}
+
+	db := newTestDB(t)
+	db.AutoMigrate(&ownedNote{})
+	guild, empty := "guild", ""
+	db.Create(&[]ownedNote{{GuildID: nil}, {GuildID: &empty}, {GuildID: &guild}})
+
+	backfill := []Migration{{
+		Package: "notes",
+		Version: 1,
+		Name:    "give notes a server",
+		Up:      func(tx *gorm.DB) error { return BackfillGuild(tx, "owned_notes") },
+	}}
+
+	// Existing rows need a server to be given
+	migrator, _ := New(db, backfill)
+	if _, err := migrator.Up(); !errors.Is(err, ErrNoLegacyGuild) {
+		t.Fatalf("Expected ErrNoLegacyGuild, got %v", err)
+	}
+
+	migrator.SetLegacyGuild("legacy")
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Failed to backfill: %v", err)
+	}
+
+	var notes []ownedNote
+	db.Order("id").Find(&notes)
+	for i, want := range []string{"legacy", "legacy", "guild"} {
+		if notes[i].GuildID == nil || *notes[i].GuildID != want {
+			t.Errorf("Expected note %d to be in %s, got %v", notes[i].ID, want, notes[i].GuildID)
+		}
+	}
+}
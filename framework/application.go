@@ -2,6 +2,7 @@ package framework
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -14,7 +15,8 @@ const (
 	AppTypeNOP AppType = 1
 
 	// AppTypeCommand is an discord application command that is executed by a
-	// user and handled by the OnCommand() handler
+	// user and handled by the OnCommand() handler. This includes user and
+	// message context menu commands based on the type of the definition
 	AppTypeCommand AppType = 1 << 1
 
 	// AppTypeCommand is an discord application command that is executed by a
@@ -37,6 +39,39 @@ const (
 	// AppTypeMountable is an application which runs on mount, handled by the
 	// OnMount() handler
 	AppTypeMountable AppType = 1 << 6
+
+	// AppTypeAutocomplete is an application which suggests option values while
+	// the user is typing a command, handled by the OnAutocomplete() handler
+	AppTypeAutocomplete AppType = 1 << 7
+
+	// AppTypeMessageUpdate is an application which runs when a message is
+	// edited, handled by the OnMessageUpdate() handler
+	AppTypeMessageUpdate AppType = 1 << 8
+
+	// AppTypeMessageDelete is an application which runs when a message is
+	// deleted, handled by the OnMessageDelete() handler
+	AppTypeMessageDelete AppType = 1 << 9
+
+	// AppTypeReactionRemoveAll is an application which runs when all the
+	// reactions are removed from a message, handled by the
+	// OnReactionRemoveAll() handler
+	AppTypeReactionRemoveAll AppType = 1 << 10
+
+	// AppTypeMember is an application which runs when a member joins or leaves
+	// a server, handled by the OnMember() handler
+	AppTypeMember AppType = 1 << 11
+
+	// AppTypeThread is an application which runs when a thread is created,
+	// handled by the OnThreadCreate() handler
+	AppTypeThread AppType = 1 << 12
+
+	// AppTypeShutdown is an application which finishes its work when the bot
+	// is shutting down, handled by the OnShutdown() handler
+	AppTypeShutdown AppType = 1 << 13
+
+	// AppTypeConfig is an application which is given the configuration when
+	// it is loaded or reloaded, handled by the OnConfig() handler
+	AppTypeConfig AppType = 1 << 14
 )
 
 type Application interface {
@@ -59,11 +94,24 @@ type ApplicationSubCommand interface {
 	OnCommand(ctx CommandContext)
 }
 
+// ApplicationSubCommandDefinition is implemented by subcommands and subcommand
+// groups to describe themselves in their command's definition, which has its
+// subcommand options generated from the route tree.
+type ApplicationSubCommandDefinition interface {
+	Application
+	GetDefinition() *discordgo.ApplicationCommandOption
+}
+
 type ApplicationEvent interface {
 	Application
 	OnEvent(ctx EventContext, eventType discordgo.InteractionType)
 }
 
+type ApplicationAutocomplete interface {
+	Application
+	OnAutocomplete(ctx AutocompleteContext)
+}
+
 type ApplicationMessage interface {
 	Application
 	OnMessage(ctx MessageContext, channel *discordgo.Channel)
@@ -74,28 +122,95 @@ type ApplicationReaction interface {
 	OnReaction(ctx ReactionContext)
 }
 
-// Route associates a command name with a command instance and optional subcommands
-type Route struct {
-	Name      string
-	App       Application
-	Subroutes []Route
+type ApplicationMessageUpdate interface {
+	Application
+	OnMessageUpdate(ctx MessageUpdateContext, channel *discordgo.Channel)
+}
+
+type ApplicationMessageDelete interface {
+	Application
+	OnMessageDelete(ctx MessageContext)
+}
+
+type ApplicationReactionRemoveAll interface {
+	Application
+	OnReactionRemoveAll(ctx ReactionContext)
+}
+
+type ApplicationMember interface {
+	Application
+	OnMember(ctx MemberContext)
+}
+
+type ApplicationThread interface {
+	Application
+	OnThreadCreate(ctx ThreadContext)
+}
 
-	appRoute map[string]Application
+// ApplicationShutdown is run when the bot shuts down, before the gateway is
+// closed. OnShutdown should return once the app's work is finished or the
+// context's deadline has passed.
+type ApplicationShutdown interface {
+	Application
+	OnShutdown(ctx ShutdownContext)
 }
 
-// NewRoute constructs a new Route
-func NewRoute(bot *Bot, routeName string, command Application, subroutes ...Route) Route {
+// ApplicationConfig is run with the configuration when the app is registered
+// and each time the configuration is reloaded, so the app can use the new
+// values without restarting.
+type ApplicationConfig interface {
+	Application
+	OnConfig(ctx ConfigContext)
+}
+
+// Route associates a command name with a command instance and optional
+// subcommands and requirements
+type Route struct {
+	Name         string
+	App          Application
+	Subroutes    []Route
+	Requirements []Requirements
+
+	// Timeout is the deadline of the handler's context, zero uses the
+	// parent route's timeout or DefaultTimeout
+	Timeout time.Duration
+
+	appRoute        map[string]Application
+	appRequirements map[string][]Requirements
+	appTimeouts     map[string]time.Duration
+}
+
+// NewRoute constructs a new Route. The options can be subroutes, requirements
+// or a timeout, which apply to the route and all of its subroutes.
+func NewRoute(bot *Bot, routeName string, command Application, opts ...RouteOption) Route {
 	r := Route{
-		Name:      routeName,
-		App:       command,
-		Subroutes: subroutes,
-		appRoute:  make(map[string]Application),
+		Name:            routeName,
+		App:             command,
+		Subroutes:       make([]Route, 0),
+		Requirements:    make([]Requirements, 0),
+		appRoute:        make(map[string]Application),
+		appRequirements: make(map[string][]Requirements),
+		appTimeouts:     make(map[string]time.Duration),
+	}
+
+	// Apply the subroutes and requirements
+	for _, opt := range opts {
+		opt.applyRoute(&r)
 	}
 
 	// Add the subroutes to the route map
-	for _, sr := range subroutes {
+	for _, sr := range r.Subroutes {
 		for k, v := range sr.appRoute {
-			r.appRoute[fmt.Sprintf("%s.%s", r.Name, k)] = v
+			key := fmt.Sprintf("%s.%s", r.Name, k)
+			r.appRoute[key] = v
+			r.appRequirements[key] = append(append([]Requirements{}, sr.appRequirements[k]...), r.Requirements...)
+
+			// Subroutes without their own timeout use this route's
+			if timeout, ok := sr.appTimeouts[k]; ok {
+				r.appTimeouts[key] = timeout
+			} else if r.Timeout > 0 {
+				r.appTimeouts[key] = r.Timeout
+			}
 		}
 	}
 
@@ -106,6 +221,10 @@ func NewRoute(bot *Bot, routeName string, command Application, subroutes ...Rout
 
 	// Add the command to the command route
 	r.appRoute[routeName] = command
+	r.appRequirements[routeName] = r.Requirements
+	if r.Timeout > 0 {
+		r.appTimeouts[routeName] = r.Timeout
+	}
 
 	return r
 }
@@ -119,6 +238,14 @@ func checkApplicationImplementation(bot *Bot, app Application, name string) bool
 	_, implementesAppEvent := app.(ApplicationEvent)
 	_, implementesAppMessage := app.(ApplicationMessage)
 	_, implementesAppReaction := app.(ApplicationReaction)
+	_, implementesAppAutocomplete := app.(ApplicationAutocomplete)
+	_, implementesAppMessageUpdate := app.(ApplicationMessageUpdate)
+	_, implementesAppMessageDelete := app.(ApplicationMessageDelete)
+	_, implementesAppReactionRemoveAll := app.(ApplicationReactionRemoveAll)
+	_, implementesAppMember := app.(ApplicationMember)
+	_, implementesAppThread := app.(ApplicationThread)
+	_, implementesAppShutdown := app.(ApplicationShutdown)
+	_, implementesAppConfig := app.(ApplicationConfig)
 
 	// Check if the app says its an Application Command but does not implement
 	// the ApplicationCommand interface
@@ -155,6 +282,62 @@ func checkApplicationImplementation(bot *Bot, app Application, name string) bool
 		implements = false
 	}
 
+	// Check if the app says its an Application Autocomplete but does not
+	// implement the ApplicationAutocomplete interface
+	if app.GetType()&AppTypeAutocomplete != 0 && !implementesAppAutocomplete {
+		bot.lg.Errorf("Autocomplete %s does not implement ApplicationAutocomplete interface", name)
+		implements = false
+	}
+
+	// Check if the app says its an Application Message Update but does not
+	// implement the ApplicationMessageUpdate interface
+	if app.GetType()&AppTypeMessageUpdate != 0 && !implementesAppMessageUpdate {
+		bot.lg.Errorf("Message update %s does not implement ApplicationMessageUpdate interface", name)
+		implements = false
+	}
+
+	// Check if the app says its an Application Message Delete but does not
+	// implement the ApplicationMessageDelete interface
+	if app.GetType()&AppTypeMessageDelete != 0 && !implementesAppMessageDelete {
+		bot.lg.Errorf("Message delete %s does not implement ApplicationMessageDelete interface", name)
+		implements = false
+	}
+
+	// Check if the app says its an Application Reaction Remove All but does
+	// not implement the ApplicationReactionRemoveAll interface
+	if app.GetType()&AppTypeReactionRemoveAll != 0 && !implementesAppReactionRemoveAll {
+		bot.lg.Errorf("Reaction remove all %s does not implement ApplicationReactionRemoveAll interface", name)
+		implements = false
+	}
+
+	// Check if the app says its an Application Member but does not implement
+	// the ApplicationMember interface
+	if app.GetType()&AppTypeMember != 0 && !implementesAppMember {
+		bot.lg.Errorf("Member %s does not implement ApplicationMember interface", name)
+		implements = false
+	}
+
+	// Check if the app says its an Application Thread but does not implement
+	// the ApplicationThread interface
+	if app.GetType()&AppTypeThread != 0 && !implementesAppThread {
+		bot.lg.Errorf("Thread %s does not implement ApplicationThread interface", name)
+		implements = false
+	}
+
+	// Check if the app says its an Application Shutdown but does not implement
+	// the ApplicationShutdown interface
+	if app.GetType()&AppTypeShutdown != 0 && !implementesAppShutdown {
+		bot.lg.Errorf("Shutdown %s does not implement ApplicationShutdown interface", name)
+		implements = false
+	}
+
+	// Check if the app says its an Application Config but does not implement
+	// the ApplicationConfig interface
+	if app.GetType()&AppTypeConfig != 0 && !implementesAppConfig {
+		bot.lg.Errorf("Config %s does not implement ApplicationConfig interface", name)
+		implements = false
+	}
+
 	return implements
 }
 
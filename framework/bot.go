@@ -1,6 +1,11 @@
 package framework
 
 import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/bwmarrin/discordgo"
 	"gorm.io/gorm"
 
@@ -10,28 +15,78 @@ import (
 type Bot struct {
 	Discord *discordgo.Session
 
-	serverId string
-	Routes   []Route
+	serverIds []string
+	Routes    []Route
+
+	middleware []Middleware
+
+	// deferAfter is how long interaction handlers run before they are
+	// deferred, zero uses DefaultDeferAfter
+	deferAfter time.Duration
+
+	// states keeps the component state, see componentStates
+	states     *ComponentStates
+	statesOnce sync.Once
+
+	// bus hands events between apps, see Events
+	bus     *EventBus
+	busOnce sync.Once
+
+	// disabled caches the routes disabled in each server, see disabledApps
+	disabled   map[string]map[string]bool
+	disabledMu sync.Mutex
+
+	// mounted is the session the routes were mounted with
+	mounted Session
 
-	registeredDiscordApplications map[string]*discordgo.ApplicationCommand
+	// scheduler runs the scheduled jobs, see Scheduler
+	scheduler     *Scheduler
+	schedulerOnce sync.Once
+
+	// metrics are recorded for every handler, see Metrics
+	metrics        *Metrics
+	handlerMetrics botMetrics
+	metricsOnce    sync.Once
+
+	// gatewayUsed is set once Run opens the gateway, which then keeps
+	// track of whether it is connected, see watchGateway
+	gatewayUsed      atomic.Bool
+	gatewayConnected atomic.Bool
+	gatewayConnects  atomic.Int64
+
+	// httpServer is set when interactions are served with RunHTTP
+	httpServer *http.Server
+
+	// metricsServer is set when the metrics are served with ServeMetrics
+	metricsServer *http.Server
+
+	// config is passed to the config apps, see Configure, and configStop
+	// ends the watch started by WatchConfig
+	config     any
+	configMu   sync.Mutex
+	configStop chan struct{}
 
 	lg *log.Entry
 	db *gorm.DB
 }
 
-func NewBot(token string, serverId string, db *gorm.DB) (*Bot, error) {
+// NewBot creates a bot serving each of the servers in serverIds
+func NewBot(token string, serverIds []string, db *gorm.DB) (*Bot, error) {
 	discord, err := discordgo.New("Bot " + token)
 	if err != nil {
 		return nil, err
 	}
 
+	// Keep recent messages so edits and deletes have the message before
+	discord.State.MaxMessageCount = messageCacheSize
+
 	return &Bot{
 		Discord: discord,
 
-		serverId: serverId,
-		Routes:   make([]Route, 0),
+		serverIds: serverIds,
+		Routes:    make([]Route, 0),
 
-		registeredDiscordApplications: make(map[string]*discordgo.ApplicationCommand),
+		middleware: make([]Middleware, 0),
 
 		lg: log.WithField("src", "bot"),
 		db: db,
@@ -44,15 +99,36 @@ func (b *Bot) OnStartup(cb func(StartupContext)) {
 			withSession(s),
 			withLogger(b.lg),
 			withDatabase(b.db),
+			withPublisher(b.Publish),
 		)
 
 		cb(ctx)
 	})
 }
 
+// Use appends middleware to the dispatch pipeline. Middleware wraps every
+// command, subcommand, event, message and reaction handler and is executed in
+// the order it was added.
+func (b *Bot) Use(middleware ...Middleware) {
+	b.middleware = append(b.middleware, middleware...)
+}
+
+// dispatch runs the handler through the middleware pipeline. The handler's
+// context is cancelled once the timeout passes or the handler returns, and
+// its metrics are recorded under the context's route key.
+func (b *Bot) dispatch(ctx *Context, timeout time.Duration, handler HandlerFunc) {
+	ctx, cancel := ctx.withTimeout(timeout)
+	defer cancel()
+
+	b.recordHandler(ctx, handler)
+}
+
 // Register adds routes to the bot
 func (b *Bot) Register(routes ...Route) {
+	b.configMu.Lock()
 	b.Routes = append(b.Routes, routes...)
+	config := b.config
+	b.configMu.Unlock()
 
 	for _, route := range routes {
 		// Register the route with the bot
@@ -60,115 +136,132 @@ func (b *Bot) Register(routes ...Route) {
 			b.lg.WithField("app", route.Name).Infof("Registering route: %s", key)
 		}
 	}
+
+	// Give the new routes the configuration if it has already been loaded
+	if config != nil {
+		b.configure(config, routes)
+	}
 }
 
-func (b *Bot) registerDiscordApplicationCommands() {
+// syncDiscordApplicationCommands brings the commands registered with Discord
+// up to date with the routes
+func (b *Bot) syncDiscordApplicationCommands() {
+	if _, err := b.SyncCommands(false); err != nil {
+		b.lg.WithError(err).Error("Error syncing commands")
+	}
+}
 
-	// Register the route with Discord
+func (b *Bot) interactionCreateHandler() func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	return func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		b.HandleInteraction(s, i)
+	}
+}
+
+// HandleInteraction routes the interaction to the registered application and
+// runs it through the middleware pipeline. The session is used for every
+// response, so the interaction does not need to come from the gateway.
+func (b *Bot) HandleInteraction(s Session, i *discordgo.InteractionCreate) {
+	// Track the response so slow handlers can be deferred
+	ds := newDeferringSession(s, i.Interaction)
+
+	// Create a new context for the route
+	ctx, err := NewInteractionContext(ds, b.db, b.lg, i.Interaction)
+	if err != nil {
+		b.lg.WithError(err).Errorf("Unknown interaction type")
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "Unknwon Interaction",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+	withComponentStates(b.componentStates())(ctx)
+	withPublisher(b.Publish)(ctx)
+	withScheduler(b.Scheduler())(ctx)
+
+	routeKey := ctx.RouteKey()
+	disabled := b.disabledApps(i.GuildID)
+
+	// Find the route
 	for _, route := range b.Routes {
+		if er, ok := route.appRoute[routeKey]; ok {
 
-		// Register Application if it is a command
-		if route.App.GetType()&AppTypeCommand != 0 {
-			app := route.App.(ApplicationCommand)
+			// Deny the interaction if the app is turned off in this server
+			if disabled[route.Name] {
+				ctx.Logger().Warn("Interaction for disabled app")
+				replyError(ctx, "This app is not enabled in this server")
+				return
+			}
 
-			// Register the command with Discord
-			createdApp, err := b.Discord.ApplicationCommandCreate(
-				b.Discord.State.User.ID,
-				b.serverId,
-				app.GetDefinition(),
-			)
+			// Deny the interaction if the user does not meet the route requirements
+			if err := checkRequirements(s, i.Interaction, route.appRequirements[routeKey]); err != nil {
+				ctx.Logger().WithError(err).Warn("Interaction denied")
+				replyError(ctx, err.Error())
+				return
+			}
 
-			// Check for errors
-			if err != nil {
-				b.lg.WithField("app", app.GetDefinition().Name).Errorf("Error creating command: %s", err)
-				continue
+			// Load the component state, rejecting expired or forged tokens
+			if err := b.loadComponentState(ctx, i.Interaction); err != nil {
+				ctx.Logger().WithError(err).Warn("Invalid component state")
+				replyError(ctx, err.Error())
+				return
 			}
 
-			// Add the application to the registered applications list for
-			// deregistration later
-			b.registeredDiscordApplications[route.Name] = createdApp
+			var handler func(ctx *Context)
+			switch {
+			// If the route is found and it is just a command, execute it
+			case i.Type == discordgo.InteractionApplicationCommand && (er.GetType()&(AppTypeCommand) != 0):
+				ctx.Logger().Infof("Executing command: %s", routeKey)
+				handler = func(ctx *Context) { er.(ApplicationCommand).OnCommand(ctx) }
 
-			// Notify that the application has been registered
-			b.lg.Infof("Created Discord Application: %s", route.Name)
-		}
-	}
-}
+			case i.Type == discordgo.InteractionApplicationCommand && (er.GetType()&(AppTypeSubCommand) != 0):
+				ctx.Logger().Infof("Executing subcommand: %s", routeKey)
+				handler = func(ctx *Context) { er.(ApplicationSubCommand).OnCommand(ctx) }
 
-func (b *Bot) interactionCreateHandler() func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	return func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		// Fetch the route key and event value from the interaction
-		routeKey, eventValue, err := GetRouteKey(i)
-		if err != nil {
-			b.lg.WithError(err).Errorf("Unknown interaction type")
-			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Content: "Unknwon Interaction",
-					Flags:   discordgo.MessageFlagsEphemeral,
-				},
-			})
-			return
-		}
+			// If the route is found and the user is typing an option, suggest values
+			case i.Type == discordgo.InteractionApplicationCommandAutocomplete && (er.GetType()&AppTypeAutocomplete != 0):
+				ctx.Logger().Debugf("Executing autocomplete: %s", routeKey)
+				handler = func(ctx *Context) { er.(ApplicationAutocomplete).OnAutocomplete(ctx) }
 
-		// Get the user from the interaction
-		user := i.Interaction.Member.User
-		if user == nil {
-			user = i.Interaction.User
-		}
+			// If the route is found and it is an event handler, execute it
+			case (i.Type == discordgo.InteractionMessageComponent || i.Type == discordgo.InteractionModalSubmit) && (er.GetType()&AppTypeEvent != 0):
+				ctx.Logger().Infof("Executing event: %s", routeKey)
+				handler = func(ctx *Context) { er.(ApplicationEvent).OnEvent(ctx, i.Type) }
 
-		// Create a new context for the route
-		ctx := NewContext(
-			withSession(s),
-			withDatabase(b.db),
-			withInteraction(i.Interaction),
-			withMessage(i.Interaction.Message),
-			withLogger(b.lg.WithFields(log.Fields{
-				"route": routeKey,
-				"type":  i.Type.String(),
-				"user":  user.ID,
-			})),
-		)
+			default:
+				continue
+			}
 
-		// Find the route
-		for _, route := range b.Routes {
-			if er, ok := route.appRoute[routeKey]; ok {
-
-				// If the route is found and it is just a command, execute it
-				if i.Type == discordgo.InteractionApplicationCommand && (er.GetType()&(AppTypeCommand) != 0) {
-					ctx.Logger().Infof("Executing command: %s", routeKey)
-					er.(ApplicationCommand).OnCommand(ctx)
-					return
-				}
-
-				if i.Type == discordgo.InteractionApplicationCommand && (er.GetType()&(AppTypeSubCommand) != 0) {
-					ctx.Logger().Infof("Executing subcommand: %s", routeKey)
-					er.(ApplicationSubCommand).OnCommand(ctx)
-					return
-				}
-
-				// If the route is found and it is an event handler, execute it
-				if i.Type != discordgo.InteractionApplicationCommand && (er.GetType()&AppTypeEvent != 0) {
-					// Set the event value for the route
-					withEventValue(eventValue)(ctx)
-
-					ctx.Logger().Infof("Executing event: %s", routeKey)
-					er.(ApplicationEvent).OnEvent(ctx, i.Type)
-					return
-				}
+			// Autocomplete can only be responded to with choices, anything
+			// else is deferred if the handler is slow to respond
+			stop := func() {}
+			if i.Type != discordgo.InteractionApplicationCommandAutocomplete {
+				stop = ds.deferAfter(b.deferThreshold(), ctx.Logger())
 			}
+
+			b.dispatch(ctx, b.routeTimeout(route, routeKey), handler)
+			stop()
+			return
 		}
+	}
 
-		ctx.Logger().Errorf("Interaction not found")
+	ctx.Logger().Errorf("Interaction not found")
 
-		// If the route is not found, respond with an error message
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Interaction not found",
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
-		})
+	// Autocomplete interactions can only be responded to with choices
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		return
 	}
+
+	// If the route is not found, respond with an error message
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "Interaction not found",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
 }
 
 func (b *Bot) messageCreateHandler() func(s *discordgo.Session, m *discordgo.MessageCreate) {
@@ -194,13 +287,16 @@ func (b *Bot) messageCreateHandler() func(s *discordgo.Session, m *discordgo.Mes
 			withSession(s),
 			withMessage(m.Message),
 			withDatabase(b.db),
+			withPublisher(b.Publish),
+			withGuildId(m.GuildID),
 		)
+		disabled := b.disabledApps(m.GuildID)
 
 		// Test a regex match for the channel name against the rule
 		for _, route := range b.Routes {
 
-			// Check if the route is a message route
-			if route.App.GetType()&AppTypeMessage == 0 {
+			// Check if the route is a message route enabled in this server
+			if route.App.GetType()&AppTypeMessage == 0 || disabled[route.Name] {
 				continue
 			}
 
@@ -210,9 +306,11 @@ func (b *Bot) messageCreateHandler() func(s *discordgo.Session, m *discordgo.Mes
 				"type":  "message",
 				"user":  user.ID,
 			}))(ctx)
+			withRouteKey(route.Name)(ctx)
 
 			// Execute the app
-			route.App.(ApplicationMessage).OnMessage(ctx, channel)
+			app := route.App.(ApplicationMessage)
+			b.dispatch(ctx, b.routeTimeout(route, route.Name), func(ctx *Context) { app.OnMessage(ctx, channel) })
 		}
 	}
 }
@@ -226,8 +324,11 @@ func (b *Bot) reactionAddHandler() func(s *discordgo.Session, r *discordgo.Messa
 		ctx := NewContext(
 			withSession(s),
 			withDatabase(b.db),
+			withPublisher(b.Publish),
 			withReaction(r.MessageReaction, true),
+			withGuildId(r.GuildID),
 		)
+		disabled := b.disabledApps(r.GuildID)
 
 		// Get the user from the reaction
 		user := r.Member.User
@@ -235,8 +336,8 @@ func (b *Bot) reactionAddHandler() func(s *discordgo.Session, r *discordgo.Messa
 		// Test a regex match for the channel name against the rule
 		for _, route := range b.Routes {
 
-			// Check if the route is a reaction route
-			if route.App.GetType()&AppTypeReaction == 0 {
+			// Check if the route is a reaction route enabled in this server
+			if route.App.GetType()&AppTypeReaction == 0 || disabled[route.Name] {
 				continue
 			}
 
@@ -246,9 +347,11 @@ func (b *Bot) reactionAddHandler() func(s *discordgo.Session, r *discordgo.Messa
 				"type":  "reaction_add",
 				"user":  user.ID,
 			}))(ctx)
+			withRouteKey(route.Name)(ctx)
 
 			// Execute the app
-			route.App.(ApplicationReaction).OnReaction(ctx)
+			app := route.App.(ApplicationReaction)
+			b.dispatch(ctx, b.routeTimeout(route, route.Name), func(ctx *Context) { app.OnReaction(ctx) })
 		}
 	}
 }
@@ -262,14 +365,17 @@ func (b *Bot) reactionRemoveHandler() func(s *discordgo.Session, r *discordgo.Me
 		ctx := NewContext(
 			withSession(s),
 			withDatabase(b.db),
+			withPublisher(b.Publish),
 			withReaction(r.MessageReaction, false),
+			withGuildId(r.GuildID),
 		)
+		disabled := b.disabledApps(r.GuildID)
 
 		// Test a regex match for the channel name against the rule
 		for _, route := range b.Routes {
 
-			// Check if the route is a reaction route
-			if route.App.GetType()&AppTypeReaction == 0 {
+			// Check if the route is a reaction route enabled in this server
+			if route.App.GetType()&AppTypeReaction == 0 || disabled[route.Name] {
 				continue
 			}
 
@@ -279,49 +385,72 @@ func (b *Bot) reactionRemoveHandler() func(s *discordgo.Session, r *discordgo.Me
 				"type":  "reaction_remove",
 				"user":  r.UserID,
 			}))(ctx)
+			withRouteKey(route.Name)(ctx)
 
 			// Execute the app
-			route.App.(ApplicationReaction).OnReaction(ctx)
+			app := route.App.(ApplicationReaction)
+			b.dispatch(ctx, b.routeTimeout(route, route.Name), func(ctx *Context) { app.OnReaction(ctx) })
 		}
 	}
 }
 
 func (b *Bot) registerAllCommandsAndRouting() {
-	b.registerDiscordApplicationCommands()
+	b.syncDiscordApplicationCommands()
 
 	// Handle the route execution
 	b.Discord.AddHandler(b.interactionCreateHandler())
 	b.Discord.AddHandler(b.messageCreateHandler())
 	b.Discord.AddHandler(b.reactionAddHandler())
 	b.Discord.AddHandler(b.reactionRemoveHandler())
+	b.Discord.AddHandler(b.messageUpdateHandler())
+	b.Discord.AddHandler(b.messageDeleteHandler())
+	b.Discord.AddHandler(b.reactionRemoveAllHandler())
+	b.Discord.AddHandler(b.memberAddHandler())
+	b.Discord.AddHandler(b.memberRemoveHandler())
+	b.Discord.AddHandler(b.threadCreateHandler())
 }
 
-func (b *Bot) deregisterAllCommands() {
-	// Delete the route from Discord
-	for route, app := range b.registeredDiscordApplications {
-		b.Discord.ApplicationCommandDelete(b.Discord.State.User.ID, b.serverId, app.ID)
-
-		b.lg.Infof("Deregistered Application: %s", route)
+func (b *Bot) Run() error {
+	// Members joining and leaving are only sent with the server members
+	// intent, which also has to be enabled on the developer portal
+	if b.handles(AppTypeMember) {
+		b.Discord.Identify.Intents |= discordgo.IntentsGuildMembers
 	}
 
-	// Clear the registered applications
-	b.registeredDiscordApplications = make(map[string]*discordgo.ApplicationCommand)
-}
-
-func (b *Bot) Run() error {
+	b.watchGateway()
 	if err := b.Discord.Open(); err != nil {
 		return err
 	}
 	b.registerAllCommandsAndRouting()
+	b.mountRoutes(b.Discord)
+
+	return nil
+}
+
+// mountRoutes runs the OnMount function for each route and subroute
+func (b *Bot) mountRoutes(s Session) {
+	b.mounted = s
 
 	ctx := NewContext(
-		withSession(b.Discord),
+		withSession(s),
 		withDatabase(b.db),
+		withPublisher(b.Publish),
+		withScheduler(b.Scheduler()),
 	)
 
 	// Run the OnMount function for each route
-	for _, route := range b.Routes {
+	b.mount(ctx, b.Routes)
 
+	// Start running jobs once the apps have added their handlers
+	if b.db != nil {
+		b.Scheduler().start()
+	}
+}
+
+// mount runs the OnMount function of the routes and, through any subcommand
+// groups, all of their subroutes
+func (b *Bot) mount(ctx *Context, routes []Route) {
+	for _, route := range routes {
 		withLogger(b.lg.WithFields(log.Fields{
 			"route": route.Name,
 			"type":  "mount",
@@ -331,22 +460,27 @@ func (b *Bot) Run() error {
 			route.App.(ApplicationMountable).OnMount(ctx)
 		}
 
-		for _, subroute := range route.Subroutes {
-			withLogger(b.lg.WithFields(log.Fields{
-				"route": subroute.Name,
-				"type":  "mount",
-			}))(ctx)
-
-			if subroute.App.GetType()&AppTypeMountable != 0 {
-				subroute.App.(ApplicationMountable).OnMount(ctx)
-			}
-		}
+		b.mount(ctx, route.Subroutes)
 	}
-
-	return nil
 }
 
 func (b *Bot) Close() error {
-	b.deregisterAllCommands()
+	if b.scheduler != nil {
+		b.scheduler.close()
+	}
+
+	if b.httpServer != nil {
+		b.httpServer.Close()
+	}
+
+	if b.metricsServer != nil {
+		b.metricsServer.Close()
+	}
+
+	if b.configStop != nil {
+		close(b.configStop)
+		b.configStop = nil
+	}
+
 	return b.Discord.Close()
 }
@@ -0,0 +1,103 @@
+package framework
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BusContext is handed to the subscribers of an event. It uses the bot's
+// session and database, the server the event happened in is part of the event.
+type BusContext interface {
+	Context() context.Context
+	Session() Session
+	Database() *gorm.DB
+	Logger() *log.Entry
+	Publish(event any)
+}
+
+// EventBus hands the domain events apps publish, such as a wallet transaction
+// or a finished race, to the apps which subscribed to them so neither has to
+// import the other. Events are structs published by value and subscribers are
+// found by the event's type.
+type EventBus struct {
+	subscribers map[reflect.Type][]func(ctx *Context, event any)
+	mu          sync.RWMutex
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[reflect.Type][]func(ctx *Context, event any))}
+}
+
+// Subscribe calls the handler with each event of type E published on the bus,
+// after the subscribers before it.
+func Subscribe[E any](bus *EventBus, handler func(ctx BusContext, event E)) {
+	eventType := reflect.TypeOf((*E)(nil)).Elem()
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.subscribers[eventType] = append(bus.subscribers[eventType], func(ctx *Context, event any) {
+		handler(ctx, event.(E))
+	})
+}
+
+// publish runs the subscribers of the event one after the other. A subscriber
+// which panics is logged and does not stop the others.
+func (bus *EventBus) publish(ctx *Context, event any) {
+	bus.mu.RLock()
+	subscribers := bus.subscribers[reflect.TypeOf(event)]
+	bus.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					ctx.Logger().Errorf("Event subscriber panicked: %v", r)
+				}
+			}()
+
+			subscriber(ctx, event)
+		}()
+	}
+}
+
+// Events returns the bot's event bus for apps to subscribe to
+func (b *Bot) Events() *EventBus {
+	b.busOnce.Do(func() {
+		if b.bus == nil {
+			b.bus = NewEventBus()
+		}
+	})
+	return b.bus
+}
+
+// Publish hands the event to its subscribers before returning, so subscribers
+// with slow work should do it in a goroutine. It can be passed to packages
+// which do not depend on the framework to publish their events.
+func (b *Bot) Publish(event any) {
+	ctx := NewContext(
+		withSession(b.session()),
+		withDatabase(b.db),
+		withPublisher(b.Publish),
+		withLogger(b.lg.WithField("event", reflect.TypeOf(event).String())),
+	)
+
+	b.Events().publish(ctx, event)
+}
+
+// session returns the session the routes were mounted with, or the Discord
+// session before then
+func (b *Bot) session() Session {
+	if b.mounted != nil {
+		return b.mounted
+	}
+	if b.Discord != nil {
+		return b.Discord
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package framework
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type racePlaced struct {
+	UserId string
+	Place  int
+}
+
+type raceCancelled struct {
+	RaceId string
+}
+
+// podiumApp publishes a racePlaced event for the user running the command
+type podiumApp struct{}
+
+func (a podiumApp) GetType() AppType {
+	return AppTypeCommand
+}
+
+func (a podiumApp) GetDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{Name: "podium"}
+}
+
+func (a podiumApp) OnCommand(ctx CommandContext) {
+	ctx.Publish(racePlaced{UserId: ctx.GetUser().ID, Place: 1})
+}
+
+func TestEventBus(t *testing.T) {
+	bot := &Bot{lg: log.WithField("src", "test"), mounted: NewRecordingSession()}
+	bot.Register(NewRoute(bot, "podium", podiumApp{}))
+
+	var received []string
+	Subscribe(bot.Events(), func(ctx BusContext, event racePlaced) {
+		received = append(received, "first "+event.UserId)
+		ctx.Publish(raceCancelled{RaceId: "race"})
+	})
+	Subscribe(bot.Events(), func(ctx BusContext, event racePlaced) {
+		panic("subscriber failed")
+	})
+	Subscribe(bot.Events(), func(ctx BusContext, event racePlaced) {
+		received = append(received, "third "+event.UserId)
+		ctx.Session().ChannelMessageSend("channel", "Congratulations")
+	})
+	Subscribe(bot.Events(), func(ctx BusContext, event raceCancelled) {
+		received = append(received, "cancelled "+event.RaceId)
+	})
+
+	bot.HandleInteraction(NewRecordingSession(), &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:     "interaction",
+		Type:   discordgo.InteractionApplicationCommand,
+		Member: &discordgo.Member{User: &discordgo.User{ID: "user", Username: "user"}},
+		Data:   discordgo.ApplicationCommandInteractionData{Name: "podium"},
+	}})
+
+	// Events without subscribers are dropped
+	bot.Publish(struct{}{})
+
+	expected := []string{"first user", "cancelled race", "third user"}
+	if len(received) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, received)
+	}
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], received[i])
+		}
+	}
+
+	if message := bot.mounted.(*RecordingSession).LastMessage(); message == nil || message.Content != "Congratulations" {
+		t.Errorf("Expected subscribers to use the bot's session, got %+v", message)
+	}
+}
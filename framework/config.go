@@ -0,0 +1,118 @@
+package framework
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrNoConfig   = errors.New("no config has been loaded")
+	ErrConfigType = errors.New("config is not of the expected type")
+)
+
+// configPollInterval is how often a watched config file is checked for
+// changes
+var configPollInterval = 5 * time.Second
+
+// ConfigLoader loads and validates the configuration, it is called again
+// each time the configuration is reloaded
+type ConfigLoader func() (any, error)
+
+// Configure sets the configuration and passes it to the config apps. Routes
+// registered afterwards are given the configuration when they are
+// registered.
+func (b *Bot) Configure(config any) {
+	b.configMu.Lock()
+	b.config = config
+	routes := b.Routes
+	b.configMu.Unlock()
+
+	b.configure(config, routes)
+}
+
+// configure runs the OnConfig function of the routes and their subroutes
+func (b *Bot) configure(config any, routes []Route) {
+	for _, route := range routes {
+		b.configure(config, route.Subroutes)
+
+		if route.App.GetType()&AppTypeConfig == 0 {
+			continue
+		}
+
+		ctx := NewContext(
+			withSession(b.session()),
+			withDatabase(b.db),
+			withConfig(config),
+			withLogger(b.lg.WithFields(log.Fields{
+				"route": route.Name,
+				"type":  "config",
+			})),
+		)
+
+		route.App.(ApplicationConfig).OnConfig(ctx)
+	}
+}
+
+// WatchConfig reloads the configuration when the bot is sent SIGHUP or the
+// file at path changes. A configuration which fails to load is logged and
+// the current one is kept. The watch stops when the bot is closed.
+func (b *Bot) WatchConfig(path string, load ConfigLoader) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	stop := make(chan struct{})
+	b.configStop = stop
+
+	modified := configModTime(path)
+
+	go func() {
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+		defer signal.Stop(hangup)
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-hangup:
+				b.lg.Info("Reloading config after SIGHUP")
+			case <-ticker.C:
+				latest := configModTime(path)
+				if latest.Equal(modified) {
+					continue
+				}
+				modified = latest
+				b.lg.WithField("path", path).Info("Reloading config after the file changed")
+			}
+
+			b.reloadConfig(load)
+		}
+	}()
+}
+
+// reloadConfig loads the configuration and passes it to the apps, keeping
+// the current configuration if it fails to load
+func (b *Bot) reloadConfig(load ConfigLoader) {
+	config, err := load()
+	if err != nil {
+		b.lg.WithError(err).Error("Failed to reload config, keeping the current config")
+		return
+	}
+
+	b.Configure(config)
+}
+
+// configModTime returns when the file was last modified, or the zero time if
+// it can't be read
+func configModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,149 @@
+package framework
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type tableConfig struct {
+	MaxPlayers int
+}
+
+// tableSettingsApp keeps the max players from each config it is given
+type tableSettingsApp struct {
+	mu      sync.Mutex
+	players []int
+}
+
+func (a *tableSettingsApp) GetType() AppType {
+	return AppTypeConfig
+}
+
+func (a *tableSettingsApp) OnConfig(ctx ConfigContext) {
+	var cfg tableConfig
+	if err := ctx.Config(&cfg); err != nil {
+		ctx.Logger().WithError(err).Error("Failed to read config")
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.players = append(a.players, cfg.MaxPlayers)
+}
+
+// latest waits for the app to be given the config, returning the last max
+// players it was given
+func (a *tableSettingsApp) latest(want int) int {
+	deadline := time.Now().Add(time.Second)
+	for {
+		a.mu.Lock()
+		got := 0
+		if len(a.players) > 0 {
+			got = a.players[len(a.players)-1]
+		}
+		a.mu.Unlock()
+
+		if got == want || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newConfigBot() *Bot {
+	discord, _ := discordgo.New("Bot token")
+	return &Bot{Discord: discord, lg: log.WithField("src", "test"), mounted: NewRecordingSession()}
+}
+
+func TestConfigure(t *testing.T) {
+	bot := newConfigBot()
+
+	table := &tableSettingsApp{}
+	bot.Register(NewRoute(bot, "games", &greetApp{}, NewRoute(bot, "table", table)))
+
+	bot.Configure(tableConfig{MaxPlayers: 7})
+	if got := table.latest(7); got != 7 {
+		t.Errorf("Expected the subroute to be given the config, got %d", got)
+	}
+
+	// Routes registered after the config is loaded are given it straight away
+	later := &tableSettingsApp{}
+	bot.Register(NewRoute(bot, "later", later))
+	if got := later.latest(7); got != 7 {
+		t.Errorf("Expected a route registered later to be given the config, got %d", got)
+	}
+
+	// Reading the config as the wrong type fails
+	ctx := NewContext(withConfig(tableConfig{MaxPlayers: 7}))
+	var wrong struct{ MaxPlayers int }
+	if err := ctx.Config(&wrong); !errors.Is(err, ErrConfigType) {
+		t.Errorf("Expected ErrConfigType, got %v", err)
+	}
+	if err := NewContext().Config(&tableConfig{}); err != ErrNoConfig {
+		t.Errorf("Expected ErrNoConfig, got %v", err)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	interval := configPollInterval
+	configPollInterval = 10 * time.Millisecond
+	defer func() { configPollInterval = interval }()
+
+	path := t.TempDir() + "/config"
+	writeSetting := func(value string, modified time.Time) {
+		if err := os.WriteFile(path, []byte(value), 0o644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		os.Chtimes(path, modified, modified)
+	}
+
+	load := func() (any, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		players, err := strconv.Atoi(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return tableConfig{MaxPlayers: players}, nil
+	}
+
+	bot := newConfigBot()
+	table := &tableSettingsApp{}
+	bot.Register(NewRoute(bot, "table", table))
+
+	start := time.Now().Add(-time.Hour)
+	writeSetting("7", start)
+	bot.WatchConfig(path, load)
+	defer bot.Close()
+
+	// Changing the file reloads the config
+	writeSetting("4", start.Add(time.Minute))
+	if got := table.latest(4); got != 4 {
+		t.Fatalf("Expected the config to be reloaded after the file changed, got %d", got)
+	}
+
+	// A config which fails to load keeps the current one
+	writeSetting("lots", start.Add(2*time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if got := table.latest(4); got != 4 {
+		t.Fatalf("Expected the current config to be kept, got %d", got)
+	}
+
+	// SIGHUP reloads the config even if the file looks unchanged
+	writeSetting("3", start.Add(2*time.Minute))
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	if got := table.latest(3); got != 3 {
+		t.Errorf("Expected the config to be reloaded after SIGHUP, got %d", got)
+	}
+}
@@ -0,0 +1,794 @@
+package framework
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	consoleGuildId   = "console"
+	consoleChannelId = "console"
+)
+
+const consoleHelp = `Commands:
+  /<command> [subcommand] [option:value ...]   run a slash command, users are @name
+  !complete /<command> ... option:partial      ask for autocomplete choices
+  !user "<command>" @name                      run a user context menu command
+  !message "<command>" <messageId>             run a message context menu command
+  !press <customId>                            press a button
+  !select <customId> <value> [value ...]       choose from a select menu
+  !submit <customId> [field:value ...]         submit a modal
+  !as <name>                                   act as another user
+  !help                                        show this help
+  !quit                                        exit the console
+`
+
+// ConsoleSession is a RecordingSession which also prints every message, response
+// and edit it receives as text, so the bot can be used from a terminal.
+type ConsoleSession struct {
+	*RecordingSession
+
+	// modals are the modals which have been shown by CustomID, so they can be
+	// submitted with every field in the right order
+	modals map[string]*discordgo.InteractionResponseData
+
+	out io.Writer
+	mu  sync.Mutex
+}
+
+func NewConsoleSession(out io.Writer) *ConsoleSession {
+	return &ConsoleSession{
+		RecordingSession: NewRecordingSession(),
+		modals:           make(map[string]*discordgo.InteractionResponseData),
+		out:              out,
+	}
+}
+
+func (s *ConsoleSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.RecordingSession.InteractionRespond(interaction, resp)
+
+	data := resp.Data
+	if data == nil {
+		data = &discordgo.InteractionResponseData{}
+	}
+
+	switch resp.Type {
+	case discordgo.InteractionResponseChannelMessageWithSource:
+		message := &discordgo.Message{
+			ID:         interaction.ID,
+			ChannelID:  interaction.ChannelID,
+			Content:    data.Content,
+			Embeds:     data.Embeds,
+			Components: data.Components,
+		}
+		if data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+			s.addMessage(message)
+		}
+		s.printMessage("reply", message, data.Flags&discordgo.MessageFlagsEphemeral != 0)
+
+	case discordgo.InteractionResponseUpdateMessage:
+		if interaction.Message == nil {
+			return nil
+		}
+		message := s.updateMessage(interaction.ChannelID, interaction.Message.ID, data)
+		s.printMessage("update", message, false)
+
+	case discordgo.InteractionResponseDeferredChannelMessageWithSource:
+		s.print("[thinking...]\n")
+
+	case discordgo.InteractionResponseModal:
+		s.mu.Lock()
+		s.modals[data.CustomID] = data
+		s.mu.Unlock()
+		s.printModal(data)
+
+	case discordgo.InteractionApplicationCommandAutocompleteResult:
+		var b strings.Builder
+		b.WriteString("[choices]\n")
+		for _, choice := range data.Choices {
+			fmt.Fprintf(&b, "  %s = %v\n", choice.Name, choice.Value)
+		}
+		s.print(b.String())
+	}
+
+	return nil
+}
+
+func (s *ConsoleSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.RecordingSession.InteractionResponseEdit(interaction, newresp)
+
+	data := &discordgo.InteractionResponseData{}
+	if newresp.Content != nil {
+		data.Content = *newresp.Content
+	}
+	if newresp.Embeds != nil {
+		data.Embeds = *newresp.Embeds
+	}
+	if newresp.Components != nil {
+		data.Components = *newresp.Components
+	}
+
+	// The original response to a component is the message it is on
+	messageId := interaction.ID
+	if interaction.Type == discordgo.InteractionMessageComponent && interaction.Message != nil {
+		messageId = interaction.Message.ID
+	}
+
+	message := s.updateMessage(interaction.ChannelID, messageId, data)
+	if message == nil {
+		message = &discordgo.Message{ID: messageId, ChannelID: interaction.ChannelID}
+		message.Content, message.Embeds, message.Components = data.Content, data.Embeds, data.Components
+		s.addMessage(message)
+	}
+
+	s.printMessage("edit", message, false)
+	return message, nil
+}
+
+func (s *ConsoleSession) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	message, err := s.RecordingSession.FollowupMessageCreate(interaction, wait, data)
+	if err != nil {
+		return nil, err
+	}
+
+	message.Embeds = data.Embeds
+	message.Components = data.Components
+	if data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		s.addMessage(message)
+	}
+
+	s.printMessage("followup", message, data.Flags&discordgo.MessageFlagsEphemeral != 0)
+	return message, nil
+}
+
+func (s *ConsoleSession) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content})
+}
+
+func (s *ConsoleSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+func (s *ConsoleSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	message, err := s.RecordingSession.ChannelMessageSendComplex(channelID, data)
+	if err != nil {
+		return nil, err
+	}
+
+	s.printMessage("message", message, false)
+	return message, nil
+}
+
+func (s *ConsoleSession) ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	edit := discordgo.NewMessageEdit(channelID, messageID)
+	edit.Content = &content
+	return s.ChannelMessageEditComplex(edit)
+}
+
+func (s *ConsoleSession) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	message, err := s.RecordingSession.ChannelMessageEditComplex(m)
+	if err != nil {
+		return nil, err
+	}
+
+	s.printMessage("edit", message, false)
+	return message, nil
+}
+
+func (s *ConsoleSession) ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error {
+	s.print(fmt.Sprintf("[delete #%s]\n", messageID))
+	return s.RecordingSession.ChannelMessageDelete(channelID, messageID)
+}
+
+func (s *ConsoleSession) ChannelMessagePin(channelID, messageID string, options ...discordgo.RequestOption) error {
+	s.print(fmt.Sprintf("[pin #%s]\n", messageID))
+	return s.RecordingSession.ChannelMessagePin(channelID, messageID)
+}
+
+func (s *ConsoleSession) ChannelMessageUnpin(channelID, messageID string, options ...discordgo.RequestOption) error {
+	s.print(fmt.Sprintf("[unpin #%s]\n", messageID))
+	return s.RecordingSession.ChannelMessageUnpin(channelID, messageID)
+}
+
+func (s *ConsoleSession) MessageReactionAdd(channelID, messageID, emojiID string, options ...discordgo.RequestOption) error {
+	s.print(fmt.Sprintf("[react #%s] %s\n", messageID, emojiID))
+	return s.RecordingSession.MessageReactionAdd(channelID, messageID, emojiID)
+}
+
+// addMessage records a message which did not go through
+// ChannelMessageSendComplex, such as an interaction reply, so its buttons can
+// be pressed and it can be edited later.
+func (s *ConsoleSession) addMessage(message *discordgo.Message) {
+	s.RecordingSession.Lock()
+	defer s.RecordingSession.Unlock()
+
+	s.Messages = append(s.Messages, message)
+}
+
+// updateMessage applies the response data to a recorded message and returns
+// it, or nil if the message is unknown.
+func (s *ConsoleSession) updateMessage(channelId, messageId string, data *discordgo.InteractionResponseData) *discordgo.Message {
+	s.RecordingSession.Lock()
+	defer s.RecordingSession.Unlock()
+
+	message := s.FindMessage(channelId, messageId)
+	if message == nil {
+		return nil
+	}
+
+	if data.Content != "" {
+		message.Content = data.Content
+	}
+	if data.Embeds != nil {
+		message.Embeds = data.Embeds
+	}
+	if data.Components != nil {
+		message.Components = data.Components
+	}
+	return message
+}
+
+// messageWithComponent returns the most recent message with a component
+// using the custom ID, or nil if there is none.
+func (s *ConsoleSession) messageWithComponent(customId string) *discordgo.Message {
+	s.RecordingSession.Lock()
+	defer s.RecordingSession.Unlock()
+
+	for i := len(s.Messages) - 1; i >= 0; i-- {
+		for _, component := range flattenComponents(s.Messages[i].Components) {
+			if componentCustomId(component) == customId {
+				return s.Messages[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ConsoleSession) modal(customId string) *discordgo.InteractionResponseData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.modals[customId]
+}
+
+func (s *ConsoleSession) print(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	io.WriteString(s.out, text)
+}
+
+func (s *ConsoleSession) printMessage(kind string, message *discordgo.Message, ephemeral bool) {
+	if message == nil {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s #%s]", kind, message.ID)
+	if ephemeral {
+		b.WriteString(" (only you can see this)")
+	}
+	b.WriteString("\n")
+
+	if message.Content != "" {
+		b.WriteString(indent(message.Content))
+	}
+
+	for _, embed := range message.Embeds {
+		writeEmbed(&b, embed)
+	}
+
+	for _, component := range flattenComponents(message.Components) {
+		writeComponent(&b, component)
+	}
+
+	s.print(b.String())
+}
+
+func (s *ConsoleSession) printModal(data *discordgo.InteractionResponseData) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[modal] %s (%s)\n", data.Title, data.CustomID)
+
+	fields := make([]string, 0)
+	for _, component := range flattenComponents(data.Components) {
+		if input, ok := asTextInput(component); ok {
+			fmt.Fprintf(&b, "  %s: %s\n", input.CustomID, input.Label)
+			fields = append(fields, input.CustomID+":...")
+		}
+	}
+	fmt.Fprintf(&b, "  submit with: !submit %s %s\n", data.CustomID, strings.Join(fields, " "))
+
+	s.print(b.String())
+}
+
+func indent(text string) string {
+	return "  " + strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\n  ") + "\n"
+}
+
+func writeEmbed(b *strings.Builder, embed *discordgo.MessageEmbed) {
+	if embed.Title != "" {
+		fmt.Fprintf(b, "  == %s ==\n", embed.Title)
+	}
+	if embed.Description != "" {
+		b.WriteString(indent(embed.Description))
+	}
+	for _, field := range embed.Fields {
+		fmt.Fprintf(b, "  %s: %s\n", field.Name, strings.ReplaceAll(field.Value, "\n", "\n    "))
+	}
+	if embed.Footer != nil && embed.Footer.Text != "" {
+		fmt.Fprintf(b, "  -- %s\n", embed.Footer.Text)
+	}
+}
+
+func writeComponent(b *strings.Builder, component discordgo.MessageComponent) {
+	switch c := component.(type) {
+	case discordgo.Button:
+		writeButton(b, &c)
+	case *discordgo.Button:
+		writeButton(b, c)
+	case discordgo.SelectMenu:
+		writeSelectMenu(b, &c)
+	case *discordgo.SelectMenu:
+		writeSelectMenu(b, c)
+	}
+}
+
+func writeButton(b *strings.Builder, button *discordgo.Button) {
+	label := button.Label
+	if button.Emoji != nil && button.Emoji.Name != "" {
+		label = strings.TrimSpace(button.Emoji.Name + " " + label)
+	}
+
+	if button.Style == discordgo.LinkButton {
+		fmt.Fprintf(b, "  [%s] %s\n", label, button.URL)
+		return
+	}
+
+	disabled := ""
+	if button.Disabled {
+		disabled = " disabled"
+	}
+	fmt.Fprintf(b, "  [%s] (%s)%s\n", label, button.CustomID, disabled)
+}
+
+func writeSelectMenu(b *strings.Builder, menu *discordgo.SelectMenu) {
+	fmt.Fprintf(b, "  <%s> (%s)\n", menu.Placeholder, menu.CustomID)
+	for _, option := range menu.Options {
+		fmt.Fprintf(b, "    - %s = %s\n", option.Label, option.Value)
+	}
+}
+
+// flattenComponents returns the components inside the action rows
+func flattenComponents(components []discordgo.MessageComponent) []discordgo.MessageComponent {
+	flat := make([]discordgo.MessageComponent, 0)
+	for _, component := range components {
+		switch row := component.(type) {
+		case discordgo.ActionsRow:
+			flat = append(flat, row.Components...)
+		case *discordgo.ActionsRow:
+			flat = append(flat, row.Components...)
+		default:
+			flat = append(flat, component)
+		}
+	}
+	return flat
+}
+
+func componentCustomId(component discordgo.MessageComponent) string {
+	switch c := component.(type) {
+	case discordgo.Button:
+		return c.CustomID
+	case *discordgo.Button:
+		return c.CustomID
+	case discordgo.SelectMenu:
+		return c.CustomID
+	case *discordgo.SelectMenu:
+		return c.CustomID
+	}
+	return ""
+}
+
+func asTextInput(component discordgo.MessageComponent) (*discordgo.TextInput, bool) {
+	switch c := component.(type) {
+	case discordgo.TextInput:
+		return &c, true
+	case *discordgo.TextInput:
+		return c, true
+	}
+	return nil, false
+}
+
+// Console runs the bot routes from a terminal instead of Discord. Each line
+// read is turned into a synthetic interaction and dispatched through the same
+// routes and middleware as the gateway.
+type Console struct {
+	bot     *Bot
+	session *ConsoleSession
+
+	user     *discordgo.User
+	nextId   int
+	commands map[string]*discordgo.ApplicationCommand
+}
+
+// RunConsole mounts the routes and reads commands from in until it is closed
+// or "!quit" is entered. All output is written to out.
+func (b *Bot) RunConsole(in io.Reader, out io.Writer) error {
+	console := NewConsole(b, NewConsoleSession(out))
+	b.mountRoutes(console.session)
+
+	fmt.Fprintf(out, "Tony console, acting as @%s. Type !help for commands.\n", console.user.Username)
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			break
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "!quit" || line == "!exit" {
+			return nil
+		}
+
+		if err := console.Execute(line); err != nil {
+			fmt.Fprintf(out, "error: %s\n", err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func NewConsole(bot *Bot, session *ConsoleSession) *Console {
+	console := &Console{
+		bot:      bot,
+		session:  session,
+		commands: make(map[string]*discordgo.ApplicationCommand),
+	}
+
+	for _, route := range bot.Routes {
+		if route.App.GetType()&AppTypeCommand != 0 {
+			definition := route.commandDefinition()
+			console.commands[definition.Name] = definition
+		}
+	}
+
+	console.user = console.lookupUser("dev")
+	return console
+}
+
+// Execute runs a single console line
+func (c *Console) Execute(line string) error {
+	args, err := splitArgs(line)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	if strings.HasPrefix(args[0], "/") {
+		return c.command(args, discordgo.InteractionApplicationCommand)
+	}
+
+	switch args[0] {
+	case "!help":
+		c.session.print(consoleHelp)
+		return nil
+
+	case "!as":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: !as <name>")
+		}
+		c.user = c.lookupUser(args[1])
+		c.session.print(fmt.Sprintf("Now acting as @%s\n", c.user.Username))
+		return nil
+
+	case "!complete":
+		if len(args) < 2 || !strings.HasPrefix(args[1], "/") {
+			return fmt.Errorf("usage: !complete /<command> ... option:partial")
+		}
+		return c.command(args[1:], discordgo.InteractionApplicationCommandAutocomplete)
+
+	case "!user":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: !user \"<command>\" @name")
+		}
+		return c.userCommand(args[1], args[2])
+
+	case "!message":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: !message \"<command>\" <messageId>")
+		}
+		return c.messageCommand(args[1], args[2])
+
+	case "!press":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: !press <customId>")
+		}
+		return c.component(args[1], discordgo.ButtonComponent, nil)
+
+	case "!select":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: !select <customId> <value> [value ...]")
+		}
+		return c.component(args[1], discordgo.SelectMenuComponent, args[2:])
+
+	case "!submit":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: !submit <customId> [field:value ...]")
+		}
+		return c.submit(args[1], args[2:])
+	}
+
+	return fmt.Errorf("unknown command %q, type !help for commands", args[0])
+}
+
+// lookupUser returns the console user with the name, creating it if it does
+// not exist yet. Console users use their name as their ID.
+func (c *Console) lookupUser(name string) *discordgo.User {
+	name = strings.TrimPrefix(name, "@")
+
+	c.session.RecordingSession.Lock()
+	defer c.session.RecordingSession.Unlock()
+
+	if user, ok := c.session.Users[name]; ok {
+		return user
+	}
+
+	user := &discordgo.User{ID: name, Username: name, GlobalName: name}
+	c.session.Users[name] = user
+	return user
+}
+
+func (c *Console) interaction(t discordgo.InteractionType, data discordgo.InteractionData) *discordgo.Interaction {
+	c.nextId++
+
+	return &discordgo.Interaction{
+		ID:        fmt.Sprintf("i%d", c.nextId),
+		Type:      t,
+		GuildID:   consoleGuildId,
+		ChannelID: consoleChannelId,
+		Member: &discordgo.Member{
+			GuildID:     consoleGuildId,
+			User:        c.user,
+			Permissions: discordgo.PermissionAll,
+		},
+		Data: data,
+	}
+}
+
+func (c *Console) handle(i *discordgo.Interaction) error {
+	c.bot.HandleInteraction(c.session, &discordgo.InteractionCreate{Interaction: i})
+	return nil
+}
+
+// command builds a slash command interaction from "/name [sub] opt:value"
+func (c *Console) command(args []string, t discordgo.InteractionType) error {
+	name := strings.TrimPrefix(args[0], "/")
+	definition, ok := c.commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command /%s", name)
+	}
+
+	resolved := &discordgo.ApplicationCommandInteractionDataResolved{
+		Users: make(map[string]*discordgo.User),
+	}
+
+	data := discordgo.ApplicationCommandInteractionData{
+		ID:          name,
+		Name:        name,
+		CommandType: discordgo.ChatApplicationCommand,
+		Resolved:    resolved,
+	}
+
+	// Walk down the subcommand groups and subcommands
+	defOptions := definition.Options
+	options := &data.Options
+	rest := args[1:]
+	for len(rest) > 0 && !strings.Contains(rest[0], ":") {
+		def := findOption(defOptions, rest[0])
+		if def == nil || (def.Type != discordgo.ApplicationCommandOptionSubCommand && def.Type != discordgo.ApplicationCommandOptionSubCommandGroup) {
+			return fmt.Errorf("unknown subcommand %q", rest[0])
+		}
+
+		option := &discordgo.ApplicationCommandInteractionDataOption{Name: def.Name, Type: def.Type}
+		*options = append(*options, option)
+
+		defOptions = def.Options
+		options = &option.Options
+		rest = rest[1:]
+	}
+
+	// Parse the options using the definition for their types
+	for _, arg := range rest {
+		key, raw, ok := strings.Cut(arg, ":")
+		if !ok {
+			return fmt.Errorf("expected option:value, got %q", arg)
+		}
+
+		optionType := discordgo.ApplicationCommandOptionString
+		if def := findOption(defOptions, key); def != nil {
+			optionType = def.Type
+		}
+
+		value, err := c.optionValue(optionType, raw, resolved)
+		if err != nil {
+			return fmt.Errorf("option %s: %w", key, err)
+		}
+
+		*options = append(*options, &discordgo.ApplicationCommandInteractionDataOption{
+			Name:  key,
+			Type:  optionType,
+			Value: value,
+		})
+	}
+
+	// The last option is the one being typed when autocompleting
+	if t == discordgo.InteractionApplicationCommandAutocomplete {
+		if len(*options) == 0 {
+			return fmt.Errorf("no option to autocomplete")
+		}
+		(*options)[len(*options)-1].Focused = true
+	}
+
+	return c.handle(c.interaction(t, data))
+}
+
+func (c *Console) optionValue(t discordgo.ApplicationCommandOptionType, raw string, resolved *discordgo.ApplicationCommandInteractionDataResolved) (interface{}, error) {
+	switch t {
+	case discordgo.ApplicationCommandOptionInteger, discordgo.ApplicationCommandOptionNumber:
+		return strconv.ParseFloat(raw, 64)
+
+	case discordgo.ApplicationCommandOptionBoolean:
+		return strconv.ParseBool(raw)
+
+	case discordgo.ApplicationCommandOptionUser:
+		user := c.lookupUser(raw)
+		resolved.Users[user.ID] = user
+		return user.ID, nil
+	}
+
+	return raw, nil
+}
+
+func findOption(options []*discordgo.ApplicationCommandOption, name string) *discordgo.ApplicationCommandOption {
+	for _, option := range options {
+		if option.Name == name {
+			return option
+		}
+	}
+	return nil
+}
+
+func (c *Console) userCommand(name, target string) error {
+	user := c.lookupUser(target)
+
+	return c.handle(c.interaction(discordgo.InteractionApplicationCommand, discordgo.ApplicationCommandInteractionData{
+		ID:          name,
+		Name:        name,
+		CommandType: discordgo.UserApplicationCommand,
+		TargetID:    user.ID,
+		Resolved: &discordgo.ApplicationCommandInteractionDataResolved{
+			Users: map[string]*discordgo.User{user.ID: user},
+		},
+	}))
+}
+
+func (c *Console) messageCommand(name, messageId string) error {
+	c.session.RecordingSession.Lock()
+	message := c.session.FindMessage(consoleChannelId, messageId)
+	c.session.RecordingSession.Unlock()
+
+	if message == nil {
+		return fmt.Errorf("unknown message #%s", messageId)
+	}
+
+	return c.handle(c.interaction(discordgo.InteractionApplicationCommand, discordgo.ApplicationCommandInteractionData{
+		ID:          name,
+		Name:        name,
+		CommandType: discordgo.MessageApplicationCommand,
+		TargetID:    message.ID,
+		Resolved: &discordgo.ApplicationCommandInteractionDataResolved{
+			Messages: map[string]*discordgo.Message{message.ID: message},
+		},
+	}))
+}
+
+// component presses a button or chooses from a select menu on the most recent
+// message which has it
+func (c *Console) component(customId string, t discordgo.ComponentType, values []string) error {
+	message := c.session.messageWithComponent(customId)
+	if message == nil {
+		return fmt.Errorf("no message has a component %q", customId)
+	}
+
+	i := c.interaction(discordgo.InteractionMessageComponent, discordgo.MessageComponentInteractionData{
+		CustomID:      customId,
+		ComponentType: t,
+		Values:        values,
+	})
+	i.Message = message
+
+	return c.handle(i)
+}
+
+// submit fills in a modal, fields which are not given are left empty
+func (c *Console) submit(customId string, args []string) error {
+	values := make(map[string]string)
+	order := make([]string, 0)
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, ":")
+		if !ok {
+			return fmt.Errorf("expected field:value, got %q", arg)
+		}
+		values[key] = value
+		order = append(order, key)
+	}
+
+	// Use the field order of the modal if it has been shown
+	if modal := c.session.modal(customId); modal != nil {
+		order = make([]string, 0)
+		for _, component := range flattenComponents(modal.Components) {
+			if input, ok := asTextInput(component); ok {
+				order = append(order, input.CustomID)
+			}
+		}
+	}
+
+	rows := make([]discordgo.MessageComponent, 0, len(order))
+	for _, field := range order {
+		rows = append(rows, &discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				&discordgo.TextInput{CustomID: field, Value: values[field]},
+			},
+		})
+	}
+
+	return c.handle(c.interaction(discordgo.InteractionModalSubmit, discordgo.ModalSubmitInteractionData{
+		CustomID:   customId,
+		Components: rows,
+	}))
+}
+
+// splitArgs splits a line on spaces, keeping quoted sections together. Quotes
+// can be used around a whole argument or just the value, like
+// reason:"two words".
+func splitArgs(line string) ([]string, error) {
+	args := make([]string, 0)
+
+	var current strings.Builder
+	inArg, quote := false, rune(0)
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,110 @@
+package framework
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// greetApp replies to "/greet user:@name" with a button which can be pressed
+// to greet them again
+type greetApp struct{}
+
+func (a greetApp) GetType() AppType {
+	return AppTypeCommand | AppTypeEvent
+}
+
+func (a greetApp) GetDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name: "greet",
+		Options: []*discordgo.ApplicationCommandOption{
+			{Name: "user", Type: discordgo.ApplicationCommandOptionUser},
+			{Name: "times", Type: discordgo.ApplicationCommandOptionInteger},
+		},
+	}
+}
+
+func (a greetApp) OnCommand(ctx CommandContext) {
+	options := ctx.Interaction().ApplicationCommandData().Options
+	user := options[0].UserValue(nil)
+	times := options[1].IntValue()
+
+	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: strings.Repeat("Hello <@"+user.ID+">! ", int(times)),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{Label: "Again", CustomID: "greet:" + user.ID},
+					},
+				},
+			},
+		},
+	})
+}
+
+func (a greetApp) OnEvent(ctx EventContext, eventType discordgo.InteractionType) {
+	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content: ctx.GetUser().Username + " greeted " + ctx.EventValue() + " again",
+		},
+	})
+}
+
+func TestConsole(t *testing.T) {
+	bot := &Bot{lg: log.WithField("src", "test")}
+	bot.Register(NewRoute(bot, "greet", greetApp{}))
+
+	in := strings.NewReader(strings.Join([]string{
+		"/greet user:@alice times:2",
+		"!as bob",
+		"!press greet:alice",
+		"/missing",
+		"!quit",
+	}, "\n"))
+	out := &bytes.Buffer{}
+
+	if err := bot.RunConsole(in, out); err != nil {
+		t.Fatalf("Console failed: %v", err)
+	}
+
+	expected := []string{
+		"Hello <@alice>! Hello <@alice>!",
+		"[Again] (greet:alice)",
+		"Now acting as @bob",
+		"[update #i1]",
+		"bob greeted alice again",
+		"error: unknown command /missing",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`!user "Pay this user" reason:"two words"  @alice`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"!user", "Pay this user", "reason:two words", "@alice"}
+	if len(args) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, args)
+	}
+	for i := range expected {
+		if args[i] != expected[i] {
+			t.Errorf("Arg %d: expected %q, got %q", i, expected[i], args[i])
+		}
+	}
+
+	if _, err := splitArgs(`"open`); err == nil {
+		t.Errorf("Expected an error for an unterminated quote")
+	}
+}
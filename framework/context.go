@@ -2,6 +2,10 @@ package framework
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	log "github.com/sirupsen/logrus"
@@ -17,53 +21,147 @@ const (
 	ctxDatabase    ContextKey = "db"
 	ctxLogger      ContextKey = "logger"
 	ctxEventValue  ContextKey = "event_val"
+	ctxError       ContextKey = "error"
+	ctxRouteKey    ContextKey = "route_key"
+	ctxGuildId     ContextKey = "guild_id"
+	ctxStates      ContextKey = "component_states"
+	ctxState       ContextKey = "component_state"
+	ctxPublisher   ContextKey = "publisher"
+	ctxScheduler   ContextKey = "scheduler"
+	ctxJobPayload  ContextKey = "job_payload"
+	ctxConfig      ContextKey = "config"
 
 	ctxReactionValue ContextKey = "reaction_val"
 	ctxReactionAdd   ContextKey = "reaction_add"
+
+	ctxMessageBefore ContextKey = "message_before"
+	ctxMemberValue   ContextKey = "member_val"
+	ctxMemberJoin    ContextKey = "member_join"
+	ctxThread        ContextKey = "thread"
 )
 
 type StartupContext interface {
-	Session() *discordgo.Session
+	Session() Session
 	Database() *gorm.DB
 	Logger() *log.Entry
 }
 
 type MountContext interface {
 	StartupContext
+	Scheduler() *Scheduler
+}
+
+type ShutdownContext interface {
+	Context() context.Context
+	Session() Session
+	Database() *gorm.DB
+	Logger() *log.Entry
+	Publish(event any)
+}
+
+type ConfigContext interface {
+	StartupContext
+	Config(dst any) error
 }
 
 type CommandContext interface {
-	Session() *discordgo.Session
+	Context() context.Context
+	Session() Session
+	GuildID() string
 	Message() *discordgo.Message
 	Interaction() *discordgo.Interaction
 	GetOption(string) *discordgo.ApplicationCommandInteractionDataOption
+	Bind(any) error
 	GetUser() *discordgo.User
+	TargetUser() *discordgo.User
+	TargetMessage() *discordgo.Message
 	Database() *gorm.DB
 	Logger() *log.Entry
+	Fail(error)
+	Defer(ephemeral bool) error
+	EditResponse(*discordgo.WebhookEdit) (*discordgo.Message, error)
+	Followup(*discordgo.WebhookParams) (*discordgo.Message, error)
+	NewCustomID(customId string, payload any, ttl time.Duration) (string, error)
+	Publish(event any)
+	Scheduler() *Scheduler
 }
 
 type EventContext interface {
-	Session() *discordgo.Session
+	Context() context.Context
+	Session() Session
+	GuildID() string
 	Message() *discordgo.Message
 	GetUser() *discordgo.User
 	Interaction() *discordgo.Interaction
 	Database() *gorm.DB
 	Logger() *log.Entry
 	EventValue() string
+	State(any) error
+	Fail(error)
+	Defer(ephemeral bool) error
+	EditResponse(*discordgo.WebhookEdit) (*discordgo.Message, error)
+	Followup(*discordgo.WebhookParams) (*discordgo.Message, error)
+	NewCustomID(customId string, payload any, ttl time.Duration) (string, error)
+	Publish(event any)
+	Scheduler() *Scheduler
+}
+
+type AutocompleteContext interface {
+	Context() context.Context
+	Session() Session
+	GuildID() string
+	Interaction() *discordgo.Interaction
+	GetOption(string) *discordgo.ApplicationCommandInteractionDataOption
+	FocusedOption() *discordgo.ApplicationCommandInteractionDataOption
+	RespondChoices(...*discordgo.ApplicationCommandOptionChoice) error
+	GetUser() *discordgo.User
+	Database() *gorm.DB
+	Logger() *log.Entry
 }
 
 type MessageContext interface {
-	Session() *discordgo.Session
+	Context() context.Context
+	Session() Session
+	GuildID() string
 	Message() *discordgo.Message
 	Database() *gorm.DB
 	Logger() *log.Entry
+	Publish(event any)
 }
 
 type ReactionContext interface {
-	Session() *discordgo.Session
+	Context() context.Context
+	Session() Session
+	GuildID() string
 	Database() *gorm.DB
 	Logger() *log.Entry
 	Reaction() (*discordgo.MessageReaction, bool)
+	Publish(event any)
+}
+
+type MessageUpdateContext interface {
+	MessageContext
+	BeforeUpdate() *discordgo.Message
+}
+
+type MemberContext interface {
+	Context() context.Context
+	Session() Session
+	GuildID() string
+	Database() *gorm.DB
+	Logger() *log.Entry
+	Member() (*discordgo.Member, bool)
+	Publish(event any)
+}
+
+type ThreadContext interface {
+	Context() context.Context
+	Session() Session
+	GuildID() string
+	Database() *gorm.DB
+	Logger() *log.Entry
+	Thread() *discordgo.Channel
+	Publish(event any)
 }
 
 // Context is a wrapper around the context.Context type that includes
@@ -92,8 +190,47 @@ func NewContext(opts ...ContextOpt) *Context {
 	return dContext
 }
 
-func (c *Context) Session() *discordgo.Session {
-	return c.ctx.Value(ctxSession).(*discordgo.Session)
+// NewInteractionContext creates the context for an interaction with the route
+// key, event value and a logger for the route. It returns an error if the
+// interaction type can not be routed.
+func NewInteractionContext(s Session, db *gorm.DB, lg *log.Entry, i *discordgo.Interaction) (*Context, error) {
+	routeKey, eventValue, err := GetRouteKey(&discordgo.InteractionCreate{Interaction: i})
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the user from the interaction, direct messages have no member
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+
+	return NewContext(
+		withSession(s),
+		withDatabase(db),
+		withInteraction(i),
+		withMessage(i.Message),
+		withRouteKey(routeKey),
+		withEventValue(eventValue),
+		withGuildId(i.GuildID),
+		withLogger(lg.WithFields(log.Fields{
+			"guild": i.GuildID,
+			"route": routeKey,
+			"type":  i.Type.String(),
+			"user":  user.ID,
+		})),
+	), nil
+}
+
+// Context returns the context.Context of the handler. It is cancelled when
+// the route's timeout passes or the handler returns, and should be passed to
+// database queries and other calls which may block.
+func (c *Context) Context() context.Context {
+	return c.ctx
+}
+
+func (c *Context) Session() Session {
+	return c.ctx.Value(ctxSession).(Session)
 }
 
 func (c *Context) Message() *discordgo.Message {
@@ -113,18 +250,105 @@ func (c *Context) GetUser() *discordgo.User {
 	return user
 }
 
+// GetOption returns the named option of the subcommand being run, or nil if
+// it was not given.
 func (c *Context) GetOption(name string) *discordgo.ApplicationCommandInteractionDataOption {
-	interaction := c.Interaction()
-	options := interaction.ApplicationCommandData().Options[0].Options
+	opt, _ := GetOption(leafOptions(c.Interaction().ApplicationCommandData().Options), name)
+	return opt
+}
 
-	for _, opt := range options {
-		if opt.Name == name {
+// Bind decodes the command's options into the tagged fields of the struct dst
+// points to, see BindOptions.
+func (c *Context) Bind(dst any) error {
+	return BindOptions(c.Interaction().ApplicationCommandData(), dst)
+}
+
+// TargetUser returns the user a user context menu command was used on, or nil
+// for any other kind of command.
+func (c *Context) TargetUser() *discordgo.User {
+	data := c.Interaction().ApplicationCommandData()
+	if data.CommandType != discordgo.UserApplicationCommand || data.Resolved == nil {
+		return nil
+	}
+	return data.Resolved.Users[data.TargetID]
+}
+
+// TargetMessage returns the message a message context menu command was used
+// on, or nil for any other kind of command.
+func (c *Context) TargetMessage() *discordgo.Message {
+	data := c.Interaction().ApplicationCommandData()
+	if data.CommandType != discordgo.MessageApplicationCommand || data.Resolved == nil {
+		return nil
+	}
+	return data.Resolved.Messages[data.TargetID]
+}
+
+// FocusedOption returns the option the user is currently typing in an
+// autocomplete interaction, or nil if there is none.
+func (c *Context) FocusedOption() *discordgo.ApplicationCommandInteractionDataOption {
+	return focusedOption(c.Interaction().ApplicationCommandData().Options)
+}
+
+func focusedOption(opts []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, opt := range opts {
+		if opt.Focused {
 			return opt
 		}
+
+		if focused := focusedOption(opt.Options); focused != nil {
+			return focused
+		}
 	}
 	return nil
 }
 
+// RespondChoices responds to an autocomplete interaction with the suggested
+// choices. Discord only displays the first 25 choices.
+func (c *Context) RespondChoices(choices ...*discordgo.ApplicationCommandOptionChoice) error {
+	if len(choices) > 25 {
+		choices = choices[:25]
+	}
+
+	return c.Session().InteractionRespond(c.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+}
+
+// Defer acknowledges the interaction so the response can be sent later with
+// EditResponse. Handlers which run past the defer threshold are deferred by
+// the framework, and any response they send afterwards becomes an edit.
+func (c *Context) Defer(ephemeral bool) error {
+	responseType := discordgo.InteractionResponseDeferredChannelMessageWithSource
+	if c.Interaction().Type == discordgo.InteractionMessageComponent {
+		responseType = discordgo.InteractionResponseDeferredMessageUpdate
+	}
+
+	data := &discordgo.InteractionResponseData{}
+	if ephemeral {
+		data.Flags = discordgo.MessageFlagsEphemeral
+	}
+
+	return c.Session().InteractionRespond(c.Interaction(), &discordgo.InteractionResponse{
+		Type: responseType,
+		Data: data,
+	})
+}
+
+// EditResponse edits the original response to the interaction, which is how
+// a deferred interaction is finished.
+func (c *Context) EditResponse(edit *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	return c.Session().InteractionResponseEdit(c.Interaction(), edit)
+}
+
+// Followup sends another message in response to the interaction. It can be
+// used after the interaction has been responded to or deferred.
+func (c *Context) Followup(params *discordgo.WebhookParams) (*discordgo.Message, error) {
+	return c.Session().FollowupMessageCreate(c.Interaction(), true, params)
+}
+
 func (c *Context) Database() *gorm.DB {
 	return c.ctx.Value(ctxDatabase).(*gorm.DB)
 }
@@ -137,11 +361,118 @@ func (c *Context) EventValue() string {
 	return c.ctx.Value(ctxEventValue).(string)
 }
 
+// RouteKey returns the key of the route being executed, e.g. "remind.add".
+// It is empty for handlers not triggered by an interaction.
+func (c *Context) RouteKey() string {
+	key, _ := c.ctx.Value(ctxRouteKey).(string)
+	return key
+}
+
+// GuildID returns the ID of the server the handler was triggered in. It is
+// empty in direct messages.
+func (c *Context) GuildID() string {
+	guildId, _ := c.ctx.Value(ctxGuildId).(string)
+	return guildId
+}
+
+// NewCustomID stores the payload for the ttl and returns the custom ID for a
+// component or modal with a signed token for the payload appended. The
+// payload is handed back to the event handler by State.
+func (c *Context) NewCustomID(customId string, payload any, ttl time.Duration) (string, error) {
+	states, ok := c.ctx.Value(ctxStates).(*ComponentStates)
+	if !ok {
+		return "", ErrNoComponentState
+	}
+	return states.CustomID(customId, payload, ttl)
+}
+
+// State decodes the payload stored with NewCustomID for the component or
+// modal into dst. It returns ErrNoComponentState if there is none.
+func (c *Context) State(dst any) error {
+	payload, ok := c.ctx.Value(ctxState).([]byte)
+	if !ok {
+		return ErrNoComponentState
+	}
+	return json.Unmarshal(payload, dst)
+}
+
+// Publish hands the event to the apps subscribed to it on the bot's event
+// bus, see Bot.Publish.
+func (c *Context) Publish(event any) {
+	if publish, ok := c.ctx.Value(ctxPublisher).(func(any)); ok {
+		publish(event)
+	}
+}
+
+// Scheduler returns the bot's job scheduler, or nil outside of the bot.
+func (c *Context) Scheduler() *Scheduler {
+	scheduler, _ := c.ctx.Value(ctxScheduler).(*Scheduler)
+	return scheduler
+}
+
+// Payload decodes the payload of the job being run into dst.
+func (c *Context) Payload(dst any) error {
+	payload, ok := c.ctx.Value(ctxJobPayload).([]byte)
+	if !ok {
+		return ErrInvalidJob
+	}
+	return json.Unmarshal(payload, dst)
+}
+
+// Config copies the configuration being loaded into dst, which must be a
+// pointer to the configuration's type.
+func (c *Context) Config(dst any) error {
+	config := c.ctx.Value(ctxConfig)
+	if config == nil {
+		return ErrNoConfig
+	}
+
+	value := reflect.ValueOf(dst)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Type() != reflect.TypeOf(config) {
+		return fmt.Errorf("%w: expected *%T, got %T", ErrConfigType, config, dst)
+	}
+
+	value.Elem().Set(reflect.ValueOf(config))
+	return nil
+}
+
 func (c *Context) Reaction() (*discordgo.MessageReaction, bool) {
 	val, add := c.ctx.Value(ctxReactionValue), c.ctx.Value(ctxReactionAdd)
 	return val.(*discordgo.MessageReaction), add.(bool)
 }
 
+// BeforeUpdate returns the message as it was before it was edited, or nil if
+// it was not in the bot's message cache.
+func (c *Context) BeforeUpdate() *discordgo.Message {
+	before, _ := c.ctx.Value(ctxMessageBefore).(*discordgo.Message)
+	return before
+}
+
+// Member returns the member who joined or left the server, and whether they
+// joined.
+func (c *Context) Member() (*discordgo.Member, bool) {
+	val, join := c.ctx.Value(ctxMemberValue), c.ctx.Value(ctxMemberJoin)
+	return val.(*discordgo.Member), join.(bool)
+}
+
+// Thread returns the thread which was created.
+func (c *Context) Thread() *discordgo.Channel {
+	return c.ctx.Value(ctxThread).(*discordgo.Channel)
+}
+
+// Fail records that the handler has failed. When the ErrorReply middleware is
+// in use the error message is sent back to the user as an ephemeral reply
+// once the handler returns.
+func (c *Context) Fail(err error) {
+	c.ctx = context.WithValue(c.ctx, ctxError, err)
+}
+
+// Err returns the error recorded by Fail, or nil if the handler has not failed.
+func (c *Context) Err() error {
+	err, _ := c.ctx.Value(ctxError).(error)
+	return err
+}
+
 func withDatabase(db *gorm.DB) ContextOpt {
 	return func(c *Context) {
 		c.ctx = context.WithValue(c.ctx, ctxDatabase, db)
@@ -154,7 +485,7 @@ func withInteraction(i *discordgo.Interaction) ContextOpt {
 	}
 }
 
-func withSession(s *discordgo.Session) ContextOpt {
+func withSession(s Session) ContextOpt {
 	return func(c *Context) {
 		c.ctx = context.WithValue(c.ctx, ctxSession, s)
 	}
@@ -178,6 +509,73 @@ func withEventValue(v string) ContextOpt {
 	}
 }
 
+func withRouteKey(key string) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxRouteKey, key)
+	}
+}
+
+func withGuildId(guildId string) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxGuildId, guildId)
+	}
+}
+
+func withComponentStates(states *ComponentStates) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxStates, states)
+	}
+}
+
+func withComponentState(payload []byte) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxState, payload)
+	}
+}
+
+func withMessageBefore(m *discordgo.Message) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxMessageBefore, m)
+	}
+}
+
+func withMember(m *discordgo.Member, join bool) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxMemberValue, m)
+		c.ctx = context.WithValue(c.ctx, ctxMemberJoin, join)
+	}
+}
+
+func withThread(t *discordgo.Channel) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxThread, t)
+	}
+}
+
+func withPublisher(publish func(event any)) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxPublisher, publish)
+	}
+}
+
+func withScheduler(scheduler *Scheduler) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxScheduler, scheduler)
+	}
+}
+
+func withConfig(config any) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxConfig, config)
+	}
+}
+
+func withJobPayload(payload []byte) ContextOpt {
+	return func(c *Context) {
+		c.ctx = context.WithValue(c.ctx, ctxJobPayload, payload)
+	}
+}
+
 func withReaction(r *discordgo.MessageReaction, add bool) ContextOpt {
 	return func(c *Context) {
 		c.ctx = context.WithValue(c.ctx, ctxReactionValue, r)
@@ -0,0 +1,212 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultTimeout is how long a handler's context lives when the route
+	// does not set its own timeout with WithTimeout.
+	DefaultTimeout = 30 * time.Second
+
+	// DefaultDeferAfter is how long an interaction handler can run before
+	// the framework sends a deferred response for it. Discord drops
+	// interactions which are not responded to within 3 seconds.
+	DefaultDeferAfter = 2 * time.Second
+)
+
+var ErrAlreadyDeferred = errors.New("the interaction has already been deferred")
+
+type timeoutOption time.Duration
+
+func (o timeoutOption) applyRoute(r *Route) {
+	r.Timeout = time.Duration(o)
+}
+
+// WithTimeout sets the deadline of the handler's context for the route and
+// all of its subroutes which do not set their own.
+func WithTimeout(d time.Duration) RouteOption {
+	return timeoutOption(d)
+}
+
+// routeTimeout returns the timeout for the route key in the route
+func (b *Bot) routeTimeout(route Route, routeKey string) time.Duration {
+	if timeout, ok := route.appTimeouts[routeKey]; ok {
+		return timeout
+	}
+	return DefaultTimeout
+}
+
+// deferThreshold returns how long an interaction handler can run before it
+// is deferred
+func (b *Bot) deferThreshold() time.Duration {
+	if b.deferAfter > 0 {
+		return b.deferAfter
+	}
+	return DefaultDeferAfter
+}
+
+// SetDeferAfter sets how long interaction handlers can run before the
+// framework sends a deferred response for them.
+func (b *Bot) SetDeferAfter(d time.Duration) {
+	b.deferAfter = d
+}
+
+// withTimeout returns a copy of the context which is cancelled after the
+// timeout or when cancel is called, whichever happens first.
+func (c *Context) withTimeout(timeout time.Duration) (*Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(c.ctx, timeout)
+	return &Context{ctx: ctx, cancelFunc: cancel}, cancel
+}
+
+// deferringSession tracks the response to an interaction so the framework
+// can defer it when the handler is slow. Once deferred, the handler's own
+// response is sent as an edit of the deferred response instead, or as a
+// followup when an edit can't show it.
+type deferringSession struct {
+	Session
+	interaction *discordgo.Interaction
+
+	mu        sync.Mutex
+	responded bool
+	deferred  bool
+
+	// deferredType and ephemeral are the type of the deferred response and
+	// whether it is only shown to the user
+	deferredType discordgo.InteractionResponseType
+	ephemeral    bool
+}
+
+func newDeferringSession(s Session, i *discordgo.Interaction) *deferringSession {
+	return &deferringSession{Session: s, interaction: i}
+}
+
+func (s *deferringSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	if interaction.ID != s.interaction.ID {
+		return s.Session.InteractionRespond(interaction, resp, options...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.deferred {
+		s.responded = true
+		s.deferred = isDeferredResponse(resp.Type)
+		s.deferredType, s.ephemeral = resp.Type, isEphemeral(resp.Data)
+		return s.Session.InteractionRespond(interaction, resp, options...)
+	}
+
+	switch resp.Type {
+	case discordgo.InteractionResponseChannelMessageWithSource:
+		// A reply to a component is a new message rather than an edit of
+		// the message it is on
+		if s.deferredType == discordgo.InteractionResponseDeferredMessageUpdate {
+			_, err := s.Session.FollowupMessageCreate(interaction, true, webhookParams(resp.Data), options...)
+			return err
+		}
+
+		// The "thinking..." message can't be made ephemeral by editing it,
+		// so it is replaced by an ephemeral followup
+		if isEphemeral(resp.Data) && !s.ephemeral {
+			if err := s.Session.InteractionResponseDelete(interaction, options...); err != nil {
+				return err
+			}
+			_, err := s.Session.FollowupMessageCreate(interaction, true, webhookParams(resp.Data), options...)
+			return err
+		}
+
+		_, err := s.Session.InteractionResponseEdit(interaction, webhookEdit(resp.Data), options...)
+		return err
+
+	case discordgo.InteractionResponseUpdateMessage:
+		_, err := s.Session.InteractionResponseEdit(interaction, webhookEdit(resp.Data), options...)
+		return err
+
+	case discordgo.InteractionResponseDeferredChannelMessageWithSource, discordgo.InteractionResponseDeferredMessageUpdate:
+		return nil
+	}
+	return ErrAlreadyDeferred
+}
+
+// deferAfter sends a deferred response if the interaction has not been
+// responded to within d. The returned function stops the timer.
+func (s *deferringSession) deferAfter(d time.Duration, lg *log.Entry) (stop func()) {
+	timer := time.AfterFunc(d, func() {
+		if err := s.deferResponse(); err != nil {
+			lg.WithError(err).Error("Failed to defer interaction")
+		}
+	})
+	return func() { timer.Stop() }
+}
+
+func (s *deferringSession) deferResponse() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.responded {
+		return nil
+	}
+
+	// Components update the message they are on, anything else gets a
+	// "thinking..." message which is edited with the response
+	responseType := discordgo.InteractionResponseDeferredChannelMessageWithSource
+	if s.interaction.Type == discordgo.InteractionMessageComponent {
+		responseType = discordgo.InteractionResponseDeferredMessageUpdate
+	}
+
+	s.responded, s.deferred = true, true
+	s.deferredType, s.ephemeral = responseType, false
+	return s.Session.InteractionRespond(s.interaction, &discordgo.InteractionResponse{Type: responseType})
+}
+
+func isDeferredResponse(t discordgo.InteractionResponseType) bool {
+	return t == discordgo.InteractionResponseDeferredChannelMessageWithSource ||
+		t == discordgo.InteractionResponseDeferredMessageUpdate
+}
+
+func isEphemeral(data *discordgo.InteractionResponseData) bool {
+	return data != nil && data.Flags&discordgo.MessageFlagsEphemeral != 0
+}
+
+// webhookParams converts response data into a followup message
+func webhookParams(data *discordgo.InteractionResponseData) *discordgo.WebhookParams {
+	if data == nil {
+		return &discordgo.WebhookParams{}
+	}
+
+	return &discordgo.WebhookParams{
+		Content:         data.Content,
+		TTS:             data.TTS,
+		Files:           data.Files,
+		Components:      data.Components,
+		Embeds:          data.Embeds,
+		AllowedMentions: data.AllowedMentions,
+		Flags:           data.Flags,
+	}
+}
+
+// webhookEdit converts response data into an edit of the original response
+func webhookEdit(data *discordgo.InteractionResponseData) *discordgo.WebhookEdit {
+	edit := &discordgo.WebhookEdit{}
+	if data == nil {
+		return edit
+	}
+
+	edit.Content = &data.Content
+	edit.Files = data.Files
+	edit.AllowedMentions = data.AllowedMentions
+	if data.Embeds != nil {
+		edit.Embeds = &data.Embeds
+	}
+	if data.Components != nil {
+		edit.Components = &data.Components
+	}
+	return edit
+}
@@ -0,0 +1,144 @@
+package framework
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// slowApp waits before replying, or until its context is done
+type slowApp struct {
+	delay time.Duration
+	err   chan error
+	flags discordgo.MessageFlags
+}
+
+func (a slowApp) GetType() AppType {
+	return AppTypeCommand
+}
+
+func (a slowApp) GetDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{Name: "slow"}
+}
+
+func (a slowApp) OnCommand(ctx CommandContext) {
+	select {
+	case <-time.After(a.delay):
+		a.err <- nil
+	case <-ctx.Context().Done():
+		a.err <- ctx.Context().Err()
+	}
+
+	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: "done", Flags: a.flags},
+	})
+}
+
+func slowInteraction() *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:     "interaction",
+		Type:   discordgo.InteractionApplicationCommand,
+		Member: &discordgo.Member{User: &discordgo.User{ID: "user", Username: "user"}},
+		Data:   discordgo.ApplicationCommandInteractionData{Name: "slow"},
+	}}
+}
+
+func TestDeferSlowHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		delay     time.Duration
+		responses []discordgo.InteractionResponseType
+	}{
+		{"fast", 0, []discordgo.InteractionResponseType{discordgo.InteractionResponseChannelMessageWithSource}},
+		{"slow", 50 * time.Millisecond, []discordgo.InteractionResponseType{
+			discordgo.InteractionResponseDeferredChannelMessageWithSource,
+			discordgo.InteractionResponseUpdateMessage, // The reply is sent as an edit
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bot := &Bot{lg: log.WithField("src", "test"), deferAfter: 10 * time.Millisecond}
+			app := slowApp{delay: test.delay, err: make(chan error, 1)}
+			bot.Register(NewRoute(bot, "slow", app))
+
+			session := NewRecordingSession()
+			bot.HandleInteraction(session, slowInteraction())
+
+			if len(session.Responses) != len(test.responses) {
+				t.Fatalf("Expected %d responses, got %d", len(test.responses), len(session.Responses))
+			}
+			for i, response := range session.Responses {
+				if response.Type != test.responses[i] {
+					t.Errorf("Response %d: expected type %d, got %d", i, test.responses[i], response.Type)
+				}
+			}
+			if content := session.LastResponse().Data.Content; content != "done" {
+				t.Errorf("Expected the reply to be \"done\", got %q", content)
+			}
+		})
+	}
+}
+
+func TestDeferEphemeralReply(t *testing.T) {
+	bot := &Bot{lg: log.WithField("src", "test"), deferAfter: 10 * time.Millisecond}
+	app := slowApp{delay: 50 * time.Millisecond, err: make(chan error, 1), flags: discordgo.MessageFlagsEphemeral}
+	bot.Register(NewRoute(bot, "slow", app))
+
+	session := NewRecordingSession()
+	bot.HandleInteraction(session, slowInteraction())
+
+	// The public "thinking..." message is replaced by an ephemeral followup
+	if len(session.Responses) != 1 || session.Responses[0].Type != discordgo.InteractionResponseDeferredChannelMessageWithSource {
+		t.Fatalf("Expected only the deferred response, got %+v", session.Responses)
+	}
+	if len(session.Deleted) != 1 || session.Deleted[0] != ":interaction" {
+		t.Errorf("Expected the deferred response to be deleted, got %v", session.Deleted)
+	}
+	if len(session.Followups) != 1 || session.Followups[0].Content != "done" || session.Followups[0].Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Errorf("Expected an ephemeral followup, got %+v", session.Followups)
+	}
+}
+
+func TestRouteTimeout(t *testing.T) {
+	bot := &Bot{lg: log.WithField("src", "test"), deferAfter: time.Second}
+	app := slowApp{delay: time.Second, err: make(chan error, 1)}
+	route := NewRoute(bot, "slow", app, WithTimeout(10*time.Millisecond))
+	bot.Register(route)
+
+	if timeout := bot.routeTimeout(route, "slow"); timeout != 10*time.Millisecond {
+		t.Errorf("Expected the route timeout to be 10ms, got %s", timeout)
+	}
+
+	bot.HandleInteraction(NewRecordingSession(), slowInteraction())
+
+	if err := <-app.err; err != context.DeadlineExceeded {
+		t.Errorf("Expected the context deadline to be exceeded, got %v", err)
+	}
+}
+
+func TestRouteTimeoutInherited(t *testing.T) {
+	bot := &Bot{lg: log.WithField("src", "test")}
+	route := NewRoute(bot, "parent", greetApp{},
+		NewRoute(bot, "inherit", greetApp{}),
+		NewRoute(bot, "own", greetApp{}, WithTimeout(time.Minute)),
+		WithTimeout(time.Second),
+	)
+
+	expected := map[string]time.Duration{
+		"parent":         time.Second,
+		"parent.inherit": time.Second,
+		"parent.own":     time.Minute,
+		"missing":        DefaultTimeout,
+	}
+	for key, timeout := range expected {
+		if got := bot.routeTimeout(route, key); got != timeout {
+			t.Errorf("%s: expected timeout %s, got %s", key, timeout, got)
+		}
+	}
+}
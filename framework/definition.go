@@ -0,0 +1,85 @@
+package framework
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// SubCommandGroup is the application of a route which groups subcommands,
+// e.g. "trade" in "/cards trade offer". It can not be run itself.
+type SubCommandGroup struct {
+	Description string
+}
+
+func (g SubCommandGroup) GetType() AppType {
+	return AppTypeNOP
+}
+
+func (g SubCommandGroup) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+		Description: g.Description,
+	}
+}
+
+// NewGroup constructs a subcommand group route. The options are the group's
+// subcommands and any requirements or timeout for them.
+func NewGroup(bot *Bot, name, description string, opts ...RouteOption) Route {
+	return NewRoute(bot, name, SubCommandGroup{Description: description}, opts...)
+}
+
+// commandDefinition returns the definition of a command route. When the route
+// has subcommands the options are generated from them, so the definition
+// always matches the registered routes.
+func (r Route) commandDefinition() *discordgo.ApplicationCommand {
+	definition := r.App.(ApplicationCommand).GetDefinition()
+	if hasSubCommands(r) {
+		definition.Options = subCommandOptions(r.Subroutes, definition.Options)
+	}
+	return definition
+}
+
+// hasSubCommands reports whether any of the subroutes can be run as a
+// subcommand
+func hasSubCommands(r Route) bool {
+	for _, sr := range r.Subroutes {
+		if sr.App.GetType()&AppTypeSubCommand != 0 || hasSubCommands(sr) {
+			return true
+		}
+	}
+	return false
+}
+
+// subCommandOptions generates the subcommand and subcommand group options for
+// the routes. Subcommands which do not implement
+// ApplicationSubCommandDefinition use the option of the same name from the
+// hand written definition instead.
+func subCommandOptions(routes []Route, written []*discordgo.ApplicationCommandOption) []*discordgo.ApplicationCommandOption {
+	options := make([]*discordgo.ApplicationCommandOption, 0)
+
+	for _, sr := range routes {
+		group := hasSubCommands(sr)
+
+		// Event only subroutes are not part of the definition
+		if !group && sr.App.GetType()&AppTypeSubCommand == 0 {
+			continue
+		}
+
+		option := &discordgo.ApplicationCommandOption{}
+		if app, ok := sr.App.(ApplicationSubCommandDefinition); ok {
+			*option = *app.GetDefinition()
+		} else if existing := findOption(written, sr.Name); existing != nil {
+			*option = *existing
+		}
+
+		option.Name = sr.Name
+		option.Type = discordgo.ApplicationCommandOptionSubCommand
+		if group {
+			option.Type = discordgo.ApplicationCommandOptionSubCommandGroup
+			option.Options = subCommandOptions(sr.Subroutes, option.Options)
+		}
+
+		options = append(options, option)
+	}
+
+	return options
+}
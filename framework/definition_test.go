@@ -0,0 +1,153 @@
+package framework
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// cardsApp is the "/cards" command, its subcommand options are generated
+// apart from "list" which comes from the written definition
+type cardsApp struct{}
+
+func (a cardsApp) GetType() AppType {
+	return AppTypeCommand
+}
+
+func (a cardsApp) GetDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "cards",
+		Description: "Trading cards",
+		Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "list", Description: "List your cards"},
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "removed", Description: "No longer routed"},
+		},
+	}
+}
+
+func (a cardsApp) OnCommand(ctx CommandContext) {}
+
+// cardsSubCommand replies with the route key and its card option
+type cardsSubCommand struct {
+	description string
+}
+
+type cardsArgs struct {
+	Card string `option:"card,required" description:"The card"`
+}
+
+func (a cardsSubCommand) GetType() AppType {
+	return AppTypeSubCommand
+}
+
+func (a cardsSubCommand) GetDefinition() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Description: a.description,
+		Options:     CommandOptions(cardsArgs{}),
+	}
+}
+
+func (a cardsSubCommand) OnCommand(ctx CommandContext) {
+	var args cardsArgs
+	if err := ctx.Bind(&args); err != nil {
+		ctx.Fail(err)
+		return
+	}
+
+	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: ctx.(*Context).RouteKey() + " " + args.Card},
+	})
+}
+
+// cardsListSubCommand has no definition of its own
+type cardsListSubCommand struct{}
+
+func (a cardsListSubCommand) GetType() AppType {
+	return AppTypeSubCommand
+}
+
+func (a cardsListSubCommand) OnCommand(ctx CommandContext) {}
+
+func cardsRoute(bot *Bot) Route {
+	return NewRoute(bot, "cards", cardsApp{},
+		NewRoute(bot, "list", cardsListSubCommand{}),
+		NewGroup(bot, "trade", "Trade cards with other users",
+			NewRoute(bot, "offer", cardsSubCommand{description: "Offer a card"}),
+			NewRoute(bot, "accept", cardsSubCommand{description: "Accept an offer"}),
+		),
+		NewRoute(bot, "button", greetApp{}), // Not a subcommand
+	)
+}
+
+func TestCommandDefinition(t *testing.T) {
+	bot := &Bot{lg: log.WithField("src", "test")}
+	definition := cardsRoute(bot).commandDefinition()
+
+	if len(definition.Options) != 2 {
+		t.Fatalf("Expected 2 options, got %d", len(definition.Options))
+	}
+
+	list := definition.Options[0]
+	if list.Name != "list" || list.Type != discordgo.ApplicationCommandOptionSubCommand || list.Description != "List your cards" {
+		t.Errorf("Expected the written list option, got %+v", list)
+	}
+
+	trade := definition.Options[1]
+	if trade.Name != "trade" || trade.Type != discordgo.ApplicationCommandOptionSubCommandGroup || trade.Description != "Trade cards with other users" {
+		t.Fatalf("Expected the trade group, got %+v", trade)
+	}
+	if len(trade.Options) != 2 {
+		t.Fatalf("Expected 2 subcommands in the group, got %d", len(trade.Options))
+	}
+
+	offer := trade.Options[0]
+	if offer.Name != "offer" || offer.Type != discordgo.ApplicationCommandOptionSubCommand || offer.Description != "Offer a card" {
+		t.Errorf("Expected the offer subcommand, got %+v", offer)
+	}
+	if len(offer.Options) != 1 || offer.Options[0].Name != "card" {
+		t.Errorf("Expected the offer subcommand to have a card option, got %+v", offer.Options)
+	}
+}
+
+func TestSubCommandGroupRouting(t *testing.T) {
+	bot := &Bot{lg: log.WithField("src", "test")}
+	bot.Use(ErrorReply())
+	bot.Register(cardsRoute(bot))
+
+	offer := func(opts ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+		return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			ID:     "interaction",
+			Type:   discordgo.InteractionApplicationCommand,
+			Member: &discordgo.Member{User: &discordgo.User{ID: "user", Username: "user"}},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name: "cards",
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+					Name: "trade",
+					Type: discordgo.ApplicationCommandOptionSubCommandGroup,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+						Name:    "offer",
+						Type:    discordgo.ApplicationCommandOptionSubCommand,
+						Options: opts,
+					}},
+				}},
+			},
+		}}
+	}
+
+	session := NewRecordingSession()
+	bot.HandleInteraction(session, offer(&discordgo.ApplicationCommandInteractionDataOption{
+		Name: "card", Type: discordgo.ApplicationCommandOptionString, Value: "snail",
+	}))
+	if content := session.LastResponse().Data.Content; content != "cards.trade.offer snail" {
+		t.Errorf("Expected the offer subcommand to run, got %q", content)
+	}
+
+	session = NewRecordingSession()
+	bot.HandleInteraction(session, offer())
+	if content := session.LastResponse().Data.Content; content != "**Error:** card is required" {
+		t.Errorf("Expected a missing option error, got %q", content)
+	}
+}
@@ -0,0 +1,178 @@
+package framework
+
+import (
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// messageCacheSize is how many messages are kept for each channel, so edited
+// and deleted messages can be handed to apps as they were before
+const messageCacheSize = 100
+
+func (b *Bot) messageUpdateHandler() func(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	return func(s *discordgo.Session, m *discordgo.MessageUpdate) {
+		// Embeds being added to a message are also updates, which have no author
+		if m.Author == nil || m.Author.ID == s.State.User.ID {
+			return
+		}
+
+		channel, err := s.State.Channel(m.ChannelID)
+		if err != nil {
+			return
+		}
+
+		b.handleMessageUpdate(s, channel, m)
+	}
+}
+
+// handleMessageUpdate runs the message update apps with the edited message
+func (b *Bot) handleMessageUpdate(s Session, channel *discordgo.Channel, m *discordgo.MessageUpdate) {
+	ctx := NewContext(
+		withSession(s),
+		withMessage(m.Message),
+		withMessageBefore(m.BeforeUpdate),
+		withDatabase(b.db),
+		withPublisher(b.Publish),
+		withGuildId(m.GuildID),
+	)
+
+	b.dispatchRoutes(ctx, AppTypeMessageUpdate, log.Fields{"type": "message_update", "user": m.Author.ID}, func(ctx *Context, app Application) {
+		app.(ApplicationMessageUpdate).OnMessageUpdate(ctx, channel)
+	})
+}
+
+func (b *Bot) messageDeleteHandler() func(s *discordgo.Session, m *discordgo.MessageDelete) {
+	return func(s *discordgo.Session, m *discordgo.MessageDelete) {
+		b.handleMessageDelete(s, m)
+	}
+}
+
+// handleMessageDelete runs the message delete apps. The message is the one
+// from the cache if it was there, otherwise it only has its IDs.
+func (b *Bot) handleMessageDelete(s Session, m *discordgo.MessageDelete) {
+	message := m.Message
+	if m.BeforeDelete != nil {
+		message = m.BeforeDelete
+	}
+
+	ctx := NewContext(
+		withSession(s),
+		withMessage(message),
+		withDatabase(b.db),
+		withPublisher(b.Publish),
+		withGuildId(m.GuildID),
+	)
+
+	b.dispatchRoutes(ctx, AppTypeMessageDelete, log.Fields{"type": "message_delete"}, func(ctx *Context, app Application) {
+		app.(ApplicationMessageDelete).OnMessageDelete(ctx)
+	})
+}
+
+func (b *Bot) reactionRemoveAllHandler() func(s *discordgo.Session, r *discordgo.MessageReactionRemoveAll) {
+	return func(s *discordgo.Session, r *discordgo.MessageReactionRemoveAll) {
+		b.handleReactionRemoveAll(s, r)
+	}
+}
+
+// handleReactionRemoveAll runs the reaction remove all apps, the reaction has
+// the message's IDs and no emoji
+func (b *Bot) handleReactionRemoveAll(s Session, r *discordgo.MessageReactionRemoveAll) {
+	ctx := NewContext(
+		withSession(s),
+		withDatabase(b.db),
+		withPublisher(b.Publish),
+		withReaction(r.MessageReaction, false),
+		withGuildId(r.GuildID),
+	)
+
+	b.dispatchRoutes(ctx, AppTypeReactionRemoveAll, log.Fields{"type": "reaction_remove_all"}, func(ctx *Context, app Application) {
+		app.(ApplicationReactionRemoveAll).OnReactionRemoveAll(ctx)
+	})
+}
+
+func (b *Bot) memberAddHandler() func(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+	return func(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+		b.handleMember(s, m.Member, true)
+	}
+}
+
+func (b *Bot) memberRemoveHandler() func(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+	return func(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+		b.handleMember(s, m.Member, false)
+	}
+}
+
+// handleMember runs the member apps when a member joins or leaves a server
+func (b *Bot) handleMember(s Session, m *discordgo.Member, join bool) {
+	ctx := NewContext(
+		withSession(s),
+		withDatabase(b.db),
+		withPublisher(b.Publish),
+		withMember(m, join),
+		withGuildId(m.GuildID),
+	)
+
+	eventType := "member_remove"
+	if join {
+		eventType = "member_add"
+	}
+
+	b.dispatchRoutes(ctx, AppTypeMember, log.Fields{"type": eventType, "user": m.User.ID}, func(ctx *Context, app Application) {
+		app.(ApplicationMember).OnMember(ctx)
+	})
+}
+
+func (b *Bot) threadCreateHandler() func(s *discordgo.Session, t *discordgo.ThreadCreate) {
+	return func(s *discordgo.Session, t *discordgo.ThreadCreate) {
+		// Threads are also sent when the bot is added to an existing thread
+		if !t.NewlyCreated {
+			return
+		}
+
+		b.handleThreadCreate(s, t.Channel)
+	}
+}
+
+// handleThreadCreate runs the thread apps when a thread is created
+func (b *Bot) handleThreadCreate(s Session, thread *discordgo.Channel) {
+	ctx := NewContext(
+		withSession(s),
+		withDatabase(b.db),
+		withPublisher(b.Publish),
+		withThread(thread),
+		withGuildId(thread.GuildID),
+	)
+
+	b.dispatchRoutes(ctx, AppTypeThread, log.Fields{"type": "thread_create", "user": thread.OwnerID}, func(ctx *Context, app Application) {
+		app.(ApplicationThread).OnThreadCreate(ctx)
+	})
+}
+
+// dispatchRoutes runs the handler for each route of the app type which is
+// enabled in the server of the context
+func (b *Bot) dispatchRoutes(ctx *Context, appType AppType, fields log.Fields, handler func(ctx *Context, app Application)) {
+	disabled := b.disabledApps(ctx.GuildID())
+
+	for _, route := range b.Routes {
+		if route.App.GetType()&appType == 0 || disabled[route.Name] {
+			continue
+		}
+
+		withLogger(b.lg.WithField("route", route.Name).WithFields(fields))(ctx)
+		withRouteKey(route.Name)(ctx)
+
+		app := route.App
+		b.dispatch(ctx, b.routeTimeout(route, route.Name), func(ctx *Context) { handler(ctx, app) })
+	}
+}
+
+// handles reports whether any route is an app of the type
+func (b *Bot) handles(appType AppType) bool {
+	for _, route := range b.Routes {
+		if route.App.GetType()&appType != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package framework
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// watchApp records the events it is handed
+type watchApp struct {
+	events *[]string
+}
+
+func (a watchApp) GetType() AppType {
+	return AppTypeMessageUpdate | AppTypeMessageDelete | AppTypeReactionRemoveAll | AppTypeMember | AppTypeThread
+}
+
+func (a watchApp) OnMessageUpdate(ctx MessageUpdateContext, channel *discordgo.Channel) {
+	before := "uncached"
+	if ctx.BeforeUpdate() != nil {
+		before = ctx.BeforeUpdate().Content
+	}
+	*a.events = append(*a.events, "update "+channel.Name+" "+before+" -> "+ctx.Message().Content)
+}
+
+func (a watchApp) OnMessageDelete(ctx MessageContext) {
+	*a.events = append(*a.events, "delete "+ctx.Message().ID+" "+ctx.Message().Content)
+}
+
+func (a watchApp) OnReactionRemoveAll(ctx ReactionContext) {
+	reaction, add := ctx.Reaction()
+	if !add {
+		*a.events = append(*a.events, "remove all "+reaction.MessageID)
+	}
+}
+
+func (a watchApp) OnMember(ctx MemberContext) {
+	member, join := ctx.Member()
+	if join {
+		*a.events = append(*a.events, "join "+member.User.ID+" "+ctx.GuildID())
+	} else {
+		*a.events = append(*a.events, "leave "+member.User.ID+" "+ctx.GuildID())
+	}
+}
+
+func (a watchApp) OnThreadCreate(ctx ThreadContext) {
+	*a.events = append(*a.events, "thread "+ctx.Thread().Name)
+}
+
+func TestEventHandlers(t *testing.T) {
+	var events []string
+	bot := &Bot{lg: log.WithField("src", "test")}
+	bot.Register(
+		NewRoute(bot, "watch", watchApp{events: &events}),
+		NewRoute(bot, "greet", greetApp{}), // Not handling these events
+	)
+
+	s := NewRecordingSession()
+	channel := &discordgo.Channel{ID: "channel", Name: "tech-news"}
+	author := &discordgo.User{ID: "author"}
+	member := &discordgo.Member{GuildID: "guild", User: &discordgo.User{ID: "member"}}
+
+	bot.handleMessageUpdate(s, channel, &discordgo.MessageUpdate{
+		Message:      &discordgo.Message{ID: "message", ChannelID: "channel", Author: author, Content: "after"},
+		BeforeUpdate: &discordgo.Message{ID: "message", ChannelID: "channel", Author: author, Content: "before"},
+	})
+	bot.handleMessageUpdate(s, channel, &discordgo.MessageUpdate{
+		Message: &discordgo.Message{ID: "message", ChannelID: "channel", Author: author, Content: "again"},
+	})
+	bot.handleMessageDelete(s, &discordgo.MessageDelete{
+		Message:      &discordgo.Message{ID: "message", ChannelID: "channel"},
+		BeforeDelete: &discordgo.Message{ID: "message", ChannelID: "channel", Content: "again"},
+	})
+	bot.handleMessageDelete(s, &discordgo.MessageDelete{
+		Message: &discordgo.Message{ID: "uncached", ChannelID: "channel"},
+	})
+	bot.handleReactionRemoveAll(s, &discordgo.MessageReactionRemoveAll{
+		MessageReaction: &discordgo.MessageReaction{MessageID: "message", ChannelID: "channel"},
+	})
+	bot.handleMember(s, member, true)
+	bot.handleMember(s, member, false)
+	bot.handleThreadCreate(s, &discordgo.Channel{ID: "thread", Name: "snails", GuildID: "guild"})
+
+	expected := []string{
+		"update tech-news before -> after",
+		"update tech-news uncached -> again",
+		"delete message again",
+		"delete uncached ",
+		"remove all message",
+		"join member guild",
+		"leave member guild",
+		"thread snails",
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %v", len(expected), events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Expected event %q, got %q", expected[i], events[i])
+		}
+	}
+
+	if !bot.handles(AppTypeMember) || bot.handles(AppTypeMessage) {
+		t.Errorf("Expected the bot to only handle the registered app types")
+	}
+}
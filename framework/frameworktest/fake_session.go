@@ -0,0 +1,21 @@
+// Package frameworktest has helpers for testing applications without a live
+// Discord connection.
+package frameworktest
+
+import (
+	"github.com/aussiebroadwan/tony/framework"
+)
+
+// FakeSession is an in-memory framework.Session which records everything sent
+// to it instead of calling Discord, for testing application handlers. The
+// recorded responses, messages and actions are its fields. Lock it to use the
+// fields while handlers may be running.
+type FakeSession struct {
+	*framework.RecordingSession
+}
+
+var _ framework.Session = (*FakeSession)(nil)
+
+func NewFakeSession() *FakeSession {
+	return &FakeSession{RecordingSession: framework.NewRecordingSession()}
+}
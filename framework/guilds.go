@@ -0,0 +1,88 @@
+package framework
+
+import (
+	"errors"
+
+	"gorm.io/gorm/clause"
+)
+
+var ErrUnknownRoute = errors.New("unknown app")
+
+// GuildApp records whether an app is enabled in a server. Apps are enabled in
+// every server unless they have a record disabling them.
+type GuildApp struct {
+	GuildID string `gorm:"primarykey"`
+	Route   string `gorm:"primarykey"`
+	Enabled bool
+}
+
+// Guilds returns the IDs of the servers the bot serves
+func (b *Bot) Guilds() []string {
+	return b.serverIds
+}
+
+// AppEnabled reports whether the route is enabled in the server. Apps are
+// always enabled in direct messages.
+func (b *Bot) AppEnabled(guildId, route string) bool {
+	return !b.disabledApps(guildId)[route]
+}
+
+// SetAppEnabled enables or disables a top level route in a server. The
+// server's commands need to be synced for the change to show in Discord.
+func (b *Bot) SetAppEnabled(guildId, route string, enabled bool) error {
+	found := false
+	for _, r := range b.Routes {
+		found = found || r.Name == route
+	}
+	if !found {
+		return ErrUnknownRoute
+	}
+
+	err := b.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&GuildApp{
+		GuildID: guildId,
+		Route:   route,
+		Enabled: enabled,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	// Load the server's apps again on the next event
+	b.disabledMu.Lock()
+	delete(b.disabled, guildId)
+	b.disabledMu.Unlock()
+	return nil
+}
+
+// disabledApps returns the routes which have been disabled in the server. They
+// are loaded once per server and kept until SetAppEnabled changes them, the
+// map returned must not be modified.
+func (b *Bot) disabledApps(guildId string) map[string]bool {
+	if b.db == nil || guildId == "" {
+		return map[string]bool{}
+	}
+
+	b.disabledMu.Lock()
+	defer b.disabledMu.Unlock()
+
+	if disabled, ok := b.disabled[guildId]; ok {
+		return disabled
+	}
+
+	var apps []GuildApp
+	if err := b.db.Where("guild_id = ? AND enabled = ?", guildId, false).Find(&apps).Error; err != nil {
+		b.lg.WithError(err).WithField("guild", guildId).Error("Failed to load disabled apps")
+		return map[string]bool{}
+	}
+
+	disabled := make(map[string]bool)
+	for _, app := range apps {
+		disabled[app.Route] = true
+	}
+
+	if b.disabled == nil {
+		b.disabled = make(map[string]map[string]bool)
+	}
+	b.disabled[guildId] = disabled
+	return disabled
+}
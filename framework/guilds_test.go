@@ -0,0 +1,73 @@
+package framework
+
+import (
+	"testing"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestGuildApps(t *testing.T) {
+	db := dbtest.Open(t, Migrations)
+
+	bot := &Bot{serverIds: []string{"guild1", "guild2"}, db: db, lg: log.WithField("src", "test")}
+	bot.Register(NewRoute(bot, "greet", greetApp{}))
+
+	if !bot.AppEnabled("guild1", "greet") {
+		t.Errorf("Expected apps to be enabled by default")
+	}
+
+	if err := bot.SetAppEnabled("guild1", "missing", false); err != ErrUnknownRoute {
+		t.Errorf("Expected ErrUnknownRoute, got %v", err)
+	}
+
+	if err := bot.SetAppEnabled("guild1", "greet", false); err != nil {
+		t.Fatalf("Failed to disable app: %v", err)
+	}
+
+	if bot.AppEnabled("guild1", "greet") {
+		t.Errorf("Expected app to be disabled in guild1")
+	}
+	if !bot.AppEnabled("guild2", "greet") {
+		t.Errorf("Expected app to still be enabled in guild2")
+	}
+	if len(bot.commandDefinitions("guild1")) != 0 || len(bot.commandDefinitions("guild2")) != 1 {
+		t.Errorf("Expected the disabled command to only be left out of guild1")
+	}
+
+	// Interactions for the disabled app are rejected
+	for _, guildId := range []string{"guild1", "guild2"} {
+		session := NewRecordingSession()
+		bot.HandleInteraction(session, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			ID:      "interaction",
+			Type:    discordgo.InteractionMessageComponent,
+			GuildID: guildId,
+			Member:  &discordgo.Member{User: &discordgo.User{ID: "user", Username: "user"}},
+			Data:    discordgo.MessageComponentInteractionData{CustomID: "greet:alice"},
+		}})
+
+		response := session.LastResponse()
+		disabled := response != nil && response.Data.Content == "**Error:** This app is not enabled in this server"
+		if disabled != (guildId == "guild1") {
+			t.Errorf("%s: unexpected response %+v", guildId, response.Data)
+		}
+	}
+
+	// The disabled apps are cached until they are changed with SetAppEnabled,
+	// so disabling it behind the bot's back isn't seen
+	db.Create(&GuildApp{GuildID: "guild2", Route: "greet", Enabled: false})
+	if !bot.AppEnabled("guild2", "greet") {
+		t.Errorf("Expected guild2's apps to be cached")
+	}
+	db.Where("guild_id = ?", "guild2").Delete(&GuildApp{})
+
+	// Turning it back on updates the existing record
+	if err := bot.SetAppEnabled("guild1", "greet", true); err != nil {
+		t.Fatalf("Failed to enable app: %v", err)
+	}
+	if !bot.AppEnabled("guild1", "greet") {
+		t.Errorf("Expected app to be enabled again in guild1")
+	}
+}
@@ -0,0 +1,219 @@
+package framework
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// Discord fails the interaction if it is not responded to within 3 seconds
+	httpResponseTimeout = 3 * time.Second
+
+	// maxInteractionBody is the largest request read, Discord's interactions
+	// are far smaller
+	maxInteractionBody = 1 << 20
+
+	// maxTimestampSkew is how far a request's signature timestamp can be from
+	// now before it is rejected, so old requests can't be replayed
+	maxTimestampSkew = 5 * time.Minute
+)
+
+// ParsePublicKey decodes the hex encoded public key shown on the Discord
+// developer portal for the application.
+func ParsePublicKey(key string) (ed25519.PublicKey, error) {
+	decoded, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(decoded) != ed25519.PublicKeySize {
+		return nil, errors.New("public key must be 32 bytes")
+	}
+	return ed25519.PublicKey(decoded), nil
+}
+
+// InteractionsHandler serves Discord's outgoing webhook interactions over
+// HTTP. Requests are verified with the application's public key and then
+// routed the same way as interactions from the gateway. The initial response
+// is written back as the HTTP response, every other call goes to the session.
+type InteractionsHandler struct {
+	bot       *Bot
+	session   Session
+	publicKey ed25519.PublicKey
+	timeout   time.Duration
+}
+
+func NewInteractionsHandler(bot *Bot, s Session, publicKey ed25519.PublicKey) *InteractionsHandler {
+	return &InteractionsHandler{
+		bot:       bot,
+		session:   s,
+		publicKey: publicKey,
+		timeout:   httpResponseTimeout,
+	}
+}
+
+func (h *InteractionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInteractionBody))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Discord requires invalid signatures to be rejected with a 401
+	if !discordgo.VerifyInteraction(r, h.publicKey) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+	if !freshTimestamp(r.Header.Get("X-Signature-Timestamp"), time.Now()) {
+		http.Error(w, "stale request timestamp", http.StatusUnauthorized)
+		return
+	}
+
+	var i discordgo.InteractionCreate
+	if err := json.Unmarshal(body, &i); err != nil {
+		http.Error(w, "invalid interaction", http.StatusBadRequest)
+		return
+	}
+
+	// Discord pings the endpoint when it is configured
+	if i.Type == discordgo.InteractionPing {
+		writeInteractionResponse(w, &discordgo.InteractionResponse{Type: discordgo.InteractionResponsePong})
+		return
+	}
+
+	session := newHTTPSession(h.session, i.ID)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.bot.HandleInteraction(session, &i)
+	}()
+
+	var response *discordgo.InteractionResponse
+	select {
+	case response = <-session.response:
+	case <-done:
+		// The handler may have responded just before returning
+		select {
+		case response = <-session.response:
+		default:
+		}
+	case <-time.After(h.timeout):
+	}
+	session.close()
+
+	if response == nil {
+		h.bot.lg.WithField("interaction", i.ID).Error("Interaction was not responded to")
+		http.Error(w, "interaction was not responded to", http.StatusInternalServerError)
+		return
+	}
+
+	writeInteractionResponse(w, response)
+}
+
+// freshTimestamp returns whether the signature timestamp, in seconds since
+// the epoch, is within maxTimestampSkew of now
+func freshTimestamp(timestamp string, now time.Time) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	skew := now.Sub(time.Unix(seconds, 0))
+	return skew <= maxTimestampSkew && skew >= -maxTimestampSkew
+}
+
+func writeInteractionResponse(w http.ResponseWriter, response *discordgo.InteractionResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// httpSession captures the first response to the interaction being served so
+// it can be written back as the HTTP response. Everything else, including
+// responses after the request has finished, is passed on to the session.
+type httpSession struct {
+	Session
+
+	interactionId string
+	response      chan *discordgo.InteractionResponse
+
+	closed bool
+	mu     sync.Mutex
+}
+
+func newHTTPSession(s Session, interactionId string) *httpSession {
+	return &httpSession{
+		Session:       s,
+		interactionId: interactionId,
+		response:      make(chan *discordgo.InteractionResponse, 1),
+	}
+}
+
+func (s *httpSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	if interaction.ID == s.interactionId && !s.closed {
+		s.closed = true
+		s.mu.Unlock()
+
+		s.response <- resp
+		return nil
+	}
+	s.mu.Unlock()
+
+	return s.Session.InteractionRespond(interaction, resp, options...)
+}
+
+func (s *httpSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+}
+
+// RunHTTP serves interactions over HTTP on the address instead of the gateway.
+// Only interactions are received in this mode, message and reaction apps are
+// not run. Commands are still registered with Discord using the bot token.
+func (b *Bot) RunHTTP(addr string, publicKey ed25519.PublicKey) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	b.syncDiscordApplicationCommands()
+	b.mountRoutes(b.Discord)
+
+	mux := http.NewServeMux()
+	mux.Handle("/interactions", NewInteractionsHandler(b, b.Discord, publicKey))
+	b.httpServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+
+	go func() {
+		if err := b.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			b.lg.WithError(err).Error("Interactions endpoint stopped")
+		}
+	}()
+
+	b.lg.Infof("Serving interactions on %s/interactions", listener.Addr())
+	return nil
+}
@@ -0,0 +1,116 @@
+package framework
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+func signedRequest(t *testing.T, key ed25519.PrivateKey, body string) *http.Request {
+	return signedRequestAt(t, key, body, time.Now())
+}
+
+func signedRequestAt(t *testing.T, key ed25519.PrivateKey, body string, at time.Time) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	signature := ed25519.Sign(key, []byte(timestamp+body))
+
+	r := httptest.NewRequest(http.MethodPost, "/interactions", strings.NewReader(body))
+	r.Header.Set("X-Signature-Ed25519", hex.EncodeToString(signature))
+	r.Header.Set("X-Signature-Timestamp", timestamp)
+	return r
+}
+
+func TestInteractionsHandler(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+
+	bot := &Bot{lg: log.WithField("src", "test")}
+	bot.Register(NewRoute(bot, "greet", greetApp{}))
+
+	session := NewRecordingSession()
+	handler := NewInteractionsHandler(bot, session, publicKey)
+
+	ping := `{"id":"1","type":1}`
+	command := `{"id":"2","type":2,"channel_id":"channel","guild_id":"guild",` +
+		`"member":{"user":{"id":"bob","username":"bob"}},` +
+		`"data":{"id":"cmd","name":"greet","type":1,"options":[` +
+		`{"name":"user","type":6,"value":"alice"},{"name":"times","type":4,"value":1}]}}`
+
+	tests := []struct {
+		name     string
+		request  *http.Request
+		status   int
+		response discordgo.InteractionResponseType
+		content  string
+	}{
+		{"ping", signedRequest(t, privateKey, ping), http.StatusOK, discordgo.InteractionResponsePong, ""},
+		{"command", signedRequest(t, privateKey, command), http.StatusOK, discordgo.InteractionResponseChannelMessageWithSource, "Hello <@alice>!"},
+		{"wrong key", signedRequest(t, otherKey, ping), http.StatusUnauthorized, 0, ""},
+		{"unsigned", httptest.NewRequest(http.MethodPost, "/interactions", strings.NewReader(ping)), http.StatusUnauthorized, 0, ""},
+		{"stale", signedRequestAt(t, privateKey, ping, time.Now().Add(-10*time.Minute)), http.StatusUnauthorized, 0, ""},
+		{"future", signedRequestAt(t, privateKey, ping, time.Now().Add(10*time.Minute)), http.StatusUnauthorized, 0, ""},
+		{"too large", signedRequest(t, privateKey, ping+strings.Repeat(" ", maxInteractionBody)), http.StatusRequestEntityTooLarge, 0, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, test.request)
+
+			if w.Code != test.status {
+				t.Fatalf("Expected status %d, got %d: %s", test.status, w.Code, w.Body.String())
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+
+			// Components can not be unmarshalled, so only decode what is checked
+			var response struct {
+				Type discordgo.InteractionResponseType `json:"type"`
+				Data *struct {
+					Content string `json:"content"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if response.Type != test.response {
+				t.Errorf("Expected response type %d, got %d", test.response, response.Type)
+			}
+			if test.content != "" && (response.Data == nil || !strings.Contains(response.Data.Content, test.content)) {
+				t.Errorf("Expected content %q, got %+v", test.content, response.Data)
+			}
+		})
+	}
+
+	// The initial response is sent over HTTP, not through the session
+	if len(session.Responses) != 0 {
+		t.Errorf("Expected no responses through the session, got %d", len(session.Responses))
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(nil)
+
+	parsed, err := ParsePublicKey(hex.EncodeToString(publicKey))
+	if err != nil || !parsed.Equal(publicKey) {
+		t.Errorf("Expected key to round trip, got %v, %v", parsed, err)
+	}
+
+	if _, err := ParsePublicKey("abcd"); err == nil {
+		t.Errorf("Expected an error for a short key")
+	}
+}
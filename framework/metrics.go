@@ -0,0 +1,277 @@
+package framework
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds in seconds used for handler latency
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const (
+	counterMetric   = "counter"
+	gaugeMetric     = "gauge"
+	histogramMetric = "histogram"
+)
+
+// Metrics is a registry of counters, histograms and gauges which is served in
+// the Prometheus text format. Every metric has a fixed list of label names and
+// is recorded with a value for each of them.
+type Metrics struct {
+	families map[string]*metricFamily
+	mu       sync.Mutex
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{families: make(map[string]*metricFamily)}
+}
+
+// MetricSample is one value of a gauge, with its label values in the order the
+// gauge's labels were given
+type MetricSample struct {
+	Labels []string
+	Value  float64
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	// collect reads the values of a gauge when the metrics are written
+	collect func() []MetricSample
+
+	series map[string]*metricSeries
+	mu     sync.Mutex
+}
+
+type metricSeries struct {
+	labels []string
+	value  float64
+
+	// counts has the observations of a histogram in each bucket, the last
+	// is for the ones above every bucket
+	counts []uint64
+	count  uint64
+}
+
+// Counter is a value which only goes up, such as how many times a command ran
+type Counter struct {
+	family *metricFamily
+}
+
+// Add adds the value to the counter with the label values
+func (c Counter) Add(value float64, labels ...string) {
+	c.family.with(labels, func(s *metricSeries) {
+		s.value += value
+	})
+}
+
+// Inc adds one to the counter with the label values
+func (c Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Histogram counts values in buckets, such as how long a command took
+type Histogram struct {
+	family *metricFamily
+}
+
+// Observe records the value in the histogram with the label values
+func (h Histogram) Observe(value float64, labels ...string) {
+	buckets := h.family.buckets
+
+	h.family.with(labels, func(s *metricSeries) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(buckets)+1)
+		}
+
+		s.counts[sort.SearchFloat64s(buckets, value)]++
+		s.count++
+		s.value += value
+	})
+}
+
+// Counter returns the counter with the name, creating it if it does not exist
+func (m *Metrics) Counter(name, help string, labels ...string) Counter {
+	return Counter{m.family(name, help, counterMetric, labels, nil)}
+}
+
+// Histogram returns the histogram with the name, creating it with the buckets
+// if it does not exist. The buckets are upper bounds in increasing order.
+func (m *Metrics) Histogram(name, help string, buckets []float64, labels ...string) Histogram {
+	return Histogram{m.family(name, help, histogramMetric, labels, buckets)}
+}
+
+// GaugeFunc adds a gauge whose values are read with collect each time the
+// metrics are written, such as how many games are running. It replaces the
+// gauge with the name if there is one.
+func (m *Metrics) GaugeFunc(name, help string, collect func() []MetricSample, labels ...string) {
+	family := m.family(name, help, gaugeMetric, labels, nil)
+
+	family.mu.Lock()
+	defer family.mu.Unlock()
+	family.collect = collect
+}
+
+func (m *Metrics) family(name, help, kind string, labels []string, buckets []float64) *metricFamily {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if family, ok := m.families[name]; ok {
+		return family
+	}
+
+	family := &metricFamily{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*metricSeries),
+	}
+	m.families[name] = family
+	return family
+}
+
+// with runs update on the series with the label values, creating it if it
+// does not exist
+func (f *metricFamily) with(labels []string, update func(s *metricSeries)) {
+	if len(labels) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d", f.name, len(f.labels), len(labels)))
+	}
+
+	key := strings.Join(labels, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	series, ok := f.series[key]
+	if !ok {
+		series = &metricSeries{labels: append([]string(nil), labels...)}
+		f.series[key] = series
+	}
+	update(series)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.Write(w)
+}
+
+// Write writes the metrics in the Prometheus text format, sorted by name
+func (m *Metrics) Write(w io.Writer) error {
+	m.mu.Lock()
+	families := make([]*metricFamily, 0, len(m.families))
+	for _, family := range m.families {
+		families = append(families, family)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	var sb strings.Builder
+	for _, family := range families {
+		family.write(&sb)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (f *metricFamily) write(sb *strings.Builder) {
+	f.mu.Lock()
+	collect := f.collect
+	series := make([]metricSeries, 0, len(f.series))
+	for _, s := range f.series {
+		series = append(series, *s)
+		series[len(series)-1].counts = append([]uint64(nil), s.counts...)
+	}
+	f.mu.Unlock()
+
+	// Gauges are read outside the lock as they may be slow
+	if collect != nil {
+		for _, sample := range collect() {
+			if len(sample.Labels) == len(f.labels) {
+				series = append(series, metricSeries{labels: sample.Labels, value: sample.Value})
+			}
+		}
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		return strings.Join(series[i].labels, "\xff") < strings.Join(series[j].labels, "\xff")
+	})
+
+	fmt.Fprintf(sb, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(sb, "# TYPE %s %s\n", f.name, f.kind)
+
+	for _, s := range series {
+		if f.kind != histogramMetric {
+			fmt.Fprintf(sb, "%s%s %s\n", f.name, formatLabels(f.labels, s.labels, "", ""), formatFloat(s.value))
+			continue
+		}
+
+		// Buckets are cumulative, ending with every observation
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			if i < len(s.counts) {
+				cumulative += s.counts[i]
+			}
+			fmt.Fprintf(sb, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(sb, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(sb, "%s_sum%s %s\n", f.name, formatLabels(f.labels, s.labels, "", ""), formatFloat(s.value))
+		fmt.Fprintf(sb, "%s_count%s %d\n", f.name, formatLabels(f.labels, s.labels, "", ""), s.count)
+	}
+}
+
+// formatLabels formats the labels as {name="value",...}, with the extra label
+// at the end if it has a name
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, escapeLabel(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, escapeLabel(extraValue)))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,132 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	metrics := NewMetrics()
+
+	calls := metrics.Counter("calls_total", "Calls made.", "route")
+	calls.Inc("ping")
+	calls.Add(2, `say "hi"`)
+
+	latency := metrics.Histogram("latency_seconds", "How long calls took.", []float64{0.1, 1}, "route")
+	latency.Observe(0.05, "ping")
+	latency.Observe(0.5, "ping")
+	latency.Observe(5, "ping")
+
+	metrics.GaugeFunc("games", "Games running.", func() []MetricSample {
+		return []MetricSample{{Labels: []string{"snailrace"}, Value: 2}}
+	}, "game")
+
+	var sb strings.Builder
+	if err := metrics.Write(&sb); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+
+	expected := `# HELP calls_total Calls made.
+# TYPE calls_total counter
+calls_total{route="ping"} 1
+calls_total{route="say \"hi\""} 2
+# HELP games Games running.
+# TYPE games gauge
+games{game="snailrace"} 2
+# HELP latency_seconds How long calls took.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="ping",le="0.1"} 1
+latency_seconds_bucket{route="ping",le="1"} 2
+latency_seconds_bucket{route="ping",le="+Inf"} 3
+latency_seconds_sum{route="ping"} 5.55
+latency_seconds_count{route="ping"} 3
+`
+	if sb.String() != expected {
+		t.Errorf("Expected metrics:\n%s\ngot:\n%s", expected, sb.String())
+	}
+}
+
+func TestHandlerMetrics(t *testing.T) {
+	bot := &Bot{lg: log.WithField("src", "test")}
+	bot.Use(Recover(), ErrorReply())
+
+	handlers := map[string]HandlerFunc{
+		"ok":    func(ctx *Context) {},
+		"fail":  func(ctx *Context) { ctx.Fail(errors.New("failed")) },
+		"panic": func(ctx *Context) { panic("boom") },
+	}
+	for route, handler := range handlers {
+		ctx := NewContext(withRouteKey(route), withLogger(log.WithField("src", "test")))
+		bot.dispatch(ctx, time.Second, handler)
+	}
+
+	var sb strings.Builder
+	bot.Metrics().Write(&sb)
+
+	for _, line := range []string{
+		`tony_handler_invocations_total{route="ok"} 1`,
+		`tony_handler_invocations_total{route="fail"} 1`,
+		`tony_handler_invocations_total{route="panic"} 1`,
+		`tony_handler_errors_total{route="fail"} 1`,
+		`tony_handler_panics_total{route="panic"} 1`,
+		`tony_handler_duration_seconds_count{route="ok"} 1`,
+		`tony_gateway_connected 0`,
+	} {
+		if !strings.Contains(sb.String(), line+"\n") {
+			t.Errorf("Expected metrics to contain %q", line)
+		}
+	}
+
+	if strings.Contains(sb.String(), `tony_handler_errors_total{route="ok"}`) {
+		t.Errorf("Expected no errors for the route which did not fail")
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	bot := &Bot{db: db, lg: log.WithField("src", "test")}
+
+	check := func(status int, expected healthStatus) {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		bot.healthHandler()(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		var health healthStatus
+		json.NewDecoder(w.Body).Decode(&health)
+		if w.Code != status || health != expected {
+			t.Errorf("Expected %d %+v, got %d %+v", status, expected, w.Code, health)
+		}
+	}
+
+	// Interactions served over HTTP do not use the gateway
+	check(http.StatusOK, healthStatus{Status: "ok", Database: "ok", Gateway: "unused"})
+
+	bot.gatewayUsed.Store(true)
+	check(http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Database: "ok", Gateway: "disconnected"})
+
+	bot.gatewayConnected.Store(true)
+	check(http.StatusOK, healthStatus{Status: "ok", Database: "ok", Gateway: "connected"})
+
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+	w := httptest.NewRecorder()
+	bot.healthHandler()(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected the closed database to be unhealthy, got %d", w.Code)
+	}
+}
@@ -0,0 +1,96 @@
+package framework
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// HandlerFunc is a single step in the dispatch pipeline. The final step of
+// every pipeline is the application handler itself (OnCommand, OnEvent,
+// OnMessage or OnReaction) wrapped up as a HandlerFunc by the bot.
+type HandlerFunc func(ctx *Context)
+
+// Middleware wraps a HandlerFunc with cross-cutting behaviour. A middleware
+// must call next to continue the dispatch, or it can return early to stop the
+// application handler from being executed.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// chainMiddleware builds the pipeline so the first middleware registered is
+// the outermost, meaning it runs first and finishes last.
+func chainMiddleware(middleware []Middleware, handler HandlerFunc) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Recover stops a panicking handler from taking down the whole goroutine. The
+// panic and stack trace are logged and, if the handler was triggered by an
+// interaction, the user is sent an ephemeral error reply.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			defer func() {
+				if r := recover(); r != nil {
+					ctx.Logger().WithField("stack", string(debug.Stack())).Errorf("Recovered from panic: %v", r)
+					replyError(ctx, "Something went wrong, please try again later")
+				}
+			}()
+
+			next(ctx)
+		}
+	}
+}
+
+// Timing logs how long the handler took to execute.
+func Timing() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			start := time.Now()
+			next(ctx)
+
+			ctx.Logger().WithField("duration_ms", time.Since(start).Milliseconds()).Debug("Handler finished")
+		}
+	}
+}
+
+// ErrorReply sends the standard ephemeral error reply if the handler reported
+// an error through Fail(). Handlers that fail this way should not respond to
+// the interaction themselves.
+func ErrorReply() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			next(ctx)
+
+			if err := ctx.Err(); err != nil {
+				ctx.Logger().WithError(err).Error("Handler failed")
+				replyError(ctx, err.Error())
+			}
+		}
+	}
+}
+
+// replyError responds to the interaction in the context with an ephemeral
+// error message. It does nothing if the context has no interaction, such as
+// for message and reaction handlers, or if the interaction is an autocomplete
+// which can only be responded to with choices.
+func replyError(ctx *Context, message string) {
+	interaction, ok := ctx.ctx.Value(ctxInteraction).(*discordgo.Interaction)
+	if !ok || interaction == nil || interaction.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		return
+	}
+
+	err := ctx.Session().InteractionRespond(interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: fmt.Sprintf("**Error:** %s", message),
+		},
+	})
+	if err != nil {
+		ctx.Logger().WithError(err).Error("Failed to send error reply")
+	}
+}
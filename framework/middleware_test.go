@@ -0,0 +1,63 @@
+package framework
+
+import (
+	"errors"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestChainMiddlewareOrder(t *testing.T) {
+	order := make([]string, 0)
+
+	record := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) {
+				order = append(order, name+":before")
+				next(ctx)
+				order = append(order, name+":after")
+			}
+		}
+	}
+
+	handler := chainMiddleware(
+		[]Middleware{record("first"), record("second")},
+		func(ctx *Context) { order = append(order, "handler") },
+	)
+	handler(NewContext())
+
+	expected := []string{"first:before", "second:before", "handler", "second:after", "first:after"}
+	if len(order) != len(expected) {
+		t.Fatalf("Expected %d steps, got %d: %v", len(expected), len(order), order)
+	}
+
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("Step %d: expected %s, got %s", i, expected[i], order[i])
+		}
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	ctx := NewContext(withLogger(log.WithField("src", "test")))
+
+	handler := chainMiddleware(
+		[]Middleware{Recover()},
+		func(ctx *Context) { panic("boom") },
+	)
+
+	// Should not panic as there is no interaction to reply to
+	handler(ctx)
+}
+
+func TestContextFail(t *testing.T) {
+	ctx := NewContext()
+	if ctx.Err() != nil {
+		t.Errorf("Expected no error on a new context, got %v", ctx.Err())
+	}
+
+	ctx.Fail(errors.New("failed"))
+	if ctx.Err() == nil || ctx.Err().Error() != "failed" {
+		t.Errorf("Expected recorded error, got %v", ctx.Err())
+	}
+}
@@ -0,0 +1,104 @@
+package framework
+
+import (
+	"time"
+
+	"github.com/aussiebroadwan/tony/database/migrate"
+	"gorm.io/gorm"
+)
+
+// guildAppV1, componentStateV1, scheduledJobV1 and rateLimitBucketV1 are the
+// framework's tables as they were first created by migrations, later changes
+// are made by the migrations after
+type guildAppV1 struct {
+	GuildID string `gorm:"primarykey"`
+	Route   string `gorm:"primarykey"`
+	Enabled bool
+}
+
+func (guildAppV1) TableName() string { return "guild_apps" }
+
+type componentStateV1 struct {
+	ID      string `gorm:"primarykey"`
+	Payload []byte
+	Expires time.Time `gorm:"index"`
+}
+
+func (componentStateV1) TableName() string { return "component_states" }
+
+type scheduledJobV1 struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	Name    string
+	Key     string `gorm:"index"`
+	GuildID string
+	Payload []byte
+
+	Every time.Duration
+	Cron  string
+
+	NextRun     time.Time `gorm:"index"`
+	LockedUntil time.Time
+	Attempts    int
+	LastError   string
+	Failed      bool
+}
+
+func (scheduledJobV1) TableName() string { return "scheduled_jobs" }
+
+type rateLimitBucketV1 struct {
+	Key      string `gorm:"primarykey"`
+	Tokens   float64
+	Refilled time.Time
+}
+
+func (rateLimitBucketV1) TableName() string { return "rate_limit_buckets" }
+
+// Migrations are the changes to the framework's tables, in order
+var Migrations = []migrate.Migration{
+	{
+		Package: "framework",
+		Version: 1,
+		Name:    "create guild apps table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&guildAppV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&guildAppV1{})
+		},
+	},
+	{
+		Package: "framework",
+		Version: 2,
+		Name:    "create scheduled jobs table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&scheduledJobV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&scheduledJobV1{})
+		},
+	},
+	{
+		Package: "framework",
+		Version: 3,
+		Name:    "create component states table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&componentStateV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&componentStateV1{})
+		},
+	},
+	{
+		Package: "framework",
+		Version: 4,
+		Name:    "create rate limit buckets table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&rateLimitBucketV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&rateLimitBucketV1{})
+		},
+	},
+}
@@ -0,0 +1,184 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// healthTimeout is how long the database has to respond to a health check
+const healthTimeout = 2 * time.Second
+
+// botMetrics are the metrics the bot records for every handler
+type botMetrics struct {
+	invocations Counter
+	duration    Histogram
+	errors      Counter
+	panics      Counter
+	reconnects  Counter
+}
+
+// Metrics returns the bot's metrics. Apps can add their own, which are served
+// alongside the bot's by ServeMetrics.
+func (b *Bot) Metrics() *Metrics {
+	b.metricsOnce.Do(func() {
+		b.metrics = NewMetrics()
+
+		b.handlerMetrics = botMetrics{
+			invocations: b.metrics.Counter("tony_handler_invocations_total", "Handlers run for each route.", "route"),
+			duration:    b.metrics.Histogram("tony_handler_duration_seconds", "How long the handlers of each route took.", DefaultBuckets, "route"),
+			errors:      b.metrics.Counter("tony_handler_errors_total", "Handlers which failed for each route.", "route"),
+			panics:      b.metrics.Counter("tony_handler_panics_total", "Handlers which panicked for each route.", "route"),
+			reconnects:  b.metrics.Counter("tony_gateway_reconnects_total", "Times the gateway connected again after the first time."),
+		}
+
+		b.metrics.GaugeFunc("tony_gateway_connected", "Whether the gateway is connected.", func() []MetricSample {
+			if b.gatewayConnected.Load() {
+				return []MetricSample{{Value: 1}}
+			}
+			return []MetricSample{{Value: 0}}
+		})
+
+		if b.db != nil {
+			b.metrics.GaugeFunc("tony_jobs_pending", "Scheduled jobs waiting to run, by job name.", b.Scheduler().pendingJobs, "job")
+		}
+	})
+	return b.metrics
+}
+
+// botMetrics returns the metrics recorded for every handler
+func (b *Bot) botMetrics() botMetrics {
+	b.Metrics()
+	return b.handlerMetrics
+}
+
+// recordHandler runs the handler through the middleware, recording how long
+// it took and whether it failed or panicked under the context's route key. A
+// panic is counted and passed on for the Recover middleware to handle.
+func (b *Bot) recordHandler(ctx *Context, handler HandlerFunc) {
+	metrics := b.botMetrics()
+	route := ctx.RouteKey()
+
+	start := time.Now()
+	defer func() {
+		metrics.invocations.Inc(route)
+		metrics.duration.Observe(time.Since(start).Seconds(), route)
+		if ctx.Err() != nil {
+			metrics.errors.Inc(route)
+		}
+	}()
+
+	chainMiddleware(b.middleware, func(ctx *Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				metrics.panics.Inc(route)
+				panic(r)
+			}
+		}()
+
+		handler(ctx)
+	})(ctx)
+}
+
+// watchGateway keeps track of whether the gateway is connected and counts
+// the times it reconnects
+func (b *Bot) watchGateway() {
+	b.gatewayUsed.Store(true)
+
+	b.Discord.AddHandler(func(s *discordgo.Session, c *discordgo.Connect) {
+		b.gatewayConnected.Store(true)
+		if b.gatewayConnects.Add(1) > 1 {
+			b.botMetrics().reconnects.Inc()
+		}
+	})
+	b.Discord.AddHandler(func(s *discordgo.Session, d *discordgo.Disconnect) {
+		b.gatewayConnected.Store(false)
+	})
+}
+
+type healthStatus struct {
+	Status   string `json:"status"`
+	Database string `json:"database"`
+	Gateway  string `json:"gateway"`
+}
+
+// health reports whether the database can be reached and the gateway is
+// connected. The gateway is "unused" when interactions are served over HTTP.
+func (b *Bot) health(ctx context.Context) (healthStatus, bool) {
+	health := healthStatus{Status: "ok", Database: "ok", Gateway: "unused"}
+	healthy := true
+
+	if err := b.pingDatabase(ctx); err != nil {
+		health.Database = err.Error()
+		healthy = false
+	}
+
+	if b.gatewayUsed.Load() {
+		health.Gateway = "connected"
+		if !b.gatewayConnected.Load() {
+			health.Gateway = "disconnected"
+			healthy = false
+		}
+	}
+
+	if !healthy {
+		health.Status = "unavailable"
+	}
+	return health, healthy
+}
+
+func (b *Bot) pingDatabase(ctx context.Context) error {
+	if b.db == nil {
+		return nil
+	}
+
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// healthHandler responds with the bot's health as JSON, with a 503 status if
+// it is unhealthy
+func (b *Bot) healthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		health, healthy := b.health(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(health)
+	}
+}
+
+// ServeMetrics serves the metrics on /metrics and the bot's health on
+// /healthz at the address until the bot is closed.
+func (b *Bot) ServeMetrics(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", b.Metrics())
+	mux.Handle("/healthz", b.healthHandler())
+	b.metricsServer = &http.Server{Handler: mux}
+
+	go func() {
+		if err := b.metricsServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			b.lg.WithError(err).Error("Metrics endpoint stopped")
+		}
+	}()
+
+	b.lg.Infof("Serving metrics on %s/metrics", listener.Addr())
+	return nil
+}
@@ -0,0 +1,283 @@
+package framework
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Command options can be bound to the fields of a struct with the option tag,
+// which holds the option name followed by any of "required", "autocomplete",
+// "min=<n>" and "max=<n>". The min and max limit the value of numbers and the
+// length of strings. The option description is set with the description tag.
+//
+//	type PayArgs struct {
+//		User   *discordgo.User `option:"user,required" description:"The user to pay"`
+//		Amount int64           `option:"amount,required,min=1" description:"The amount to pay"`
+//	}
+//
+// Fields can be a string, bool, any int or float, or a *discordgo.User,
+// *discordgo.Channel or *discordgo.Role.
+
+var (
+	userType    = reflect.TypeOf(&discordgo.User{})
+	channelType = reflect.TypeOf(&discordgo.Channel{})
+	roleType    = reflect.TypeOf(&discordgo.Role{})
+)
+
+// optionField is a struct field bound to a command option
+type optionField struct {
+	index        int
+	name         string
+	description  string
+	optionType   discordgo.ApplicationCommandOptionType
+	required     bool
+	autocomplete bool
+	min          *float64
+	max          *float64
+}
+
+// optionFields parses the option tags of the struct type
+func optionFields(t reflect.Type) ([]optionField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("options must be bound to a struct, not %s", t)
+	}
+
+	fields := make([]optionField, 0)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("option")
+		if !ok || !sf.IsExported() {
+			continue
+		}
+
+		optionType, ok := fieldOptionType(sf.Type)
+		if !ok {
+			return nil, fmt.Errorf("field %s has unsupported option type %s", sf.Name, sf.Type)
+		}
+
+		parts := strings.Split(tag, ",")
+		field := optionField{
+			index:       i,
+			name:        parts[0],
+			description: sf.Tag.Get("description"),
+			optionType:  optionType,
+		}
+		if field.name == "" {
+			field.name = strings.ToLower(sf.Name)
+		}
+
+		for _, part := range parts[1:] {
+			key, value, _ := strings.Cut(part, "=")
+			switch key {
+			case "required":
+				field.required = true
+			case "autocomplete":
+				field.autocomplete = true
+			case "min", "max":
+				limit, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("field %s has invalid %s %q", sf.Name, key, value)
+				}
+				if key == "min" {
+					field.min = &limit
+				} else {
+					field.max = &limit
+				}
+			default:
+				return nil, fmt.Errorf("field %s has unknown option setting %q", sf.Name, part)
+			}
+		}
+
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func fieldOptionType(t reflect.Type) (discordgo.ApplicationCommandOptionType, bool) {
+	switch t {
+	case userType:
+		return discordgo.ApplicationCommandOptionUser, true
+	case channelType:
+		return discordgo.ApplicationCommandOptionChannel, true
+	case roleType:
+		return discordgo.ApplicationCommandOptionRole, true
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return discordgo.ApplicationCommandOptionString, true
+	case reflect.Bool:
+		return discordgo.ApplicationCommandOptionBoolean, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return discordgo.ApplicationCommandOptionInteger, true
+	case reflect.Float32, reflect.Float64:
+		return discordgo.ApplicationCommandOptionNumber, true
+	}
+	return 0, false
+}
+
+// CommandOptions generates the command option definitions for the tagged
+// fields of args. Required options are listed first as Discord requires.
+func CommandOptions(args any) []*discordgo.ApplicationCommandOption {
+	fields, err := optionFields(reflect.TypeOf(args))
+	if err != nil {
+		log.WithField("src", "options").Fatalf("Invalid command options: %s", err)
+	}
+
+	options := make([]*discordgo.ApplicationCommandOption, 0, len(fields))
+	for _, field := range fields {
+		option := &discordgo.ApplicationCommandOption{
+			Type:         field.optionType,
+			Name:         field.name,
+			Description:  field.description,
+			Required:     field.required,
+			Autocomplete: field.autocomplete,
+		}
+
+		if field.optionType == discordgo.ApplicationCommandOptionString {
+			if field.min != nil {
+				minLength := int(*field.min)
+				option.MinLength = &minLength
+			}
+			if field.max != nil {
+				option.MaxLength = int(*field.max)
+			}
+		} else {
+			option.MinValue = field.min
+			if field.max != nil {
+				option.MaxValue = *field.max
+			}
+		}
+
+		options = append(options, option)
+	}
+
+	sort.SliceStable(options, func(i, j int) bool {
+		return options[i].Required && !options[j].Required
+	})
+	return options
+}
+
+// leafOptions returns the options of the subcommand being run, descending
+// through any subcommand groups
+func leafOptions(opts []*discordgo.ApplicationCommandInteractionDataOption) []*discordgo.ApplicationCommandInteractionDataOption {
+	for len(opts) == 1 && isSubCommand(opts[0]) {
+		opts = opts[0].Options
+	}
+	return opts
+}
+
+// BindOptions decodes the options of the command into the tagged fields of
+// the struct dst points to. An error is returned if a required option is
+// missing or a value is outside of its limits.
+func BindOptions(data discordgo.ApplicationCommandInteractionData, dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("options must be bound to a pointer to a struct, not %T", dst)
+	}
+	v = v.Elem()
+
+	fields, err := optionFields(v.Type())
+	if err != nil {
+		return err
+	}
+
+	options := leafOptions(data.Options)
+	for _, field := range fields {
+		opt, err := GetOption(options, field.name)
+		if err != nil {
+			if field.required {
+				return fmt.Errorf("%s is required", field.name)
+			}
+			continue
+		}
+
+		if opt.Type != field.optionType {
+			return fmt.Errorf("%s should be %s, not %s", field.name, field.optionType, opt.Type)
+		}
+
+		if err := bindOption(v.Field(field.index), field, opt, data.Resolved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bindOption(v reflect.Value, field optionField, opt *discordgo.ApplicationCommandInteractionDataOption, resolved *discordgo.ApplicationCommandInteractionDataResolved) error {
+	if resolved == nil {
+		resolved = &discordgo.ApplicationCommandInteractionDataResolved{}
+	}
+
+	switch field.optionType {
+	case discordgo.ApplicationCommandOptionString:
+		value := opt.StringValue()
+		if err := checkLimits(field, float64(utf8.RuneCountInString(value)), " characters"); err != nil {
+			return err
+		}
+		v.SetString(value)
+
+	case discordgo.ApplicationCommandOptionBoolean:
+		v.SetBool(opt.BoolValue())
+
+	case discordgo.ApplicationCommandOptionInteger:
+		value := opt.IntValue()
+		if err := checkLimits(field, float64(value), ""); err != nil {
+			return err
+		}
+		if v.OverflowInt(value) {
+			return fmt.Errorf("%s is too large", field.name)
+		}
+		v.SetInt(value)
+
+	case discordgo.ApplicationCommandOptionNumber:
+		value := opt.FloatValue()
+		if err := checkLimits(field, value, ""); err != nil {
+			return err
+		}
+		v.SetFloat(value)
+
+	case discordgo.ApplicationCommandOptionUser:
+		id := opt.Value.(string)
+		user, ok := resolved.Users[id]
+		if !ok {
+			user = &discordgo.User{ID: id}
+		}
+		v.Set(reflect.ValueOf(user))
+
+	case discordgo.ApplicationCommandOptionChannel:
+		id := opt.Value.(string)
+		channel, ok := resolved.Channels[id]
+		if !ok {
+			channel = &discordgo.Channel{ID: id}
+		}
+		v.Set(reflect.ValueOf(channel))
+
+	case discordgo.ApplicationCommandOptionRole:
+		id := opt.Value.(string)
+		role, ok := resolved.Roles[id]
+		if !ok {
+			role = &discordgo.Role{ID: id}
+		}
+		v.Set(reflect.ValueOf(role))
+	}
+	return nil
+}
+
+// checkLimits checks the value is within the field's min and max
+func checkLimits(field optionField, value float64, unit string) error {
+	if field.min != nil && value < *field.min {
+		return fmt.Errorf("%s must be at least %v%s", field.name, *field.min, unit)
+	}
+	if field.max != nil && value > *field.max {
+		return fmt.Errorf("%s must be at most %v%s", field.name, *field.max, unit)
+	}
+	return nil
+}
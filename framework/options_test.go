@@ -0,0 +1,144 @@
+package framework
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type testArgs struct {
+	Note   string          `option:"note,max=5" description:"A note"`
+	User   *discordgo.User `option:"user,required" description:"A user"`
+	Amount int64           `option:"amount,required,min=1,max=100" description:"An amount"`
+	Ratio  float64         `option:"ratio" description:"A ratio"`
+	Public bool            `option:"public" description:"Show everyone"`
+
+	hidden string `option:"hidden"`
+}
+
+func TestCommandOptions(t *testing.T) {
+	options := CommandOptions(testArgs{})
+
+	// Required options are moved to the front
+	expected := []struct {
+		name       string
+		optionType discordgo.ApplicationCommandOptionType
+		required   bool
+	}{
+		{"user", discordgo.ApplicationCommandOptionUser, true},
+		{"amount", discordgo.ApplicationCommandOptionInteger, true},
+		{"note", discordgo.ApplicationCommandOptionString, false},
+		{"ratio", discordgo.ApplicationCommandOptionNumber, false},
+		{"public", discordgo.ApplicationCommandOptionBoolean, false},
+	}
+
+	if len(options) != len(expected) {
+		t.Fatalf("Expected %d options, got %d", len(expected), len(options))
+	}
+	for i, e := range expected {
+		if options[i].Name != e.name || options[i].Type != e.optionType || options[i].Required != e.required {
+			t.Errorf("Option %d: expected %+v, got %+v", i, e, options[i])
+		}
+	}
+
+	amount := options[1]
+	if amount.MinValue == nil || *amount.MinValue != 1 || amount.MaxValue != 100 {
+		t.Errorf("Expected amount to be limited to 1-100, got %v-%v", amount.MinValue, amount.MaxValue)
+	}
+	if note := options[2]; note.MaxLength != 5 || note.MinLength != nil {
+		t.Errorf("Expected note to be limited to 5 characters, got %v-%v", note.MinLength, note.MaxLength)
+	}
+}
+
+func TestBindOptions(t *testing.T) {
+	// "/cmd group sub ..." with the options nested in a subcommand group
+	data := func(opts ...*discordgo.ApplicationCommandInteractionDataOption) discordgo.ApplicationCommandInteractionData {
+		return discordgo.ApplicationCommandInteractionData{
+			Name: "cmd",
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+				Name: "group",
+				Type: discordgo.ApplicationCommandOptionSubCommandGroup,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+					Name:    "sub",
+					Type:    discordgo.ApplicationCommandOptionSubCommand,
+					Options: opts,
+				}},
+			}},
+			Resolved: &discordgo.ApplicationCommandInteractionDataResolved{
+				Users: map[string]*discordgo.User{"1": {ID: "1", Username: "alice"}},
+			},
+		}
+	}
+
+	user := &discordgo.ApplicationCommandInteractionDataOption{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: "1"}
+	amount := func(v float64) *discordgo.ApplicationCommandInteractionDataOption {
+		return &discordgo.ApplicationCommandInteractionDataOption{Name: "amount", Type: discordgo.ApplicationCommandOptionInteger, Value: v}
+	}
+
+	var args testArgs
+	err := BindOptions(data(user, amount(50),
+		&discordgo.ApplicationCommandInteractionDataOption{Name: "note", Type: discordgo.ApplicationCommandOptionString, Value: "hi"},
+		&discordgo.ApplicationCommandInteractionDataOption{Name: "public", Type: discordgo.ApplicationCommandOptionBoolean, Value: true},
+	), &args)
+	if err != nil {
+		t.Fatalf("Failed to bind options: %v", err)
+	}
+
+	if args.User == nil || args.User.Username != "alice" {
+		t.Errorf("Expected the resolved user, got %+v", args.User)
+	}
+	if args.Amount != 50 || args.Note != "hi" || !args.Public || args.Ratio != 0 {
+		t.Errorf("Unexpected options bound: %+v", args)
+	}
+
+	errors := []struct {
+		name string
+		opts []*discordgo.ApplicationCommandInteractionDataOption
+		err  string
+	}{
+		{"missing required", []*discordgo.ApplicationCommandInteractionDataOption{user}, "amount is required"},
+		{"below min", []*discordgo.ApplicationCommandInteractionDataOption{user, amount(0)}, "amount must be at least 1"},
+		{"above max", []*discordgo.ApplicationCommandInteractionDataOption{user, amount(101)}, "amount must be at most 100"},
+		{"too long", []*discordgo.ApplicationCommandInteractionDataOption{user, amount(1),
+			{Name: "note", Type: discordgo.ApplicationCommandOptionString, Value: "too long"},
+		}, "note must be at most 5 characters"},
+		{"wrong type", []*discordgo.ApplicationCommandInteractionDataOption{user,
+			{Name: "amount", Type: discordgo.ApplicationCommandOptionString, Value: "1"},
+		}, "amount should be Integer, not String"},
+	}
+
+	for _, test := range errors {
+		t.Run(test.name, func(t *testing.T) {
+			var args testArgs
+			err := BindOptions(data(test.opts...), &args)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("Expected error %q, got %v", test.err, err)
+			}
+		})
+	}
+
+	if err := BindOptions(data(), testArgs{}); err == nil {
+		t.Errorf("Expected an error binding to a non pointer")
+	}
+}
+
+func TestOptionFieldsInvalid(t *testing.T) {
+	invalid := []any{
+		struct {
+			Amount int64 `option:"amount,min=one"`
+		}{},
+		struct {
+			Amount int64 `option:"amount,minimum=1"`
+		}{},
+		struct {
+			Amount uint `option:"amount"`
+		}{},
+	}
+
+	for _, args := range invalid {
+		if _, err := optionFields(reflect.TypeOf(args)); err == nil {
+			t.Errorf("Expected %T to be invalid", args)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package framework
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm"
+)
+
+// rateLimitPruneInterval is how often idle buckets are removed from the store
+const rateLimitPruneInterval = time.Minute
+
+type RateLimitScope int
+
+const (
+	// RateLimitUser gives every user their own bucket
+	RateLimitUser RateLimitScope = iota
+
+	// RateLimitChannel gives every channel its own bucket, shared by all the
+	// users in the channel
+	RateLimitChannel
+
+	// RateLimitGlobal shares a single bucket between everyone
+	RateLimitGlobal
+)
+
+// RateLimit is a token bucket applied to the routes matching Route. Commands
+// are matched by their route key (e.g. "snailrace.host") and events by their
+// custom ID (e.g. "blackjack:hit"), with glob patterns such as "blackjack:*"
+// supported.
+type RateLimit struct {
+	Route string
+	Scope RateLimitScope
+
+	// Capacity is the number of uses available at once
+	Capacity int
+
+	// Refill is the time it takes for a single use to become available again
+	Refill time.Duration
+}
+
+// RateLimitBucket is the persisted state of a single token bucket.
+type RateLimitBucket struct {
+	Key      string `gorm:"primarykey"`
+	Tokens   float64
+	Refilled time.Time
+}
+
+// RateLimitStore keeps the token buckets between interactions. Prune removes
+// the buckets last refilled before the time.
+type RateLimitStore interface {
+	Get(key string) (RateLimitBucket, bool, error)
+	Put(bucket RateLimitBucket) error
+	Prune(before time.Time) error
+}
+
+// MemoryRateLimitStore keeps the token buckets in memory, they are lost when
+// the bot restarts.
+type MemoryRateLimitStore struct {
+	buckets map[string]RateLimitBucket
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]RateLimitBucket)}
+}
+
+func (s *MemoryRateLimitStore) Get(key string) (RateLimitBucket, bool, error) {
+	bucket, ok := s.buckets[key]
+	return bucket, ok, nil
+}
+
+func (s *MemoryRateLimitStore) Put(bucket RateLimitBucket) error {
+	s.buckets[bucket.Key] = bucket
+	return nil
+}
+
+func (s *MemoryRateLimitStore) Prune(before time.Time) error {
+	for key, bucket := range s.buckets {
+		if bucket.Refilled.Before(before) {
+			delete(s.buckets, key)
+		}
+	}
+	return nil
+}
+
+// DatabaseRateLimitStore persists the token buckets in the database so limits
+// survive a restart.
+type DatabaseRateLimitStore struct {
+	db *gorm.DB
+}
+
+// NewDatabaseRateLimitStore creates the store, its table is created by
+// Migrations.
+func NewDatabaseRateLimitStore(db *gorm.DB) *DatabaseRateLimitStore {
+	return &DatabaseRateLimitStore{db: db}
+}
+
+func (s *DatabaseRateLimitStore) Get(key string) (RateLimitBucket, bool, error) {
+	var buckets []RateLimitBucket
+	if err := s.db.Where(RateLimitBucket{Key: key}).Limit(1).Find(&buckets).Error; err != nil {
+		return RateLimitBucket{}, false, err
+	}
+
+	if len(buckets) == 0 {
+		return RateLimitBucket{}, false, nil
+	}
+	return buckets[0], true, nil
+}
+
+func (s *DatabaseRateLimitStore) Put(bucket RateLimitBucket) error {
+	return s.db.Save(&bucket).Error
+}
+
+func (s *DatabaseRateLimitStore) Prune(before time.Time) error {
+	return s.db.Where("refilled < ?", before).Delete(&RateLimitBucket{}).Error
+}
+
+// RateLimiter throttles interactions using token buckets.
+type RateLimiter struct {
+	limits []RateLimit
+	store  RateLimitStore
+	now    func() time.Time
+
+	// idle is how long until any bucket is full again, when it can be
+	// removed from the store as a missing bucket starts full. Buckets are
+	// kept if a limit never refills.
+	idle time.Duration
+
+	mu     sync.Mutex
+	pruned time.Time
+}
+
+// NewRateLimiter creates a rate limiter for the given limits. If more than one
+// limit matches a route then all of them have to allow the interaction.
+func NewRateLimiter(store RateLimitStore, limits ...RateLimit) *RateLimiter {
+	var idle time.Duration
+	for _, limit := range limits {
+		if limit.Refill <= 0 {
+			idle = 0
+			break
+		}
+		idle = max(idle, time.Duration(limit.Capacity)*limit.Refill)
+	}
+
+	return &RateLimiter{
+		limits: limits,
+		store:  store,
+		now:    time.Now,
+		idle:   idle,
+	}
+}
+
+// Allow takes a token from every bucket matching the route. It returns false
+// and how long to wait if any bucket is empty, in which case no tokens are
+// taken.
+func (rl *RateLimiter) Allow(route, userId, channelId string) (bool, time.Duration, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.prune(now)
+
+	// Check every bucket before taking from any, so being limited by one
+	// doesn't use up the others
+	type match struct {
+		limit  RateLimit
+		bucket RateLimitBucket
+	}
+	matches := []match{}
+	allowed, wait := true, time.Duration(0)
+
+	for _, limit := range rl.limits {
+		if matched, _ := path.Match(limit.Route, route); !matched {
+			continue
+		}
+
+		key := limit.Route + "|" + limit.scopeKey(userId, channelId)
+		bucket, ok, err := rl.store.Get(key)
+		if err != nil {
+			return true, 0, err
+		}
+
+		if !ok {
+			bucket = RateLimitBucket{Key: key, Tokens: float64(limit.Capacity), Refilled: now}
+		}
+
+		limit.refill(&bucket, now)
+		if has, w := limit.available(bucket); !has {
+			allowed, wait = false, max(wait, w)
+		}
+		matches = append(matches, match{limit, bucket})
+	}
+
+	if !allowed {
+		return false, wait, nil
+	}
+
+	for _, m := range matches {
+		m.bucket.Tokens--
+		if err := rl.store.Put(m.bucket); err != nil {
+			return true, 0, err
+		}
+	}
+
+	return true, 0, nil
+}
+
+// prune removes idle buckets from the store every rateLimitPruneInterval
+func (rl *RateLimiter) prune(now time.Time) {
+	if rl.idle <= 0 || now.Sub(rl.pruned) < rateLimitPruneInterval {
+		return
+	}
+
+	rl.pruned = now
+	rl.store.Prune(now.Add(-rl.idle))
+}
+
+// Middleware creates a middleware which replies with an ephemeral message and
+// stops the handler when the interaction is rate limited.
+func (rl *RateLimiter) Middleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			interaction, ok := ctx.ctx.Value(ctxInteraction).(*discordgo.Interaction)
+			if !ok || interaction == nil {
+				next(ctx)
+				return
+			}
+
+			// Autocomplete runs as the user types, it isn't a use
+			if interaction.Type == discordgo.InteractionApplicationCommandAutocomplete {
+				next(ctx)
+				return
+			}
+
+			// Events are matched by their full custom id
+			route := ctx.RouteKey()
+			if value, ok := ctx.ctx.Value(ctxEventValue).(string); ok && value != "" {
+				route = route + ":" + value
+			}
+
+			allowed, wait, err := rl.Allow(route, ctx.GetUser().ID, interaction.ChannelID)
+			if err != nil {
+				ctx.Logger().WithError(err).Error("Failed to check rate limit")
+			}
+
+			if !allowed {
+				ctx.Logger().WithField("wait", wait.String()).Warn("Rate limited")
+				replyError(ctx, fmt.Sprintf("You're doing that too much, try again in %ds", int(math.Ceil(wait.Seconds()))))
+				return
+			}
+
+			next(ctx)
+		}
+	}
+}
+
+// scopeKey builds the part of the bucket key which identifies who is sharing
+// the bucket.
+func (l RateLimit) scopeKey(userId, channelId string) string {
+	switch l.Scope {
+	case RateLimitChannel:
+		return "channel:" + channelId
+	case RateLimitGlobal:
+		return "global"
+	default:
+		return "user:" + userId
+	}
+}
+
+// refill adds the tokens refilled in the time elapsed to the bucket.
+func (l RateLimit) refill(bucket *RateLimitBucket, now time.Time) {
+	if l.Refill > 0 {
+		elapsed := now.Sub(bucket.Refilled)
+		bucket.Tokens = math.Min(float64(l.Capacity), bucket.Tokens+float64(elapsed)/float64(l.Refill))
+	}
+	bucket.Refilled = now
+}
+
+// available checks if the bucket has a token, otherwise it returns how long
+// until the next token.
+func (l RateLimit) available(bucket RateLimitBucket) (bool, time.Duration) {
+	if bucket.Tokens >= 1 {
+		return true, 0
+	}
+
+	return false, time.Duration((1 - bucket.Tokens) * float64(l.Refill))
+}
@@ -0,0 +1,159 @@
+package framework
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestRateLimiterUserBucket(t *testing.T) {
+	now := time.Now()
+	rl := NewRateLimiter(NewMemoryRateLimitStore(), RateLimit{
+		Route:    "wallet.pay",
+		Scope:    RateLimitUser,
+		Capacity: 2,
+		Refill:   10 * time.Second,
+	})
+	rl.now = func() time.Time { return now }
+
+	// Use up the bucket
+	for i := 0; i < 2; i++ {
+		if allowed, _, _ := rl.Allow("wallet.pay", "user1", "channel"); !allowed {
+			t.Fatalf("Expected use %d to be allowed", i+1)
+		}
+	}
+
+	allowed, wait, _ := rl.Allow("wallet.pay", "user1", "channel")
+	if allowed || wait != 10*time.Second {
+		t.Errorf("Expected to be limited for 10s, got allowed=%v wait=%v", allowed, wait)
+	}
+
+	// Another user has their own bucket
+	if allowed, _, _ := rl.Allow("wallet.pay", "user2", "channel"); !allowed {
+		t.Errorf("Expected another user to be allowed")
+	}
+
+	// Routes that don't match are not limited
+	if allowed, _, _ := rl.Allow("wallet.balance", "user1", "channel"); !allowed {
+		t.Errorf("Expected unmatched route to be allowed")
+	}
+
+	// Wait for a token to refill
+	now = now.Add(10 * time.Second)
+	if allowed, _, _ := rl.Allow("wallet.pay", "user1", "channel"); !allowed {
+		t.Errorf("Expected use to be allowed after refill")
+	}
+}
+
+func TestRateLimiterGlobPattern(t *testing.T) {
+	rl := NewRateLimiter(NewMemoryRateLimitStore(), RateLimit{
+		Route:    "blackjack:*",
+		Scope:    RateLimitChannel,
+		Capacity: 1,
+		Refill:   time.Minute,
+	})
+
+	if allowed, _, _ := rl.Allow("blackjack:hit", "user1", "channel"); !allowed {
+		t.Fatalf("Expected first use to be allowed")
+	}
+
+	// Channel buckets are shared between users
+	if allowed, _, _ := rl.Allow("blackjack:stand", "user2", "channel"); allowed {
+		t.Errorf("Expected channel bucket to be shared")
+	}
+
+	if allowed, _, _ := rl.Allow("blackjack:hit", "user1", "other"); !allowed {
+		t.Errorf("Expected another channel to be allowed")
+	}
+}
+
+func TestRateLimiterChecksEveryLimit(t *testing.T) {
+	rl := NewRateLimiter(NewMemoryRateLimitStore(),
+		RateLimit{Route: "wallet.pay", Scope: RateLimitUser, Capacity: 1, Refill: time.Minute},
+		RateLimit{Route: "wallet.*", Scope: RateLimitChannel, Capacity: 1, Refill: time.Minute},
+	)
+
+	if allowed, _, _ := rl.Allow("wallet.pay", "user1", "channel1"); !allowed {
+		t.Fatalf("Expected first use to be allowed")
+	}
+
+	// The channel's bucket is empty, which doesn't take from the user's
+	if allowed, _, _ := rl.Allow("wallet.pay", "user2", "channel1"); allowed {
+		t.Errorf("Expected the channel to be limited")
+	}
+	if allowed, _, _ := rl.Allow("wallet.pay", "user2", "channel2"); !allowed {
+		t.Errorf("Expected the user's bucket to be left untouched when limited")
+	}
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryRateLimitStore()
+	rl := NewRateLimiter(store, RateLimit{
+		Route:    "wallet.pay",
+		Scope:    RateLimitUser,
+		Capacity: 2,
+		Refill:   10 * time.Second,
+	})
+	rl.now = func() time.Time { return now }
+
+	rl.Allow("wallet.pay", "user1", "channel")
+
+	// The bucket is full again by the time it is pruned
+	now = now.Add(rateLimitPruneInterval)
+	rl.Allow("wallet.pay", "user2", "channel")
+
+	if _, ok, _ := store.Get("wallet.pay|user:user1"); ok {
+		t.Errorf("Expected the idle bucket to be removed")
+	}
+	if _, ok, _ := store.Get("wallet.pay|user:user2"); !ok {
+		t.Errorf("Expected the bucket in use to be kept")
+	}
+}
+
+func TestRateLimiterSkipsAutocomplete(t *testing.T) {
+	rl := NewRateLimiter(NewMemoryRateLimitStore(), RateLimit{
+		Route:    "wallet.pay",
+		Scope:    RateLimitUser,
+		Capacity: 1,
+		Refill:   time.Minute,
+	})
+
+	calls := 0
+	handler := chainMiddleware([]Middleware{rl.Middleware()}, func(ctx *Context) { calls++ })
+
+	interaction := func(interactionType discordgo.InteractionType) *discordgo.Interaction {
+		return &discordgo.Interaction{
+			Type:      interactionType,
+			ChannelID: "channel",
+			Member:    &discordgo.Member{User: &discordgo.User{ID: "user1"}},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name: "wallet",
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{Name: "pay", Type: discordgo.ApplicationCommandOptionSubCommand},
+				},
+			},
+		}
+	}
+
+	for _, interactionType := range []discordgo.InteractionType{
+		discordgo.InteractionApplicationCommandAutocomplete,
+		discordgo.InteractionApplicationCommandAutocomplete,
+		discordgo.InteractionApplicationCommand,
+		discordgo.InteractionApplicationCommand,
+	} {
+		ctx, err := NewInteractionContext(NewRecordingSession(), nil, log.WithField("src", "test"), interaction(interactionType))
+		if err != nil {
+			t.Fatalf("Failed to create context: %v", err)
+		}
+		handler(ctx)
+	}
+
+	// Only the second command is limited
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
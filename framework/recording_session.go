@@ -0,0 +1,267 @@
+package framework
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// RecordingSession is an in-memory Session which records everything sent to
+// it instead of calling Discord. The console prints what it records, and
+// frameworktest.FakeSession wraps it for testing application handlers. Lock
+// it to use the fields while handlers may be running.
+type RecordingSession struct {
+	// Responses are the interaction responses in the order they were sent
+	Responses []*discordgo.InteractionResponse
+
+	// Followups are the interaction follow up messages in the order they were
+	// sent
+	Followups []*discordgo.WebhookParams
+
+	// Messages are the channel messages in the order they were sent, edits
+	// are applied to the message in place
+	Messages []*discordgo.Message
+
+	// Deleted, Pinned and Reactions record the "channelId:messageId" (and
+	// ":emoji" for reactions) of each action
+	Deleted   []string
+	Pinned    []string
+	Reactions []string
+
+	// Users are returned by User(), unknown users only have their ID set
+	Users map[string]*discordgo.User
+
+	// Channels are returned by GuildChannels() for every guild
+	Channels []*discordgo.Channel
+
+	nextId int
+	sync.Mutex
+}
+
+func NewRecordingSession() *RecordingSession {
+	return &RecordingSession{
+		Responses: make([]*discordgo.InteractionResponse, 0),
+		Followups: make([]*discordgo.WebhookParams, 0),
+		Messages:  make([]*discordgo.Message, 0),
+		Deleted:   make([]string, 0),
+		Pinned:    make([]string, 0),
+		Reactions: make([]string, 0),
+		Users:     make(map[string]*discordgo.User),
+		Channels:  make([]*discordgo.Channel, 0),
+	}
+}
+
+// LastResponse returns the most recent interaction response, or nil if there
+// are none.
+func (s *RecordingSession) LastResponse() *discordgo.InteractionResponse {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.Responses) == 0 {
+		return nil
+	}
+	return s.Responses[len(s.Responses)-1]
+}
+
+// LastMessage returns the most recently sent channel message, or nil if there
+// are none.
+func (s *RecordingSession) LastMessage() *discordgo.Message {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.Messages) == 0 {
+		return nil
+	}
+	return s.Messages[len(s.Messages)-1]
+}
+
+func (s *RecordingSession) newId() string {
+	s.nextId++
+	return fmt.Sprintf("%d", s.nextId)
+}
+
+// FindMessage returns the recorded message, or nil if it is unknown. The
+// session must be locked.
+func (s *RecordingSession) FindMessage(channelId, messageId string) *discordgo.Message {
+	for _, m := range s.Messages {
+		if m.ChannelID == channelId && m.ID == messageId {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *RecordingSession) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Responses = append(s.Responses, resp)
+	return nil
+}
+
+func (s *RecordingSession) InteractionResponse(interaction *discordgo.Interaction, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.Responses) == 0 {
+		return nil, fmt.Errorf("no response to interaction %s", interaction.ID)
+	}
+
+	message := &discordgo.Message{ID: interaction.ID, ChannelID: interaction.ChannelID}
+	if data := s.Responses[len(s.Responses)-1].Data; data != nil {
+		message.Content = data.Content
+		message.Embeds = data.Embeds
+		message.Components = data.Components
+	}
+	return message, nil
+}
+
+func (s *RecordingSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	data := &discordgo.InteractionResponseData{}
+	if newresp.Content != nil {
+		data.Content = *newresp.Content
+	}
+	if newresp.Embeds != nil {
+		data.Embeds = *newresp.Embeds
+	}
+	if newresp.Components != nil {
+		data.Components = *newresp.Components
+	}
+
+	s.Responses = append(s.Responses, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: data,
+	})
+	return &discordgo.Message{ID: interaction.ID, ChannelID: interaction.ChannelID, Content: data.Content}, nil
+}
+
+// InteractionResponseDelete records the original response as deleted, by
+// the interaction's ID as InteractionResponse() gives it
+func (s *RecordingSession) InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Deleted = append(s.Deleted, interaction.ChannelID+":"+interaction.ID)
+	return nil
+}
+
+func (s *RecordingSession) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Followups = append(s.Followups, data)
+	return &discordgo.Message{ID: s.newId(), ChannelID: interaction.ChannelID, Content: data.Content}, nil
+}
+
+func (s *RecordingSession) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content})
+}
+
+func (s *RecordingSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+func (s *RecordingSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	message := &discordgo.Message{
+		ID:         s.newId(),
+		ChannelID:  channelID,
+		Content:    data.Content,
+		Embeds:     data.Embeds,
+		Components: data.Components,
+	}
+	s.Messages = append(s.Messages, message)
+	return message, nil
+}
+
+func (s *RecordingSession) ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	edit := discordgo.NewMessageEdit(channelID, messageID)
+	edit.Content = &content
+	return s.ChannelMessageEditComplex(edit)
+}
+
+func (s *RecordingSession) ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	message := s.FindMessage(m.Channel, m.ID)
+	if message == nil {
+		return nil, fmt.Errorf("message %s not found in channel %s", m.ID, m.Channel)
+	}
+
+	if m.Content != nil {
+		message.Content = *m.Content
+	}
+	if m.Embeds != nil {
+		message.Embeds = *m.Embeds
+	}
+	if m.Components != nil {
+		message.Components = *m.Components
+	}
+	return message, nil
+}
+
+func (s *RecordingSession) ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Deleted = append(s.Deleted, channelID+":"+messageID)
+	return nil
+}
+
+func (s *RecordingSession) ChannelMessagePin(channelID, messageID string, options ...discordgo.RequestOption) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Pinned = append(s.Pinned, channelID+":"+messageID)
+	return nil
+}
+
+func (s *RecordingSession) ChannelMessageUnpin(channelID, messageID string, options ...discordgo.RequestOption) error {
+	s.Lock()
+	defer s.Unlock()
+
+	key := channelID + ":" + messageID
+	for i, pinned := range s.Pinned {
+		if pinned == key {
+			s.Pinned = append(s.Pinned[:i], s.Pinned[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("message %s is not pinned", messageID)
+}
+
+func (s *RecordingSession) MessageReactionAdd(channelID, messageID, emojiID string, options ...discordgo.RequestOption) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Reactions = append(s.Reactions, channelID+":"+messageID+":"+emojiID)
+	return nil
+}
+
+func (s *RecordingSession) User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if user, ok := s.Users[userID]; ok {
+		return user, nil
+	}
+	return &discordgo.User{ID: userID}, nil
+}
+
+func (s *RecordingSession) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return &discordgo.Channel{ID: "dm-" + recipientID, Type: discordgo.ChannelTypeDM}, nil
+}
+
+func (s *RecordingSession) GuildChannels(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Channel, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.Channels, nil
+}
@@ -0,0 +1,182 @@
+package framework
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	ErrMissingPermissions = errors.New("you do not have the required permissions to use this")
+	ErrMissingRole        = errors.New("you do not have the required role to use this")
+	ErrChannelNotAllowed  = errors.New("this can not be used in this channel")
+	ErrGuildOnly          = errors.New("this can only be used in a server")
+	ErrDMOnly             = errors.New("this can only be used in direct messages")
+)
+
+// Requirements declares who can run a route and where it can be run. Every
+// field is optional, an empty Requirements allows everyone.
+type Requirements struct {
+	// Permissions is a bitmask of discordgo.Permission* values the user must
+	// all have in the channel. It is also used as the default member
+	// permissions when the route is registered as a Discord command.
+	Permissions int64
+
+	// Roles the user must have at least one of, matched by ID or name.
+	Roles []string
+
+	// Channels the route may be used in, matched by ID or name.
+	Channels []string
+
+	GuildOnly bool
+	DMOnly    bool
+}
+
+// RouteOption configures a Route when it is passed to NewRoute. A Route is
+// itself a RouteOption which adds it as a subroute.
+type RouteOption interface {
+	applyRoute(r *Route)
+}
+
+type requirementOption Requirements
+
+func (o requirementOption) applyRoute(r *Route) {
+	r.Requirements = append(r.Requirements, Requirements(o))
+}
+
+func (sr Route) applyRoute(r *Route) {
+	r.Subroutes = append(r.Subroutes, sr)
+}
+
+// Require restricts the route, and all of its subroutes, to the given
+// requirements.
+func Require(req Requirements) RouteOption {
+	return requirementOption(req)
+}
+
+// RequirePermissions restricts the route to users with all of the given
+// Discord permissions.
+func RequirePermissions(permissions int64) RouteOption {
+	return requirementOption{Permissions: permissions}
+}
+
+// RequireRoles restricts the route to users with at least one of the given
+// roles, matched by ID or name.
+func RequireRoles(roles ...string) RouteOption {
+	return requirementOption{Roles: roles}
+}
+
+// RequireChannels restricts the route to the given channels, matched by ID or
+// name.
+func RequireChannels(channels ...string) RouteOption {
+	return requirementOption{Channels: channels}
+}
+
+// RequireGuild restricts the route to be used in a server.
+func RequireGuild() RouteOption {
+	return requirementOption{GuildOnly: true}
+}
+
+// RequireDM restricts the route to be used in direct messages.
+func RequireDM() RouteOption {
+	return requirementOption{DMOnly: true}
+}
+
+// Check tests the interaction against the requirements and returns the reason
+// the interaction is denied, or nil if it is allowed.
+func (req Requirements) Check(s Session, i *discordgo.Interaction) error {
+	inGuild := i.GuildID != "" && i.Member != nil
+
+	if req.GuildOnly && !inGuild {
+		return ErrGuildOnly
+	}
+
+	if req.DMOnly && inGuild {
+		return ErrDMOnly
+	}
+
+	if req.Permissions != 0 && (!inGuild || i.Member.Permissions&req.Permissions != req.Permissions) {
+		return ErrMissingPermissions
+	}
+
+	if len(req.Roles) > 0 && (!inGuild || !hasRole(s, i.GuildID, i.Member.Roles, req.Roles)) {
+		return ErrMissingRole
+	}
+
+	if len(req.Channels) > 0 && !inChannel(s, i.ChannelID, req.Channels) {
+		return ErrChannelNotAllowed
+	}
+
+	return nil
+}
+
+// hasRole checks if any of the member roles match the required roles by ID,
+// falling back to the role name from the session state.
+func hasRole(s Session, guildId string, memberRoles, required []string) bool {
+	for _, roleId := range memberRoles {
+		if slices.Contains(required, roleId) {
+			return true
+		}
+
+		// Role names are only known from the gateway state
+		ds, ok := s.(*discordgo.Session)
+		if !ok || ds == nil || ds.State == nil {
+			continue
+		}
+
+		role, err := ds.State.Role(guildId, roleId)
+		if err == nil && slices.Contains(required, role.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// inChannel checks if the channel matches the allowed channels by ID, falling
+// back to the channel name from the session state.
+func inChannel(s Session, channelId string, allowed []string) bool {
+	if slices.Contains(allowed, channelId) {
+		return true
+	}
+
+	// Channel names are only known from the gateway state
+	ds, ok := s.(*discordgo.Session)
+	if !ok || ds == nil || ds.State == nil {
+		return false
+	}
+
+	channel, err := ds.State.Channel(channelId)
+	return err == nil && slices.Contains(allowed, channel.Name)
+}
+
+// checkRequirements tests the interaction against every requirement and
+// returns the first reason for denial.
+func checkRequirements(s Session, i *discordgo.Interaction, reqs []Requirements) error {
+	for _, req := range reqs {
+		if err := req.Check(s, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// permissions combines the permissions of all the requirements so they can be
+// set as the default member permissions of a Discord command.
+func permissions(reqs []Requirements) int64 {
+	var perms int64
+	for _, req := range reqs {
+		perms |= req.Permissions
+	}
+	return perms
+}
+
+// guildOnly checks if any of the requirements restrict the route to servers.
+func guildOnly(reqs []Requirements) bool {
+	for _, req := range reqs {
+		if req.GuildOnly {
+			return true
+		}
+	}
+	return false
+}
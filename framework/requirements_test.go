@@ -0,0 +1,76 @@
+package framework
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+type testApp struct {
+	ApplicationSubCommand
+}
+
+func (a testApp) GetType() AppType {
+	return AppTypeNOP
+}
+
+func TestRequirementsCheck(t *testing.T) {
+	guildInteraction := &discordgo.Interaction{
+		GuildID:   "guild",
+		ChannelID: "channel",
+		Member: &discordgo.Member{
+			User:        &discordgo.User{ID: "user"},
+			Roles:       []string{"role"},
+			Permissions: discordgo.PermissionManageMessages,
+		},
+	}
+
+	dmInteraction := &discordgo.Interaction{
+		ChannelID: "dm",
+		User:      &discordgo.User{ID: "user"},
+	}
+
+	tests := []struct {
+		name        string
+		req         Requirements
+		interaction *discordgo.Interaction
+		expected    error
+	}{
+		{"empty", Requirements{}, guildInteraction, nil},
+		{"guild only in guild", Requirements{GuildOnly: true}, guildInteraction, nil},
+		{"guild only in dm", Requirements{GuildOnly: true}, dmInteraction, ErrGuildOnly},
+		{"dm only in guild", Requirements{DMOnly: true}, guildInteraction, ErrDMOnly},
+		{"has permission", Requirements{Permissions: discordgo.PermissionManageMessages}, guildInteraction, nil},
+		{"missing permission", Requirements{Permissions: discordgo.PermissionAdministrator}, guildInteraction, ErrMissingPermissions},
+		{"permission in dm", Requirements{Permissions: discordgo.PermissionManageMessages}, dmInteraction, ErrMissingPermissions},
+		{"has role", Requirements{Roles: []string{"other", "role"}}, guildInteraction, nil},
+		{"missing role", Requirements{Roles: []string{"other"}}, guildInteraction, ErrMissingRole},
+		{"allowed channel", Requirements{Channels: []string{"channel"}}, guildInteraction, nil},
+		{"wrong channel", Requirements{Channels: []string{"other"}}, guildInteraction, ErrChannelNotAllowed},
+	}
+
+	for _, test := range tests {
+		if err := test.req.Check(nil, test.interaction); err != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestRouteRequirementsInherited(t *testing.T) {
+	bot := &Bot{lg: log.WithField("src", "test")}
+
+	route := NewRoute(bot, "parent", &testApp{},
+		RequireGuild(),
+		NewRoute(bot, "child", &testApp{}, RequireRoles("admin")),
+	)
+
+	if len(route.appRequirements["parent"]) != 1 {
+		t.Errorf("Expected 1 requirement on parent, got %d", len(route.appRequirements["parent"]))
+	}
+
+	child := route.appRequirements["parent.child"]
+	if len(child) != 2 || len(child[0].Roles) != 1 || !child[1].GuildOnly {
+		t.Errorf("Expected child to have its own and the parent requirements, got %v", child)
+	}
+}
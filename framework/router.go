@@ -7,50 +7,60 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
+// isSubCommand reports whether the option is a subcommand or subcommand group
+func isSubCommand(opt *discordgo.ApplicationCommandInteractionDataOption) bool {
+	return opt.Type == discordgo.ApplicationCommandOptionSubCommand ||
+		opt.Type == discordgo.ApplicationCommandOptionSubCommandGroup
+}
+
 func keyBuilder(opt *discordgo.ApplicationCommandInteractionDataOption) string {
 	routeKey := opt.Name
 
-	// Base case: If there are no options of type SubCommand, return the route key
-	if len(opt.Options) == 0 {
-		return routeKey
-	}
-	if opt.Options[0].Type != discordgo.ApplicationCommandOptionSubCommand {
+	// Base case: If there are no subcommands or groups, return the route key
+	if len(opt.Options) == 0 || !isSubCommand(opt.Options[0]) {
 		return routeKey
 	}
 
-	// Recursive case: If there are options of type SubCommand, append the option name to the route key
+	// Recursive case: If there is a subcommand or group, append the option name to the route key
 	return fmt.Sprintf("%s.%s", routeKey, keyBuilder(opt.Options[0]))
 }
 
 func routeBuilder(i *discordgo.InteractionCreate) string {
 	routeKey := i.ApplicationCommandData().Name
 
-	// Base case: If there are no options of type SubCommand, return the route key
-	if len(i.ApplicationCommandData().Options) == 0 {
+	// User and message context menu commands have no subcommands so they are
+	// routed by their name, e.g. "Pay this user"
+	if i.ApplicationCommandData().CommandType == discordgo.UserApplicationCommand ||
+		i.ApplicationCommandData().CommandType == discordgo.MessageApplicationCommand {
 		return routeKey
 	}
 
-	// Recursive case: If there are options of type SubCommand, append the option name to the route key
-	if i.ApplicationCommandData().Options[0].Type != discordgo.ApplicationCommandOptionSubCommand {
+	// Base case: If there are no subcommands or groups, return the route key
+	if len(i.ApplicationCommandData().Options) == 0 || !isSubCommand(i.ApplicationCommandData().Options[0]) {
 		return routeKey
 	}
 
+	// Recursive case: Follow the subcommand groups down to the subcommand,
+	// e.g. "cards.trade.offer"
 	return fmt.Sprintf("%s.%s", routeKey, keyBuilder(i.ApplicationCommandData().Options[0]))
 }
 
-// Route Key is the name of the command dot separated by the subcommands
-// e.g. "remind" or "remind.add". It is slightly different for the
+// Route Key is the name of the command dot separated by the subcommand groups
+// and subcommands e.g. "remind", "remind.add" or "cards.trade.offer", context
+// menu commands use their name. It is slightly different for the
 // message components and modals as they should have the CustomID with
 // the format of "command.subcommand:value" so the route key is the
-// part before the colon.
+// part before the colon. Any component state token is not part of the value.
 func GetRouteKey(i *discordgo.InteractionCreate) (routeKey, eventValue string, err error) {
 	switch i.Type {
-	case discordgo.InteractionApplicationCommand:
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
 		routeKey = routeBuilder(i)
 	case discordgo.InteractionMessageComponent:
-		routeKey, eventValue, _ = strings.Cut(i.MessageComponentData().CustomID, ":")
+		customId, _, _ := splitStateToken(i.MessageComponentData().CustomID)
+		routeKey, eventValue, _ = strings.Cut(customId, ":")
 	case discordgo.InteractionModalSubmit:
-		routeKey, eventValue, _ = strings.Cut(i.ModalSubmitData().CustomID, ":")
+		customId, _, _ := splitStateToken(i.ModalSubmitData().CustomID)
+		routeKey, eventValue, _ = strings.Cut(customId, ":")
 	default:
 		return "", "", fmt.Errorf("interaction type %s not supported", i.Type.String())
 	}
@@ -0,0 +1,93 @@
+package framework
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestGetRouteKey(t *testing.T) {
+	subcommand := &discordgo.ApplicationCommandInteractionData{
+		Name: "remind",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{
+				Name: "del",
+				Type: discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{Name: "id", Type: discordgo.ApplicationCommandOptionInteger, Focused: true},
+				},
+			},
+		},
+	}
+
+	group := &discordgo.ApplicationCommandInteractionData{
+		Name: "cards",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{
+				Name: "trade",
+				Type: discordgo.ApplicationCommandOptionSubCommandGroup,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Name: "offer",
+						Type: discordgo.ApplicationCommandOptionSubCommand,
+						Options: []*discordgo.ApplicationCommandInteractionDataOption{
+							{Name: "card", Type: discordgo.ApplicationCommandOptionString, Value: "snail"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		interaction *discordgo.Interaction
+		routeKey    string
+		eventValue  string
+	}{
+		{
+			name:        "command",
+			interaction: &discordgo.Interaction{Type: discordgo.InteractionApplicationCommand, Data: *subcommand},
+			routeKey:    "remind.del",
+		},
+		{
+			name:        "autocomplete",
+			interaction: &discordgo.Interaction{Type: discordgo.InteractionApplicationCommandAutocomplete, Data: *subcommand},
+			routeKey:    "remind.del",
+		},
+		{
+			name:        "subcommand group",
+			interaction: &discordgo.Interaction{Type: discordgo.InteractionApplicationCommand, Data: *group},
+			routeKey:    "cards.trade.offer",
+		},
+		{
+			name: "user command",
+			interaction: &discordgo.Interaction{
+				Type: discordgo.InteractionApplicationCommand,
+				Data: discordgo.ApplicationCommandInteractionData{Name: "Pay this user", CommandType: discordgo.UserApplicationCommand, TargetID: "user"},
+			},
+			routeKey: "Pay this user",
+		},
+		{
+			name: "message component",
+			interaction: &discordgo.Interaction{
+				Type: discordgo.InteractionMessageComponent,
+				Data: discordgo.MessageComponentInteractionData{CustomID: "snailrace.host:join_select:race"},
+			},
+			routeKey:   "snailrace.host",
+			eventValue: "join_select:race",
+		},
+	}
+
+	for _, test := range tests {
+		routeKey, eventValue, err := GetRouteKey(&discordgo.InteractionCreate{Interaction: test.interaction})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if routeKey != test.routeKey || eventValue != test.eventValue {
+			t.Errorf("%s: expected %s/%s, got %s/%s", test.name, test.routeKey, test.eventValue, routeKey, eventValue)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// schedulerPollInterval is how often the scheduler looks for due jobs
+	schedulerPollInterval = time.Second
+
+	// schedulerBatchSize is the most jobs run at once
+	schedulerBatchSize = 20
+
+	// DefaultJobLease is how long a job can run for before it is considered
+	// lost and run again
+	DefaultJobLease = time.Minute
+
+	// DefaultJobAttempts is how many times a job is tried before it fails
+	DefaultJobAttempts = 5
+
+	// jobRetryBackoff is the wait before the first retry, doubled for each
+	// retry after it up to jobMaxBackoff
+	jobRetryBackoff = 10 * time.Second
+	jobMaxBackoff   = time.Hour
+)
+
+var (
+	ErrInvalidJob = errors.New("a job needs a name and one of At, Every or Cron")
+	ErrNoJob      = errors.New("there is no job with that key")
+)
+
+// JobHandler runs a scheduled job. Returning an error retries the job with a
+// backoff. Jobs run at least once, so a job which was interrupted by a restart
+// is run again and handlers should be safe to run more than once.
+type JobHandler func(ctx JobContext) error
+
+type JobContext interface {
+	Context() context.Context
+	Session() Session
+	GuildID() string
+	Database() *gorm.DB
+	Logger() *log.Entry
+	Publish(event any)
+	Payload(dst any) error
+}
+
+// Job describes work to schedule. One of At, Every or Cron is needed.
+type Job struct {
+	// Name is the handler which runs the job
+	Name string
+
+	// Key is optional, scheduling a job with the key of an existing job
+	// replaces it, and it can be removed with Unschedule
+	Key string
+
+	GuildID string
+	Payload any
+
+	// At is when a one off job runs, or the first run of a job with Every
+	At time.Time
+
+	// Every runs the job on an interval
+	Every time.Duration
+
+	// Cron runs the job on a cron expression, see parseCron
+	Cron string
+}
+
+// ScheduledJob is a job stored in the database.
+type ScheduledJob struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	Name    string
+	Key     string `gorm:"index"`
+	GuildID string
+	Payload []byte
+
+	Every time.Duration
+	Cron  string
+
+	NextRun     time.Time `gorm:"index"`
+	LockedUntil time.Time
+	Attempts    int
+	LastError   string
+	Failed      bool
+}
+
+// recurring reports whether the job runs more than once
+func (j ScheduledJob) recurring() bool {
+	return j.Every > 0 || j.Cron != ""
+}
+
+// Scheduler runs jobs stored in the database at their time. Jobs missed while
+// the bot was stopped run once when it starts again.
+type Scheduler struct {
+	db       *gorm.DB
+	handlers map[string]JobHandler
+
+	// newContext creates the context a job runs with
+	newContext func(job ScheduledJob) *Context
+
+	now         func() time.Time
+	lease       time.Duration
+	maxAttempts int
+
+	mu   sync.RWMutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newScheduler(db *gorm.DB, newContext func(job ScheduledJob) *Context) *Scheduler {
+	return &Scheduler{
+		db:          db,
+		handlers:    make(map[string]JobHandler),
+		newContext:  newContext,
+		now:         time.Now,
+		lease:       DefaultJobLease,
+		maxAttempts: DefaultJobAttempts,
+	}
+}
+
+// Handle sets the handler which runs the jobs with the name
+func (s *Scheduler) Handle(name string, handler JobHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[name] = handler
+}
+
+// Schedule stores the job and returns its ID. A job with the key of an
+// existing job replaces it, keeping its next run if the schedule is the same.
+func (s *Scheduler) Schedule(job Job) (uint, error) {
+	if job.Name == "" || (job.At.IsZero() && job.Every <= 0 && job.Cron == "") {
+		return 0, ErrInvalidJob
+	}
+
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return 0, err
+	}
+
+	scheduled := ScheduledJob{
+		Name:    job.Name,
+		Key:     job.Key,
+		GuildID: job.GuildID,
+		Payload: payload,
+		Every:   job.Every,
+		Cron:    job.Cron,
+	}
+
+	// Work out the first run
+	now := s.now()
+	switch {
+	case !job.At.IsZero():
+		scheduled.NextRun = job.At
+	case job.Every > 0:
+		scheduled.NextRun = now.Add(job.Every)
+	}
+	if job.Cron != "" {
+		cron, err := parseCron(job.Cron)
+		if err != nil {
+			return 0, err
+		}
+		if scheduled.NextRun.IsZero() {
+			scheduled.NextRun = cron.next(now)
+		}
+	}
+	scheduled.NextRun = scheduled.NextRun.UTC()
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if job.Key == "" {
+			return tx.Create(&scheduled).Error
+		}
+
+		var existing []ScheduledJob
+		if err := tx.Where(ScheduledJob{Key: job.Key}).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			return tx.Create(&scheduled).Error
+		}
+
+		// Keep when a recurring job is next due so missed runs still catch up
+		scheduled.ID = existing[0].ID
+		scheduled.CreatedAt = existing[0].CreatedAt
+		scheduled.LockedUntil = existing[0].LockedUntil
+		if existing[0].Every == job.Every && existing[0].Cron == job.Cron && job.At.IsZero() && scheduled.recurring() {
+			scheduled.NextRun = existing[0].NextRun
+		}
+		return tx.Save(&scheduled).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return scheduled.ID, nil
+}
+
+// Unschedule removes the job with the key. A job which is already running
+// finishes but does not run again.
+func (s *Scheduler) Unschedule(key string) error {
+	result := s.db.Where(ScheduledJob{Key: key}).Delete(&ScheduledJob{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoJob
+	}
+	return nil
+}
+
+// start runs due jobs in the background until stopped
+func (s *Scheduler) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func(stop, done chan struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(schedulerPollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.runDue()
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}(s.stop, s.done)
+}
+
+// close stops the scheduler and waits for the running jobs to finish
+func (s *Scheduler) close() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// flush stops the scheduler, then runs the jobs which are due one last time
+func (s *Scheduler) flush() {
+	s.close()
+	s.runDue()
+}
+
+// runDue runs the jobs which are due and waits for them to finish
+func (s *Scheduler) runDue() {
+	now := s.now().UTC()
+
+	var due []ScheduledJob
+	err := s.db.Where("next_run <= ? AND locked_until < ? AND failed = ?", now, now, false).
+		Order("next_run").Limit(schedulerBatchSize).Find(&due).Error
+	if err != nil {
+		log.WithField("src", "scheduler").WithError(err).Error("Failed to find due jobs")
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range due {
+		if !s.claim(job, now) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(job ScheduledJob) {
+			defer wg.Done()
+			s.run(job)
+		}(job)
+	}
+	wg.Wait()
+}
+
+// claim locks the job for the lease so it is not run twice. A job which is
+// still locked after its lease has run out is claimed again.
+func (s *Scheduler) claim(job ScheduledJob, now time.Time) bool {
+	result := s.db.Model(&ScheduledJob{}).
+		Where("id = ? AND locked_until < ?", job.ID, now).
+		Update("locked_until", now.Add(s.lease))
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// run runs the job's handler and records the result
+func (s *Scheduler) run(job ScheduledJob) {
+	ctx, cancel := s.newContext(job).withTimeout(s.lease)
+	defer cancel()
+
+	s.mu.RLock()
+	handler, ok := s.handlers[job.Name]
+	s.mu.RUnlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler for job %s", job.Name)
+	} else {
+		err = runJobHandler(ctx, handler)
+	}
+
+	if err != nil {
+		s.retry(ctx, job, err)
+		return
+	}
+
+	ctx.Logger().Debug("Job finished")
+	if !job.recurring() {
+		s.db.Delete(&job)
+		return
+	}
+
+	s.db.Model(&job).Updates(map[string]any{
+		"next_run":     s.nextRun(job).UTC(),
+		"locked_until": time.Time{},
+		"attempts":     0,
+		"last_error":   "",
+	})
+}
+
+// retry schedules the job to run again after a backoff. Once it has been
+// tried too many times a one off job fails and a recurring job waits for its
+// next run.
+func (s *Scheduler) retry(ctx *Context, job ScheduledJob, err error) {
+	attempts := job.Attempts + 1
+	ctx.Logger().WithError(err).WithField("attempt", attempts).Warn("Job failed")
+
+	updates := map[string]any{
+		"locked_until": time.Time{},
+		"attempts":     attempts,
+		"last_error":   err.Error(),
+	}
+
+	switch {
+	case attempts < s.maxAttempts:
+		backoff := jobRetryBackoff << (attempts - 1)
+		if backoff > jobMaxBackoff || backoff <= 0 {
+			backoff = jobMaxBackoff
+		}
+		updates["next_run"] = s.now().Add(backoff).UTC()
+	case job.recurring():
+		ctx.Logger().Error("Job failed too many times, waiting for the next run")
+		updates["next_run"] = s.nextRun(job).UTC()
+		updates["attempts"] = 0
+	default:
+		ctx.Logger().Error("Job failed too many times")
+		updates["failed"] = true
+	}
+
+	s.db.Model(&job).Updates(updates)
+}
+
+// nextRun returns when a recurring job runs after now. Runs which were missed
+// are skipped, so a job that was due many times while the bot was stopped
+// only runs once.
+func (s *Scheduler) nextRun(job ScheduledJob) time.Time {
+	now := s.now()
+
+	if job.Cron != "" {
+		cron, err := parseCron(job.Cron)
+		if err != nil {
+			return time.Time{}
+		}
+		return cron.next(now)
+	}
+
+	next := job.NextRun
+	if !next.After(now) {
+		missed := now.Sub(next)/job.Every + 1
+		next = next.Add(missed * job.Every)
+	}
+	return next
+}
+
+// pendingJobs counts the jobs waiting to run by their name
+func (s *Scheduler) pendingJobs() []MetricSample {
+	var counts []struct {
+		Name  string
+		Count int64
+	}
+	err := s.db.Model(&ScheduledJob{}).Select("name, count(*) as count").
+		Where("failed = ?", false).Group("name").Scan(&counts).Error
+	if err != nil {
+		log.WithField("src", "scheduler").WithError(err).Error("Failed to count pending jobs")
+		return nil
+	}
+
+	samples := make([]MetricSample, 0, len(counts))
+	for _, c := range counts {
+		samples = append(samples, MetricSample{Labels: []string{c.Name}, Value: float64(c.Count)})
+	}
+	return samples
+}
+
+// runJobHandler runs the handler, returning a panic as an error
+func runJobHandler(ctx *Context, handler JobHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx)
+}
+
+// Scheduler returns the bot's job scheduler, which runs once the routes are
+// mounted
+func (b *Bot) Scheduler() *Scheduler {
+	b.schedulerOnce.Do(func() {
+		b.scheduler = newScheduler(b.db, func(job ScheduledJob) *Context {
+			return NewContext(
+				withSession(b.session()),
+				withDatabase(b.db),
+				withPublisher(b.Publish),
+				withScheduler(b.scheduler),
+				withGuildId(job.GuildID),
+				withJobPayload(job.Payload),
+				withLogger(b.lg.WithFields(log.Fields{
+					"guild":   job.GuildID,
+					"job":     job.Name,
+					"job_id":  job.ID,
+					"attempt": job.Attempts + 1,
+					"type":    "job",
+				})),
+			)
+		})
+	})
+	return b.scheduler
+}
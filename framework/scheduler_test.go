@@ -0,0 +1,253 @@
+package framework
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"gorm.io/gorm"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type greeting struct {
+	ChannelId string
+	Name      string
+}
+
+func newTestScheduler(t *testing.T, now *time.Time) (*Scheduler, *gorm.DB) {
+	bot := &Bot{db: newSchedulerDB(t), lg: log.WithField("src", "test"), mounted: NewRecordingSession()}
+	scheduler := bot.Scheduler()
+	scheduler.now = func() time.Time { return *now }
+	return scheduler, bot.db
+}
+
+// newSchedulerDB creates a database with the scheduled jobs table
+func newSchedulerDB(t *testing.T) *gorm.DB {
+	return dbtest.Open(t, Migrations)
+}
+
+func getJob(db *gorm.DB, id uint) (ScheduledJob, bool) {
+	var jobs []ScheduledJob
+	db.Where("id = ?", id).Find(&jobs)
+	if len(jobs) == 0 {
+		return ScheduledJob{}, false
+	}
+	return jobs[0], true
+}
+
+func TestSchedulerOneOffJob(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	scheduler, db := newTestScheduler(t, &now)
+
+	var greeted []string
+	scheduler.Handle("greet", func(ctx JobContext) error {
+		var g greeting
+		if err := ctx.Payload(&g); err != nil {
+			return err
+		}
+		greeted = append(greeted, ctx.GuildID()+" "+g.Name)
+		_, err := ctx.Session().ChannelMessageSend(g.ChannelId, "Hello "+g.Name)
+		return err
+	})
+
+	if _, err := scheduler.Schedule(Job{Name: "greet"}); err != ErrInvalidJob {
+		t.Errorf("Expected ErrInvalidJob, got %v", err)
+	}
+
+	id, err := scheduler.Schedule(Job{
+		Name:    "greet",
+		GuildID: "guild",
+		Payload: greeting{ChannelId: "channel", Name: "alice"},
+		At:      now.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("Failed to schedule job: %v", err)
+	}
+
+	// Not due yet
+	scheduler.runDue()
+	if len(greeted) != 0 {
+		t.Fatalf("Expected the job to wait until it is due, got %v", greeted)
+	}
+
+	now = now.Add(time.Minute)
+	scheduler.runDue()
+	if len(greeted) != 1 || greeted[0] != "guild alice" {
+		t.Fatalf("Expected the job to run once, got %v", greeted)
+	}
+	if _, ok := getJob(db, id); ok {
+		t.Errorf("Expected a one off job to be removed once it has run")
+	}
+
+	scheduler.runDue()
+	if len(greeted) != 1 {
+		t.Errorf("Expected the job to only run once, got %v", greeted)
+	}
+}
+
+func TestSchedulerRetry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	scheduler, db := newTestScheduler(t, &now)
+	scheduler.maxAttempts = 3
+
+	runs := 0
+	scheduler.Handle("flaky", func(ctx JobContext) error {
+		runs++
+		if runs == 2 {
+			panic("job failed")
+		}
+		return errors.New("job failed")
+	})
+
+	id, _ := scheduler.Schedule(Job{Name: "flaky", At: now})
+
+	// Each failure waits twice as long as the one before
+	for attempt, backoff := range []time.Duration{jobRetryBackoff, 2 * jobRetryBackoff} {
+		scheduler.runDue()
+
+		job, _ := getJob(db, id)
+		if job.Attempts != attempt+1 || job.LastError == "" || job.Failed {
+			t.Fatalf("Expected attempt %d to be recorded, got %+v", attempt+1, job)
+		}
+		if !job.NextRun.Equal(now.Add(backoff)) {
+			t.Fatalf("Expected a retry after %s, got %s", backoff, job.NextRun.Sub(now))
+		}
+
+		scheduler.runDue()
+		if runs != attempt+1 {
+			t.Fatalf("Expected the job to wait for its backoff, ran %d times", runs)
+		}
+		now = now.Add(backoff)
+	}
+
+	// The last attempt fails the job
+	scheduler.runDue()
+	job, _ := getJob(db, id)
+	if runs != 3 || !job.Failed {
+		t.Fatalf("Expected the job to fail after 3 attempts, ran %d times: %+v", runs, job)
+	}
+
+	now = now.Add(jobMaxBackoff)
+	scheduler.runDue()
+	if runs != 3 {
+		t.Errorf("Expected a failed job not to run again, ran %d times", runs)
+	}
+}
+
+func TestSchedulerRecurringJob(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	scheduler, db := newTestScheduler(t, &now)
+
+	runs := 0
+	scheduler.Handle("tick", func(ctx JobContext) error {
+		runs++
+		return nil
+	})
+
+	id, _ := scheduler.Schedule(Job{Name: "tick", Key: "tick", Every: time.Hour})
+
+	now = now.Add(time.Hour)
+	scheduler.runDue()
+	job, _ := getJob(db, id)
+	if runs != 1 || !job.NextRun.Equal(now.Add(time.Hour)) {
+		t.Fatalf("Expected the job to run and be due in an hour, ran %d times, next run %s", runs, job.NextRun)
+	}
+
+	// Scheduling the same job again on startup keeps when it is due
+	now = now.Add(5*time.Hour + 30*time.Minute)
+	if again, _ := scheduler.Schedule(Job{Name: "tick", Key: "tick", Every: time.Hour}); again != id {
+		t.Fatalf("Expected the job to be replaced, got a new job %d", again)
+	}
+
+	// Missed runs only run once
+	scheduler.runDue()
+	scheduler.runDue()
+	job, _ = getJob(db, id)
+	if runs != 2 {
+		t.Errorf("Expected missed runs to run once, ran %d times", runs)
+	}
+	if !job.NextRun.Equal(now.Add(30 * time.Minute)) {
+		t.Errorf("Expected the next run to stay on the hour, got %s", job.NextRun)
+	}
+
+	if err := scheduler.Unschedule("tick"); err != nil {
+		t.Fatalf("Failed to unschedule job: %v", err)
+	}
+	if err := scheduler.Unschedule("tick"); err != ErrNoJob {
+		t.Errorf("Expected ErrNoJob, got %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	scheduler.runDue()
+	if runs != 2 {
+		t.Errorf("Expected an unscheduled job not to run, ran %d times", runs)
+	}
+}
+
+func TestSchedulerCronJob(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	scheduler, db := newTestScheduler(t, &now)
+
+	if _, err := scheduler.Schedule(Job{Name: "report", Cron: "61 * * * *"}); !errors.Is(err, ErrInvalidCron) {
+		t.Errorf("Expected ErrInvalidCron, got %v", err)
+	}
+
+	scheduler.Handle("report", func(ctx JobContext) error { return nil })
+	id, err := scheduler.Schedule(Job{Name: "report", Cron: "30 9 * * *"})
+	if err != nil {
+		t.Fatalf("Failed to schedule job: %v", err)
+	}
+
+	job, _ := getJob(db, id)
+	if want := time.Date(2024, 5, 2, 9, 30, 0, 0, time.UTC); !job.NextRun.Equal(want) {
+		t.Fatalf("Expected the job to be due at %s, got %s", want, job.NextRun)
+	}
+
+	now = time.Date(2024, 5, 2, 9, 30, 0, 0, time.UTC)
+	scheduler.runDue()
+	job, _ = getJob(db, id)
+	if want := time.Date(2024, 5, 3, 9, 30, 0, 0, time.UTC); !job.NextRun.Equal(want) {
+		t.Errorf("Expected the job to be due at %s, got %s", want, job.NextRun)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Wednesday the 1st of May 2024
+	from := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 5, 1, 12, 1, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 5, 1, 12, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		cron, err := parseCron(test.expr)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", test.expr, err)
+			continue
+		}
+		if got := cron.next(from); !got.Equal(test.want) {
+			t.Errorf("Expected %q to be next at %s, got %s", test.expr, test.want, got)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseCron(expr); !errors.Is(err, ErrInvalidCron) {
+			t.Errorf("Expected %q to be invalid, got %v", expr, err)
+		}
+	}
+}
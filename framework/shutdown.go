@@ -0,0 +1,68 @@
+package framework
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultShutdownTimeout is how long the apps have to finish their work when
+// the bot shuts down
+const DefaultShutdownTimeout = 2 * time.Minute
+
+// Shutdown stops the bot gracefully. The shutdown apps are run together and
+// have until the timeout to finish their work, such as games in progress. The
+// scheduled jobs which are due are then run before the bot is closed, and the
+// rest are kept in the database for when the bot starts again.
+func (b *Bot) Shutdown(timeout time.Duration) error {
+	b.lg.WithField("timeout", timeout.String()).Info("Shutting down")
+
+	deadline := time.Now().Add(timeout)
+
+	var wg sync.WaitGroup
+	b.shutdown(b.Routes, deadline, &wg)
+	wg.Wait()
+
+	// Send what is due, such as reminders, before the gateway is closed
+	if b.scheduler != nil && b.db != nil {
+		b.scheduler.flush()
+	}
+
+	return b.Close()
+}
+
+// shutdown runs the OnShutdown function of the routes and their subroutes,
+// each in its own goroutine with a context which ends at the deadline
+func (b *Bot) shutdown(routes []Route, deadline time.Time, wg *sync.WaitGroup) {
+	for _, route := range routes {
+		b.shutdown(route.Subroutes, deadline, wg)
+
+		if route.App.GetType()&AppTypeShutdown == 0 {
+			continue
+		}
+
+		ctx, cancel := NewContext(
+			withSession(b.session()),
+			withDatabase(b.db),
+			withPublisher(b.Publish),
+			withLogger(b.lg.WithFields(log.Fields{
+				"route": route.Name,
+				"type":  "shutdown",
+			})),
+		).withTimeout(time.Until(deadline))
+
+		wg.Add(1)
+		go func(app ApplicationShutdown) {
+			defer wg.Done()
+			defer cancel()
+			defer func() {
+				if r := recover(); r != nil {
+					ctx.Logger().Errorf("Recovered from panic during shutdown: %v", r)
+				}
+			}()
+
+			app.OnShutdown(ctx)
+		}(route.App.(ApplicationShutdown))
+	}
+}
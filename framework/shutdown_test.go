@@ -0,0 +1,87 @@
+package framework
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tableApp waits for its game to finish when shutting down, or gives up at
+// the deadline
+type tableApp struct {
+	finished chan struct{}
+
+	mu      sync.Mutex
+	results []string
+}
+
+func (a *tableApp) GetType() AppType {
+	return AppTypeShutdown
+}
+
+func (a *tableApp) OnShutdown(ctx ShutdownContext) {
+	result := "finished"
+	select {
+	case <-a.finished:
+	case <-ctx.Context().Done():
+		result = "refunded"
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.results = append(a.results, result)
+}
+
+func TestShutdown(t *testing.T) {
+	db := newSchedulerDB(t)
+	discord, _ := discordgo.New("Bot token")
+	session := NewRecordingSession()
+	bot := &Bot{Discord: discord, db: db, lg: log.WithField("src", "test"), mounted: session}
+
+	now := time.Now()
+	scheduler := bot.Scheduler()
+
+	finished := &tableApp{finished: make(chan struct{})}
+	close(finished.finished)
+	stuck := &tableApp{finished: make(chan struct{})}
+
+	bot.Register(
+		NewRoute(bot, "finished", finished),
+		NewRoute(bot, "games", &greetApp{}, NewRoute(bot, "stuck", stuck)),
+	)
+
+	// A reminder which is due is sent before closing, one which is not is kept
+	scheduler.Handle("remind", func(ctx JobContext) error {
+		_, err := ctx.Session().ChannelMessageSend("channel", "Reminder")
+		return err
+	})
+	scheduler.Schedule(Job{Name: "remind", At: now})
+	later, _ := scheduler.Schedule(Job{Name: "remind", At: now.Add(time.Hour)})
+
+	start := time.Now()
+	if err := bot.Shutdown(50 * time.Millisecond); err != nil {
+		t.Fatalf("Failed to shut down: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected shutdown to stop waiting at the timeout, took %s", elapsed)
+	}
+
+	if len(finished.results) != 1 || finished.results[0] != "finished" {
+		t.Errorf("Expected the finished game to be waited for, got %v", finished.results)
+	}
+	if len(stuck.results) != 1 || stuck.results[0] != "refunded" {
+		t.Errorf("Expected the subroute's game to be stopped at the deadline, got %v", stuck.results)
+	}
+
+	if message := session.LastMessage(); message == nil || message.Content != "Reminder" {
+		t.Errorf("Expected the due reminder to be sent, got %+v", message)
+	}
+	if _, ok := getJob(db, later); !ok {
+		t.Errorf("Expected the later reminder to be kept")
+	}
+}
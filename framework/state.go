@@ -0,0 +1,298 @@
+package framework
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm"
+)
+
+// Component state lets handlers keep a payload for a message component or
+// modal on the server, instead of in its CustomID. The CustomID gets a short
+// token, "<customId>:~<id>.<signature>", signed with HMAC so it can not be
+// forged or moved to another component.
+
+const (
+	// stateTokenPrefix marks the start of the state token in a CustomID
+	stateTokenPrefix = "~"
+
+	// customIdLimit is the longest CustomID Discord accepts
+	customIdLimit = 100
+
+	// statePruneInterval is how often expired state is removed from the store
+	statePruneInterval = time.Minute
+)
+
+var (
+	ErrComponentStateExpired = errors.New("this has expired, please try again")
+	ErrComponentStateInvalid = errors.New("this is not valid")
+	ErrNoComponentState      = errors.New("there is no state for this interaction")
+	ErrCustomIdTooLong       = errors.New("the custom ID is too long")
+)
+
+// ComponentState is a payload stored for a component.
+type ComponentState struct {
+	ID      string `gorm:"primarykey"`
+	Payload []byte
+	Expires time.Time `gorm:"index"`
+}
+
+// ComponentStateStore keeps the component state between interactions.
+type ComponentStateStore interface {
+	Get(id string) (ComponentState, bool, error)
+	Put(state ComponentState) error
+	Delete(id string) error
+	Prune(now time.Time) error
+}
+
+// MemoryComponentStateStore keeps the component state in memory, it is lost
+// when the bot restarts.
+type MemoryComponentStateStore struct {
+	states map[string]ComponentState
+	mu     sync.Mutex
+}
+
+func NewMemoryComponentStateStore() *MemoryComponentStateStore {
+	return &MemoryComponentStateStore{states: make(map[string]ComponentState)}
+}
+
+func (s *MemoryComponentStateStore) Get(id string) (ComponentState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[id]
+	return state, ok, nil
+}
+
+func (s *MemoryComponentStateStore) Put(state ComponentState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[state.ID] = state
+	return nil
+}
+
+func (s *MemoryComponentStateStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, id)
+	return nil
+}
+
+func (s *MemoryComponentStateStore) Prune(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, state := range s.states {
+		if now.After(state.Expires) {
+			delete(s.states, id)
+		}
+	}
+	return nil
+}
+
+// DatabaseComponentStateStore persists the component state in the database so
+// components keep working after a restart.
+type DatabaseComponentStateStore struct {
+	db *gorm.DB
+}
+
+// NewDatabaseComponentStateStore creates the store, its table is created by
+// Migrations.
+func NewDatabaseComponentStateStore(db *gorm.DB) *DatabaseComponentStateStore {
+	return &DatabaseComponentStateStore{db: db}
+}
+
+func (s *DatabaseComponentStateStore) Get(id string) (ComponentState, bool, error) {
+	var states []ComponentState
+	if err := s.db.Where(ComponentState{ID: id}).Limit(1).Find(&states).Error; err != nil {
+		return ComponentState{}, false, err
+	}
+
+	if len(states) == 0 {
+		return ComponentState{}, false, nil
+	}
+	return states[0], true, nil
+}
+
+func (s *DatabaseComponentStateStore) Put(state ComponentState) error {
+	return s.db.Create(&state).Error
+}
+
+func (s *DatabaseComponentStateStore) Delete(id string) error {
+	return s.db.Delete(&ComponentState{ID: id}).Error
+}
+
+func (s *DatabaseComponentStateStore) Prune(now time.Time) error {
+	return s.db.Where("expires < ?", now).Delete(&ComponentState{}).Error
+}
+
+// ComponentStates signs and stores component state.
+type ComponentStates struct {
+	store  ComponentStateStore
+	secret []byte
+	now    func() time.Time
+
+	mu     sync.Mutex
+	pruned time.Time
+}
+
+// NewComponentStates creates the component state for the store. The secret
+// signs the tokens, it has to stay the same for tokens to work after a
+// restart.
+func NewComponentStates(store ComponentStateStore, secret []byte) *ComponentStates {
+	return &ComponentStates{
+		store:  store,
+		secret: secret,
+		now:    time.Now,
+	}
+}
+
+// newMemoryComponentStates creates component state kept in memory with a
+// random secret
+func newMemoryComponentStates() *ComponentStates {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return NewComponentStates(NewMemoryComponentStateStore(), secret)
+}
+
+// CustomID stores the payload as JSON for the ttl and returns the custom ID
+// with the signed state token appended.
+func (cs *ComponentStates) CustomID(customId string, payload any, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	id := make([]byte, 9)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	encodedId := base64.RawURLEncoding.EncodeToString(id)
+
+	signed := fmt.Sprintf("%s:%s%s.%s", customId, stateTokenPrefix, encodedId, cs.sign(customId, encodedId))
+	if len(signed) > customIdLimit {
+		return "", ErrCustomIdTooLong
+	}
+
+	now := cs.now()
+	cs.prune(now)
+
+	err = cs.store.Put(ComponentState{
+		ID:      encodedId,
+		Payload: data,
+		Expires: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return signed, nil
+}
+
+// Load checks the state token in the custom ID and returns the stored
+// payload. It returns ErrNoComponentState if the custom ID has no token.
+func (cs *ComponentStates) Load(customId string) ([]byte, error) {
+	customId, token, ok := splitStateToken(customId)
+	if !ok {
+		return nil, ErrNoComponentState
+	}
+
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(cs.sign(customId, id))) {
+		return nil, ErrComponentStateInvalid
+	}
+
+	state, ok, err := cs.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrComponentStateExpired
+	}
+
+	if cs.now().After(state.Expires) {
+		cs.store.Delete(id)
+		return nil, ErrComponentStateExpired
+	}
+	return state.Payload, nil
+}
+
+// sign returns the signature of the state ID for the custom ID
+func (cs *ComponentStates) sign(customId, id string) string {
+	mac := hmac.New(sha256.New, cs.secret)
+	mac.Write([]byte(customId + "|" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// prune removes expired state from the store every statePruneInterval
+func (cs *ComponentStates) prune(now time.Time) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if now.Sub(cs.pruned) < statePruneInterval {
+		return
+	}
+	cs.pruned = now
+	cs.store.Prune(now)
+}
+
+// splitStateToken splits the state token from the end of the custom ID
+func splitStateToken(customId string) (string, string, bool) {
+	i := strings.LastIndex(customId, ":"+stateTokenPrefix)
+	if i < 0 {
+		return customId, "", false
+	}
+	return customId[:i], customId[i+1+len(stateTokenPrefix):], true
+}
+
+// UseComponentStates sets where component state is stored. By default it is
+// kept in memory and lost when the bot restarts.
+func (b *Bot) UseComponentStates(states *ComponentStates) {
+	b.states = states
+}
+
+// componentStates returns the bot's component state, creating the default in
+// memory state on first use
+func (b *Bot) componentStates() *ComponentStates {
+	b.statesOnce.Do(func() {
+		if b.states == nil {
+			b.states = newMemoryComponentStates()
+		}
+	})
+	return b.states
+}
+
+// loadComponentState adds the state of a component or modal interaction to
+// the context. Interactions without a state token are left as they are.
+func (b *Bot) loadComponentState(ctx *Context, i *discordgo.Interaction) error {
+	var customId string
+	switch i.Type {
+	case discordgo.InteractionMessageComponent:
+		customId = i.MessageComponentData().CustomID
+	case discordgo.InteractionModalSubmit:
+		customId = i.ModalSubmitData().CustomID
+	default:
+		return nil
+	}
+
+	payload, err := b.componentStates().Load(customId)
+	if errors.Is(err, ErrNoComponentState) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	withComponentState(payload)(ctx)
+	return nil
+}
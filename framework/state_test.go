@@ -0,0 +1,169 @@
+package framework
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"github.com/bwmarrin/discordgo"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type counterState struct {
+	Count int
+}
+
+// counterApp replies with a button which counts how many times it has been
+// pressed, keeping the count in the component state
+type counterApp struct{}
+
+func (a counterApp) GetType() AppType {
+	return AppTypeCommand | AppTypeEvent
+}
+
+func (a counterApp) GetDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{Name: "counter"}
+}
+
+func (a counterApp) OnCommand(ctx CommandContext) {
+	customId, err := ctx.NewCustomID("counter:press", counterState{Count: 1}, time.Minute)
+	if err != nil {
+		ctx.Fail(err)
+		return
+	}
+
+	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: customId},
+	})
+}
+
+func (a counterApp) OnEvent(ctx EventContext, eventType discordgo.InteractionType) {
+	var state counterState
+	if err := ctx.State(&state); err != nil {
+		ctx.Fail(err)
+		return
+	}
+
+	ctx.Session().InteractionRespond(ctx.Interaction(), &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{Content: ctx.EventValue() + " " + strings.Repeat("+", state.Count)},
+	})
+}
+
+func TestComponentStates(t *testing.T) {
+	states := NewComponentStates(NewMemoryComponentStateStore(), []byte("secret"))
+	now := time.Now()
+	states.now = func() time.Time { return now }
+
+	customId, err := states.CustomID("snailrace.host:join_select", counterState{Count: 3}, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create custom ID: %v", err)
+	}
+	if !strings.HasPrefix(customId, "snailrace.host:join_select:~") || len(customId) > customIdLimit {
+		t.Errorf("Unexpected custom ID %q", customId)
+	}
+
+	payload, err := states.Load(customId)
+	if err != nil || string(payload) != `{"Count":3}` {
+		t.Errorf("Expected the payload back, got %s %v", payload, err)
+	}
+
+	// The token can't be changed or moved to another component
+	_, token, _ := splitStateToken(customId)
+	tampered := []string{
+		customId[:len(customId)-1] + "A",
+		"snailrace.host:join:~" + token,
+		"snailrace.host:join_select:~" + strings.Split(token, ".")[0],
+	}
+	for _, id := range tampered {
+		if _, err := states.Load(id); err != ErrComponentStateInvalid {
+			t.Errorf("%s: expected ErrComponentStateInvalid, got %v", id, err)
+		}
+	}
+
+	// Other secrets can't read the token
+	other := NewComponentStates(states.store, []byte("other"))
+	if _, err := other.Load(customId); err != ErrComponentStateInvalid {
+		t.Errorf("Expected ErrComponentStateInvalid with another secret, got %v", err)
+	}
+
+	if _, err := states.Load("snailrace.host:join_select:race"); err != ErrNoComponentState {
+		t.Errorf("Expected ErrNoComponentState, got %v", err)
+	}
+
+	if _, err := states.CustomID(strings.Repeat("a", 80), nil, time.Minute); err != ErrCustomIdTooLong {
+		t.Errorf("Expected ErrCustomIdTooLong, got %v", err)
+	}
+
+	// Expired state is rejected and removed
+	now = now.Add(2 * time.Minute)
+	if _, err := states.Load(customId); err != ErrComponentStateExpired {
+		t.Errorf("Expected ErrComponentStateExpired, got %v", err)
+	}
+	if _, err := states.Load(customId); err != ErrComponentStateExpired {
+		t.Errorf("Expected ErrComponentStateExpired once removed, got %v", err)
+	}
+}
+
+func TestDatabaseComponentStateStore(t *testing.T) {
+	store := NewDatabaseComponentStateStore(dbtest.Open(t, Migrations))
+
+	now := time.Now()
+	store.Put(ComponentState{ID: "old", Payload: []byte("{}"), Expires: now.Add(-time.Minute)})
+	store.Put(ComponentState{ID: "new", Payload: []byte(`{"Count":1}`), Expires: now.Add(time.Minute)})
+
+	if err := store.Prune(now); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+
+	if _, ok, _ := store.Get("old"); ok {
+		t.Errorf("Expected expired state to be pruned")
+	}
+	if state, ok, _ := store.Get("new"); !ok || string(state.Payload) != `{"Count":1}` {
+		t.Errorf("Expected state to be kept, got %+v", state)
+	}
+}
+
+func TestComponentStateInteraction(t *testing.T) {
+	bot := &Bot{lg: log.WithField("src", "test")}
+	bot.Use(ErrorReply())
+	bot.Register(NewRoute(bot, "counter", counterApp{}))
+
+	user := &discordgo.Member{User: &discordgo.User{ID: "user", Username: "user"}}
+	session := NewRecordingSession()
+	bot.HandleInteraction(session, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:     "command",
+		Type:   discordgo.InteractionApplicationCommand,
+		Member: user,
+		Data:   discordgo.ApplicationCommandInteractionData{Name: "counter"},
+	}})
+	customId := session.LastResponse().Data.Content
+
+	press := func(customId string) string {
+		session := NewRecordingSession()
+		bot.HandleInteraction(session, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			ID:     "press",
+			Type:   discordgo.InteractionMessageComponent,
+			Member: user,
+			Data:   discordgo.MessageComponentInteractionData{CustomID: customId},
+		}})
+		return session.LastResponse().Data.Content
+	}
+
+	// The token is not part of the event value
+	if content := press(customId); content != "press +" {
+		t.Errorf("Expected the state to be handed to the event, got %q", content)
+	}
+
+	if content := press(customId + "x"); content != "**Error:** "+ErrComponentStateInvalid.Error() {
+		t.Errorf("Expected a forged token to be rejected, got %q", content)
+	}
+
+	bot.componentStates().now = func() time.Time { return time.Now().Add(time.Hour) }
+	if content := press(customId); content != "**Error:** "+ErrComponentStateExpired.Error() {
+		t.Errorf("Expected an expired token to be rejected, got %q", content)
+	}
+}
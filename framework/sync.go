@@ -0,0 +1,293 @@
+package framework
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type CommandChangeAction string
+
+const (
+	CommandCreate CommandChangeAction = "create"
+	CommandUpdate CommandChangeAction = "update"
+	CommandDelete CommandChangeAction = "delete"
+)
+
+// CommandChange is a single change needed to make the commands registered with
+// Discord match the routes.
+type CommandChange struct {
+	Action  CommandChangeAction
+	GuildID string
+
+	// Command is the definition from the route, nil when deleting
+	Command *discordgo.ApplicationCommand
+
+	// Existing is the command registered with Discord, nil when creating
+	Existing *discordgo.ApplicationCommand
+}
+
+func (c CommandChange) String() string {
+	command := commandOf(c)
+	symbol := map[CommandChangeAction]string{
+		CommandCreate: "+",
+		CommandUpdate: "~",
+		CommandDelete: "-",
+	}[c.Action]
+
+	switch commandType(command) {
+	case discordgo.UserApplicationCommand:
+		return fmt.Sprintf("%s %s user command %q", symbol, c.Action, command.Name)
+	case discordgo.MessageApplicationCommand:
+		return fmt.Sprintf("%s %s message command %q", symbol, c.Action, command.Name)
+	}
+	return fmt.Sprintf("%s %s /%s", symbol, c.Action, command.Name)
+}
+
+// PlanCommandSync works out the creates, updates and deletes needed to turn
+// the existing commands into the desired commands. Commands are matched by
+// name and type, so a slash command and a context menu command can share a
+// name.
+func PlanCommandSync(existing, desired []*discordgo.ApplicationCommand) []CommandChange {
+	changes := make([]CommandChange, 0)
+
+	registered := make(map[string]*discordgo.ApplicationCommand)
+	for _, command := range existing {
+		registered[commandKey(command)] = command
+	}
+
+	for _, command := range desired {
+		key := commandKey(command)
+		current, ok := registered[key]
+		delete(registered, key)
+
+		if !ok {
+			changes = append(changes, CommandChange{Action: CommandCreate, Command: command})
+		} else if !commandsEqual(current, command) {
+			changes = append(changes, CommandChange{Action: CommandUpdate, Command: command, Existing: current})
+		}
+	}
+
+	// Anything left over is no longer a route, keep the order Discord gave
+	for _, command := range existing {
+		if _, ok := registered[commandKey(command)]; ok {
+			changes = append(changes, CommandChange{Action: CommandDelete, Existing: command})
+		}
+	}
+
+	return changes
+}
+
+func commandType(c *discordgo.ApplicationCommand) discordgo.ApplicationCommandType {
+	if c.Type == 0 {
+		return discordgo.ChatApplicationCommand
+	}
+	return c.Type
+}
+
+func commandKey(c *discordgo.ApplicationCommand) string {
+	return fmt.Sprintf("%d:%s", commandType(c), c.Name)
+}
+
+// commandsEqual compares the parts of a command definition that Discord
+// stores, ignoring IDs and versions which are only set on registered commands.
+func commandsEqual(a, b *discordgo.ApplicationCommand) bool {
+	if commandType(a) != commandType(b) || a.Name != b.Name || a.Description != b.Description {
+		return false
+	}
+
+	if !int64PtrEqual(a.DefaultMemberPermissions, b.DefaultMemberPermissions) {
+		return false
+	}
+
+	if boolOr(a.NSFW, false) != boolOr(b.NSFW, false) {
+		return false
+	}
+
+	// Commands can be used in DMs unless they say otherwise, Discord does not
+	// store this for server commands as they are never shown in DMs
+	if a.GuildID == "" && boolOr(a.DMPermission, true) != boolOr(b.DMPermission, true) {
+		return false
+	}
+
+	return optionsEqual(a.Options, b.Options)
+}
+
+func optionsEqual(a, b []*discordgo.ApplicationCommandOption) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		x, y := a[i], b[i]
+
+		if x.Type != y.Type || x.Name != y.Name || x.Description != y.Description ||
+			x.Required != y.Required || x.Autocomplete != y.Autocomplete ||
+			x.MaxValue != y.MaxValue || x.MaxLength != y.MaxLength {
+			return false
+		}
+
+		if !float64PtrEqual(x.MinValue, y.MinValue) || !intPtrEqual(x.MinLength, y.MinLength) {
+			return false
+		}
+
+		if !slices.Equal(x.ChannelTypes, y.ChannelTypes) || !choicesEqual(x.Choices, y.Choices) {
+			return false
+		}
+
+		if !optionsEqual(x.Options, y.Options) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func choicesEqual(a, b []*discordgo.ApplicationCommandOptionChoice) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	// Values are compared as text as Discord returns every number as a float
+	for i := range a {
+		if a[i].Name != b[i].Name || fmt.Sprint(a[i].Value) != fmt.Sprint(b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func boolOr(v *bool, def bool) bool {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+func int64PtrEqual(a, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func intPtrEqual(a, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func float64PtrEqual(a, b *float64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// commandDefinitions returns the command definitions for every command route
+// enabled in the server with the route requirements applied.
+func (b *Bot) commandDefinitions(guildId string) []*discordgo.ApplicationCommand {
+	definitions := make([]*discordgo.ApplicationCommand, 0)
+	disabled := b.disabledApps(guildId)
+
+	for _, route := range b.Routes {
+		if route.App.GetType()&AppTypeCommand == 0 || disabled[route.Name] {
+			continue
+		}
+
+		definition := route.commandDefinition()
+
+		// Hide the command from users without the required permissions
+		if perms := permissions(route.Requirements); perms != 0 {
+			definition.DefaultMemberPermissions = &perms
+		}
+		if guildOnly(route.Requirements) {
+			dmPermission := false
+			definition.DMPermission = &dmPermission
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions
+}
+
+// applicationId returns the ID of the bot application. It is set by the
+// gateway Ready event, otherwise it is fetched with the bot token.
+func (b *Bot) applicationId() (string, error) {
+	if b.Discord.State.User == nil {
+		user, err := b.Discord.User("@me")
+		if err != nil {
+			return "", err
+		}
+		b.Discord.State.User = user
+	}
+
+	return b.Discord.State.User.ID, nil
+}
+
+// SyncCommands syncs the commands of every server the bot serves, see
+// SyncGuildCommands. The changes for all servers are returned.
+func (b *Bot) SyncCommands(dryRun bool) ([]CommandChange, error) {
+	changes := make([]CommandChange, 0)
+
+	for _, guildId := range b.serverIds {
+		guildChanges, err := b.SyncGuildCommands(guildId, dryRun)
+		changes = append(changes, guildChanges...)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	return changes, nil
+}
+
+// SyncGuildCommands fetches the commands registered with Discord for the
+// server and applies only the changes needed to match the routes enabled in
+// it. Commands are left registered when the bot stops, so they keep working
+// across restarts. With dryRun the changes are returned without being
+// applied.
+func (b *Bot) SyncGuildCommands(guildId string, dryRun bool) ([]CommandChange, error) {
+	appId, err := b.applicationId()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := b.Discord.ApplicationCommands(appId, guildId)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := PlanCommandSync(existing, b.commandDefinitions(guildId))
+	for i := range changes {
+		changes[i].GuildID = guildId
+	}
+
+	if dryRun {
+		return changes, nil
+	}
+
+	lg := b.lg.WithField("guild", guildId)
+	if len(changes) == 0 {
+		lg.Info("Discord commands are up to date")
+	}
+
+	for _, change := range changes {
+		switch change.Action {
+		case CommandCreate:
+			_, err = b.Discord.ApplicationCommandCreate(appId, guildId, change.Command)
+		case CommandUpdate:
+			_, err = b.Discord.ApplicationCommandEdit(appId, guildId, change.Existing.ID, change.Command)
+		case CommandDelete:
+			err = b.Discord.ApplicationCommandDelete(appId, guildId, change.Existing.ID)
+		}
+
+		if err != nil {
+			return changes, fmt.Errorf("failed to sync %s: %w", change, err)
+		}
+
+		lg.Infof("Synced command: %s", change)
+	}
+
+	return changes, nil
+}
+
+func commandOf(change CommandChange) *discordgo.ApplicationCommand {
+	if change.Command != nil {
+		return change.Command
+	}
+	return change.Existing
+}
@@ -0,0 +1,106 @@
+package framework
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestPlanCommandSync(t *testing.T) {
+	minValue := 1.0
+	dmPermission := false
+
+	desired := []*discordgo.ApplicationCommand{
+		{
+			Name:        "wallet",
+			Description: "Manage your wallet",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "amount", Description: "Amount", MinValue: &minValue},
+			},
+		},
+		{Name: "ping", Description: "Ping the bot", DMPermission: &dmPermission},
+		{Name: "remind", Description: "Set a reminder"},
+		{Name: "Pay this user", Type: discordgo.UserApplicationCommand},
+	}
+
+	// Registered commands as Discord returns them
+	existing := []*discordgo.ApplicationCommand{
+		{
+			ID:          "1",
+			GuildID:     "guild",
+			Version:     "10",
+			Type:        discordgo.ChatApplicationCommand,
+			Name:        "wallet",
+			Description: "Manage your wallet",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionInteger, Name: "amount", Description: "Amount", MinValue: &minValue},
+			},
+		},
+		{ID: "2", GuildID: "guild", Type: discordgo.ChatApplicationCommand, Name: "ping", Description: "Ping the bot"},
+		{ID: "3", GuildID: "guild", Type: discordgo.ChatApplicationCommand, Name: "remind", Description: "Old description"},
+		{ID: "4", GuildID: "guild", Type: discordgo.ChatApplicationCommand, Name: "autopin", Description: "Removed"},
+	}
+
+	changes := PlanCommandSync(existing, desired)
+
+	expected := []string{
+		"~ update /remind",
+		"+ create user command \"Pay this user\"",
+		"- delete /autopin",
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %v", len(expected), len(changes), changes)
+	}
+
+	for i := range expected {
+		if changes[i].String() != expected[i] {
+			t.Errorf("Change %d: expected %q, got %q", i, expected[i], changes[i].String())
+		}
+	}
+
+	if changes[0].Existing.ID != "3" {
+		t.Errorf("Expected update to target command 3, got %s", changes[0].Existing.ID)
+	}
+
+	// Nothing to do once the commands match
+	if changes := PlanCommandSync(existing[:2], desired[:2]); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+}
+
+func TestCommandsEqualOptions(t *testing.T) {
+	a := &discordgo.ApplicationCommand{
+		Name: "remind",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand,
+				Name: "del",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionString, Name: "id", Autocomplete: true},
+				},
+			},
+		},
+	}
+
+	b := &discordgo.ApplicationCommand{
+		Name: "remind",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand,
+				Name: "del",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionString, Name: "id"},
+				},
+			},
+		},
+	}
+
+	if !commandsEqual(a, a) {
+		t.Errorf("Expected command to equal itself")
+	}
+
+	if commandsEqual(a, b) {
+		t.Errorf("Expected nested option change to be detected")
+	}
+}
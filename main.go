@@ -2,8 +2,14 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	app "github.com/aussiebroadwan/tony/applications"
 	"github.com/aussiebroadwan/tony/applications/autopin"
@@ -11,19 +17,31 @@ import (
 	"github.com/aussiebroadwan/tony/applications/remind"
 	snailrace_app "github.com/aussiebroadwan/tony/applications/snailrace"
 	walletApp "github.com/aussiebroadwan/tony/applications/wallet"
+	"github.com/aussiebroadwan/tony/pkg/blackjack"
+	"github.com/aussiebroadwan/tony/pkg/snailrace"
 	"github.com/aussiebroadwan/tony/pkg/tradingcards"
 	"github.com/aussiebroadwan/tony/pkg/wallet"
 	"github.com/bwmarrin/discordgo"
 
+	"github.com/aussiebroadwan/tony/config"
 	"github.com/aussiebroadwan/tony/database"
+	"github.com/aussiebroadwan/tony/database/migrate"
 	"github.com/aussiebroadwan/tony/framework"
 
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	VERSION  = "Unreleased"
-	SERVERID = ""
+	VERSION   = "Unreleased"
+	SERVERIDS = []string{}
+
+	// CONFIGPATH is the config file set with TONY_CONFIG, which is watched
+	// for changes
+	CONFIGPATH = ""
+
+	// startupChannel is the name of the channel to announce startup in,
+	// which is updated each time the config is loaded
+	startupChannel atomic.Value
 )
 
 func init() {
@@ -38,46 +56,160 @@ func main() {
 		VERSION = version
 	}
 
+	// "tony console" runs the routes from the terminal instead of Discord,
+	// "tony sync [--dry-run]" updates the Discord commands then exits and
+	// "tony migrate up|down [steps]|status" updates the database then exits
+	mode := ""
+	if len(os.Args) > 1 {
+		mode = os.Args[1]
+	}
+
+	console := mode == "console"
+	if console || mode == "sync" || mode == "migrate" {
+		log.SetOutput(os.Stderr)
+	}
+
 	// Print version
 	log.Infof("Tony %s", VERSION)
 
+	// Load the settings admins can change without recompiling, which are
+	// reloaded on SIGHUP or when the file changes
+	CONFIGPATH = os.Getenv("TONY_CONFIG")
+	settings, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Error loading config: %s", err)
+		return
+	}
+	cfg := settings.(config.Config)
+
+	// Multiple servers can be served by separating their IDs with commas
+	for _, serverId := range strings.Split(os.Getenv("DISCORD_SERVER_ID"), ",") {
+		if serverId = strings.TrimSpace(serverId); serverId != "" {
+			SERVERIDS = append(SERVERIDS, serverId)
+		}
+	}
+
 	// Setup database
-	db := database.NewDatabase()
+	log.WithField("driver", cfg.Database.Driver).Info("Connecting to database")
+	db, err := database.Open(cfg.Database.Driver, cfg.Database.DSN)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %s", err)
+		return
+	}
+
+	migrator, err := migrate.New(db,
+		framework.Migrations,
+		wallet.Migrations,
+		tradingcards.Migrations,
+		snailrace.Migrations,
+		blackjack.Migrations,
+		autopin.Migrations,
+		remind.Migrations,
+	)
+	if err != nil {
+		log.Fatalf("Error loading migrations: %s", err)
+		return
+	}
+
+	// Records from before data was kept per server belong to the first
+	// server
+	if len(SERVERIDS) > 0 {
+		migrator.SetLegacyGuild(SERVERIDS[0])
+	}
+
+	if mode == "migrate" {
+		if err = runMigrations(migrator, os.Args[2:]); err != nil {
+			log.Fatalf("Error migrating database: %s", err)
+		}
+		return
+	}
+
+	// Don't start with tables which are older than the code
+	if err = migrator.Check(); err != nil {
+		log.Fatalf("%s, run `tony migrate up` first", err)
+		return
+	}
+
 	wallet.SetupWalletDB(db, log.WithField("src", "wallet"))
 	tradingcards.SetupTradingCardsDB(db, log.WithField("src", "tradingcards"))
 
 	token := os.Getenv("DISCORD_TOKEN")
-	SERVERID = os.Getenv("DISCORD_SERVER_ID")
 
 	// Check if token is provided
-	if token == "" {
+	if console {
+		SERVERIDS = []string{"console"}
+	} else if token == "" {
 		log.Fatal("No token provided. Please set DISCORD_TOKEN environment variable.")
 		return
 	}
 
-	if SERVERID == "" {
+	if len(SERVERIDS) == 0 {
 		log.Fatal("No server ID provided. Please set DISCORD_SERVER_ID environment variable.")
 		return
 	}
 
+	// Games in progress are given time to finish when shutting down, set
+	// SHUTDOWN_TIMEOUT (e.g. 30s) to change how long they are waited for
+	shutdownTimeout := framework.DefaultShutdownTimeout
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid SHUTDOWN_TIMEOUT: %s", err)
+			return
+		}
+		shutdownTimeout = timeout
+	}
+
 	// Create a new bot
-	bot, err := framework.NewBot(token, SERVERID, db)
+	bot, err := framework.NewBot(token, SERVERIDS, db)
 	if err != nil {
 		log.Fatalf("Error creating bot: %s", err)
 		return
 	}
 
 	bot.OnStartup(startupCb)
+	bot.Configure(cfg)
+
+	// Publish the wallet and trading card events on the bot's event bus
+	wallet.SetPublisher(bot.Publish)
+	tradingcards.SetPublisher(bot.Publish)
+
+	// Keep component state in the database so buttons and selects keep
+	// working after a restart, which needs a secret that stays the same
+	if secret := os.Getenv("COMPONENT_STATE_SECRET"); secret != "" {
+		store := framework.NewDatabaseComponentStateStore(db)
+		bot.UseComponentStates(framework.NewComponentStates(store, []byte(secret)))
+	}
+
+	// Throttle the commands and buttons that are easy to spam
+	limiter := framework.NewRateLimiter(framework.NewMemoryRateLimitStore(),
+		framework.RateLimit{Route: "wallet.pay", Scope: framework.RateLimitUser, Capacity: 3, Refill: 10 * time.Second},
+		framework.RateLimit{Route: "voteythumbs", Scope: framework.RateLimitChannel, Capacity: 2, Refill: 30 * time.Second},
+		framework.RateLimit{Route: "blackjack:host", Scope: framework.RateLimitUser, Capacity: 1, Refill: 5 * time.Second},
+		framework.RateLimit{Route: "snailrace.host", Scope: framework.RateLimitUser, Capacity: 1, Refill: 30 * time.Second},
+	)
+
+	// Middleware applied to every handler
+	bot.Use(
+		framework.Recover(),
+		framework.Timing(),
+		limiter.Middleware(),
+		framework.ErrorReply(),
+	)
 
 	// Register routes
 	bot.Register(
 		walletApp.RegisterWalletApp(bot),
+		walletApp.RegisterWalletUserApp(bot),
 
+		app.RegisterAppsApp(bot),
 		app.RegisterPingApp(bot),
 		app.RegisterVoteyThumbsApp(bot),
 
 		remind.RegisterRemindApp(bot),
+		remind.RegisterRemindMessageApp(bot),
 		autopin.RegisterAutopinApp(bot),
+		autopin.RegisterAutopinMessageApp(bot),
 
 		blackjack_app.RegisterBlackjackApp(bot),
 		snailrace_app.RegisterSnailraceApp(bot),
@@ -86,19 +218,152 @@ func main() {
 		// app.RegisterRSSModeration(bot),
 	)
 
-	// Run the bot
-	if err = bot.Run(); err != nil {
+	// Only sync the commands, or show what would change with --dry-run
+	if mode == "sync" {
+		dryRun := len(os.Args) > 2 && os.Args[2] == "--dry-run"
+
+		changes, err := bot.SyncCommands(dryRun)
+		for _, change := range changes {
+			fmt.Printf("%s: %s\n", change.GuildID, change)
+		}
+		if err != nil {
+			log.Fatalf("Error syncing commands: %s", err)
+		}
+		if len(changes) == 0 {
+			fmt.Println("Commands are up to date")
+		}
+		return
+	}
+
+	registerMetrics(bot)
+
+	// Run the console until it is closed
+	if console {
+		if err = bot.RunConsole(os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("Error running console: %s", err)
+		}
+		return
+	}
+
+	// Serve interactions over HTTP instead of the gateway if an address is set
+	if addr := os.Getenv("DISCORD_INTERACTIONS_ADDR"); addr != "" {
+		publicKey, err := framework.ParsePublicKey(os.Getenv("DISCORD_PUBLIC_KEY"))
+		if err != nil {
+			log.Fatalf("Invalid DISCORD_PUBLIC_KEY: %s", err)
+			return
+		}
+
+		if err = bot.RunHTTP(addr, publicKey); err != nil {
+			log.Fatalf("Error running interactions endpoint: %s", err)
+			return
+		}
+	} else if err = bot.Run(); err != nil {
 		log.Fatalf("Error running bot: %s", err)
 		return
 	}
-	defer bot.Close()
+
+	// Serve the metrics and health check if an address is set
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		if err = bot.ServeMetrics(addr); err != nil {
+			log.Fatalf("Error serving metrics: %s", err)
+			return
+		}
+	}
+
+	if CONFIGPATH != "" {
+		bot.WatchConfig(CONFIGPATH, loadConfig)
+	}
 
 	waitForInterrupt()
+
+	if err = bot.Shutdown(shutdownTimeout); err != nil {
+		log.Errorf("Error shutting down: %s", err)
+	}
+}
+
+// runMigrations runs "migrate up", "migrate down [steps]" or "migrate status"
+func runMigrations(migrator *migrate.Migrator, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected up, down or status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("Applied %s\n", migration)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+
+		rolledBack, err := migrator.Down(steps)
+		for _, migration := range rolledBack {
+			fmt.Printf("Rolled back %s\n", migration)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status()
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-40s %s\n", status.Migration, applied)
+		}
+		return err
+	}
+
+	return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+}
+
+// loadConfig loads the config from CONFIGPATH and the environment
+func loadConfig() (any, error) {
+	cfg, err := config.Load(CONFIGPATH)
+	if err != nil {
+		return nil, err
+	}
+
+	startupChannel.Store(cfg.StartupChannel)
+	return cfg, nil
+}
+
+// registerMetrics adds the metrics of the games and the wallet to the bot's
+// metrics. Pending reminders are counted by the scheduler's tony_jobs_pending.
+func registerMetrics(bot *framework.Bot) {
+	metrics := bot.Metrics()
+
+	metrics.GaugeFunc("tony_active_games", "Games which are running, by game.", func() []framework.MetricSample {
+		return []framework.MetricSample{
+			{Labels: []string{"blackjack"}, Value: float64(blackjack.ActiveGames())},
+			{Labels: []string{"snailrace"}, Value: float64(snailrace.ActiveRaces())},
+		}
+	}, "game")
+
+	transactions := metrics.Counter("tony_wallet_transactions_total", "Wallet transactions, by application and type.", "application", "type")
+	volume := metrics.Counter("tony_wallet_transaction_amount_total", "Coins moved by wallet transactions, by application and type.", "application", "type")
+
+	framework.Subscribe(bot.Events(), func(ctx framework.BusContext, event wallet.TransactionCreated) {
+		transactions.Inc(event.ApplicationId, string(event.Type))
+		volume.Add(math.Abs(float64(event.Amount)), event.ApplicationId, string(event.Type))
+	})
 }
 
 func waitForInterrupt() {
 	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
 	log.Println("Shutting down...")
 }
@@ -107,13 +372,17 @@ func startupCb(ctx framework.StartupContext) {
 	session := ctx.Session()
 
 	// Get Channel ID
-	channelName := os.Getenv("DISCORD_STARTUP_CHANNEL")
-	if channelName == "" {
-		channelName = "tony-dev"
+	channelName := startupChannel.Load().(string)
+
+	// Announce in the startup channel of each server
+	for _, serverId := range SERVERIDS {
+		announceStartup(session, serverId, channelName)
 	}
+}
 
+func announceStartup(session framework.Session, serverId, channelName string) {
 	// Get channels for this guild
-	channels, _ := session.GuildChannels(SERVERID)
+	channels, _ := session.GuildChannels(serverId)
 	for _, channel := range channels {
 		if channel.Name == channelName {
 
@@ -127,5 +396,5 @@ func startupCb(ctx framework.StartupContext) {
 		}
 	}
 
-	log.Warnf("Channel startup channel (#%s) not found", channelName)
+	log.WithField("guild", serverId).Warnf("Channel startup channel (#%s) not found", channelName)
 }
@@ -82,10 +82,9 @@ func (a *UserAchievements) populateAchievementsUnlocked() {
 var database *gorm.DB = nil
 
 // SetupAchievementDB initialises the database connection for storing
-// achievements.
-func SetupAchievementDB(db *gorm.DB) error {
+// achievements. The table is created and updated by Migrations.
+func SetupAchievementDB(db *gorm.DB) {
 	database = db
-	return database.AutoMigrate(&UserAchievements{})
 }
 
 // emptyAchievementStatus creates a new UserAchievements instance with default
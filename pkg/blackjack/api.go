@@ -1,17 +1,114 @@
 package blackjack
 
-func Running() bool {
-	dealer.mu.Lock()
-	defer dealer.mu.Unlock()
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// shutdownPollInterval is how often Shutdown checks if the round has finished
+const shutdownPollInterval = 100 * time.Millisecond
+
+// maxPlayers is how many players can join a round, see SetMaxPlayers
+var maxPlayers atomic.Int64
+
+func init() {
+	maxPlayers.Store(DefaultMaxPlayers)
+}
+
+// MaxPlayers returns how many players can join a round.
+func MaxPlayers() int {
+	return int(maxPlayers.Load())
+}
+
+// SetMaxPlayers changes how many players can join a round, players who have
+// already joined are kept.
+func SetMaxPlayers(players int) {
+	maxPlayers.Store(int64(players))
+}
+
+// Running reports whether a game is being played in the server
+func Running(guildId string) bool {
+	return dealerFor(guildId).running()
+}
 
-	return dealer.Stage != IdleStage && dealer.Stage != FinishedStage
+// ActiveGames returns how many servers a game is being played in
+func ActiveGames() int {
+	active := 0
+	for _, dealer := range allDealers() {
+		if dealer.running() {
+			active++
+		}
+	}
+	return active
 }
 
-// Host initialises and starts a new game of Blackjack. It requires a  callback
-// function that is invoked on game state changes, which can be used to update
-// clients. It returns an error if a game is already in progress.
-func Host(stateCb StateChangeCallback, achievementCb AchievementCallback, messageId, channelId string) error {
-	if Running() {
+// Shutdown stops new games from being hosted in every server and waits for
+// the bets of the current rounds to be paid out, then ends the games. If the
+// context is done first the rounds still running are cancelled, which hands
+// their state to the state change callback with CancelledStage to refund the
+// bets, and the context's error is returned.
+func Shutdown(ctx context.Context) error {
+	dealersMu.Lock()
+	shuttingDown = true
+	dealersMu.Unlock()
+
+	all := allDealers()
+	for _, dealer := range all {
+		dealer.mu.Lock()
+		dealer.draining = true
+		dealer.mu.Unlock()
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for !settled(all) {
+		select {
+		case <-ctx.Done():
+			for _, dealer := range all {
+				dealer.stop()
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	for _, dealer := range all {
+		dealer.stop()
+	}
+	return nil
+}
+
+// allDealers returns the dealer of every server which has used blackjack
+func allDealers() []*Dealer {
+	dealersMu.Lock()
+	defer dealersMu.Unlock()
+
+	all := make([]*Dealer, 0, len(dealers))
+	for _, dealer := range dealers {
+		all = append(all, dealer)
+	}
+	return all
+}
+
+// settled reports whether none of the dealers have bets waiting on a round
+func settled(dealers []*Dealer) bool {
+	for _, dealer := range dealers {
+		if !dealer.settled() {
+			return false
+		}
+	}
+	return true
+}
+
+// Host initialises and starts a new game of Blackjack in the server. It
+// requires a  callback function that is invoked on game state changes, which
+// can be used to update clients. It returns an error if a game is already in
+// progress in the server.
+func Host(guildId string, stateCb StateChangeCallback, achievementCb AchievementCallback, messageId, channelId string) error {
+	dealer := dealerFor(guildId)
+	if dealer.running() {
 		return ErrDealerBusy
 	}
 
@@ -24,6 +121,10 @@ func Host(stateCb StateChangeCallback, achievementCb AchievementCallback, messag
 	dealer.mu.Lock()
 	defer dealer.mu.Unlock()
 
+	if dealer.draining {
+		return ErrShuttingDown
+	}
+
 	// Initialise a new game state
 	dealer.State = newState()
 	dealer.action = make(chan int)
@@ -33,15 +134,16 @@ func Host(stateCb StateChangeCallback, achievementCb AchievementCallback, messag
 	dealer.onStateChange = stateCb
 	dealer.onAchievement = achievementCb
 
-	go executeGameLoop() // Start the game loop in a new goroutine
+	go dealer.executeGameLoop() // Start the game loop in a new goroutine
 
 	return nil
 }
 
-// Join attempts to add a player to the game during the joining phase. It takes
-// a user Discord ID and the bet amount as parameters and returns an error if
-// the player cannot join.
-func Join(userId string, bet int64) error {
+// Join attempts to add a player to the server's game during the joining phase.
+// It takes a user Discord ID and the bet amount as parameters and returns an
+// error if the player cannot join.
+func Join(guildId, userId string, bet int64) error {
+	dealer := dealerFor(guildId)
 	dealer.mu.Lock()
 	defer dealer.mu.Unlock()
 
@@ -58,7 +160,7 @@ func Join(userId string, bet int64) error {
 	}
 
 	// Check if the maximum number of players has been reached
-	if len(dealer.State.Users) >= MaxPlayers {
+	if len(dealer.State.Users) >= MaxPlayers() {
 		return ErrMaxPlayers
 	}
 
@@ -78,7 +180,8 @@ func Join(userId string, bet int64) error {
 // Hit deals another card to the player requesting it and checks if they bust.
 // It returns an error if it's not the player's turn or the game stage is
 // incorrect.
-func Hit(userId string) error {
+func Hit(guildId, userId string) error {
+	dealer := dealerFor(guildId)
 	dealer.mu.Lock()
 	defer dealer.mu.Unlock()
 
@@ -113,7 +216,8 @@ func Hit(userId string) error {
 // Stand marks the player's turn as complete and advances the game to the next
 // player. It returns an error if it's not the player's turn or if the stage is
 // not correct for standing.
-func Stand(userId string) error {
+func Stand(guildId, userId string) error {
+	dealer := dealerFor(guildId)
 	dealer.mu.Lock()
 	defer dealer.mu.Unlock()
 
@@ -0,0 +1,64 @@
+package blackjack
+
+import (
+	"context"
+	"testing"
+)
+
+const (
+	exampleGuildId = "1229977032540573766"
+	otherGuildId   = "1229977032540573767"
+	exampleUserId  = "1060681976622891089"
+)
+
+func TestDealerPerGuild(t *testing.T) {
+	t.Cleanup(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Shutdown(ctx)
+
+		dealersMu.Lock()
+		dealers, shuttingDown = make(map[string]*Dealer), false
+		dealersMu.Unlock()
+	})
+
+	stateCb := func(stage GameStage, state GameState, messageId, channelId string) {}
+	for _, guildId := range []string{exampleGuildId, otherGuildId} {
+		if err := Host(guildId, stateCb, nil, "message", "channel"); err != nil {
+			t.Fatalf("%s: failed to host game: %v", guildId, err)
+		}
+	}
+
+	if err := Host(exampleGuildId, stateCb, nil, "message", "channel"); err != ErrDealerBusy {
+		t.Errorf("Expected a second game in the server to be refused, got %v", err)
+	}
+	if !Running(exampleGuildId) || !Running(otherGuildId) || Running("guild") {
+		t.Errorf("Expected games to only be running in the servers they were hosted in")
+	}
+	if active := ActiveGames(); active != 2 {
+		t.Errorf("Expected 2 active games, got %d", active)
+	}
+
+	// Each server's game has its own players
+	for _, guildId := range []string{exampleGuildId, otherGuildId} {
+		if err := Join(guildId, exampleUserId, 50); err != nil {
+			t.Errorf("%s: failed to join: %v", guildId, err)
+		}
+	}
+	if err := Join(exampleGuildId, exampleUserId, 50); err != ErrAlreadyJoined {
+		t.Errorf("Expected ErrAlreadyJoined, got %v", err)
+	}
+
+	// Shutting down cancels every server's round
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Shutdown(ctx); err != context.Canceled {
+		t.Errorf("Expected the rounds to be cancelled, got %v", err)
+	}
+	if ActiveGames() != 0 {
+		t.Errorf("Expected no active games after shutting down")
+	}
+	if err := Host("guild", stateCb, nil, "message", "channel"); err != ErrShuttingDown {
+		t.Errorf("Expected new servers not to host games while shutting down, got %v", err)
+	}
+}
@@ -15,6 +15,10 @@ const (
 	PayoutStage    GameStage = "Payout"
 	ReshuffleStage GameStage = "Reshuffle"
 	FinishedStage  GameStage = "Finished"
+
+	// CancelledStage ends a round before its payout, the bets of the users in
+	// the state are to be refunded
+	CancelledStage GameStage = "Cancelled"
 )
 
 type StateChangeCallback func(stage GameStage, state GameState, messageId, channelId string)
@@ -29,6 +33,7 @@ type User struct {
 
 type GameState struct {
 	Id          string
+	RoundId     string // Unique to each round, set when it starts
 	Shoe        Shoe
 	Hand        Hand
 	PlayerTurn  int
@@ -55,6 +60,11 @@ type Dealer struct {
 	// user of their achievement.
 	onAchievement AchievementCallback
 
+	// draining stops new games and rounds from starting, and stopped is set
+	// once the game has been ended by Shutdown so the game loop stops
+	draining bool
+	stopped  bool
+
 	mu sync.Mutex
 }
 
@@ -62,14 +72,64 @@ func (d *Dealer) changeStage(stage GameStage) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	if d.stopped {
+		return
+	}
+
 	d.Stage = stage
 	d.onStateChange(stage, d.State, d.messageId, d.channelId)
 }
 
 func (d *Dealer) commitState() {
+	if d.stopped {
+		return
+	}
+
 	d.onStateChange(d.Stage, d.State, d.messageId, d.channelId)
 }
 
+// running reports whether a game is being played
+func (d *Dealer) running() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.Stage != IdleStage && d.Stage != FinishedStage && d.Stage != CancelledStage
+}
+
+// closing reports whether the game loop should not start another round
+func (d *Dealer) closing() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.draining || d.stopped
+}
+
+// settled reports whether no bets are waiting on the round to be paid out
+func (d *Dealer) settled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.Stage != JoinStage && d.Stage != RoundStage
+}
+
+// stop ends the game. A round which has not been paid out is cancelled so its
+// bets are refunded, otherwise the game is finished.
+func (d *Dealer) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch d.Stage {
+	case JoinStage, RoundStage:
+		d.Stage = CancelledStage
+		d.onStateChange(d.Stage, d.State, d.messageId, d.channelId)
+	case PayoutStage, ReshuffleStage:
+		d.Stage = FinishedStage
+		d.onStateChange(d.Stage, d.State, d.messageId, d.channelId)
+	}
+
+	d.stopped = true
+}
+
 func newState() GameState {
 	s := GameState{
 		Id:          fmt.Sprintf("%d", time.Now().UTC().Unix()),
@@ -9,4 +9,5 @@ var (
 	ErrInvalidAction  = errors.New("invalid action")
 	ErrPlayerTurn     = errors.New("not player's turn")
 	ErrPlayerNotFound = errors.New("player not found")
+	ErrShuttingDown   = errors.New("the dealer is closing the table")
 )
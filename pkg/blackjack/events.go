@@ -0,0 +1,14 @@
+package blackjack
+
+// RoundFinished is published by the app running the game once a round has
+// been paid out. Each user's Bet is what they were paid, which is zero if they
+// lost the round.
+type RoundFinished struct {
+	GuildId   string
+	ChannelId string
+	MessageId string
+
+	GameId string
+	Hand   Hand // The dealer's hand
+	Users  []User
+}
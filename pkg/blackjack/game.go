@@ -1,12 +1,13 @@
 package blackjack
 
 import (
+	"fmt"
 	"sync"
 	"time"
 )
 
 const (
-	MaxPlayers            = 7
+	DefaultMaxPlayers     = 7
 	DefaultDeckCount      = 6
 	DefaultPayoutRatio    = 1.0
 	BlackjackPayoutRatio  = 1.5
@@ -21,25 +22,44 @@ const (
 	ReshuffleDuration     = 10 * time.Second
 )
 
-// The dealer manages the game state and controls the flow of the game. This is
-// the only dealer instance that should be used in the application.
-var dealer *Dealer = &Dealer{
-	State: GameState{
-		Shoe:        NewShoe(DefaultDeckCount),
-		Hand:        make([]Card, 0),
-		Users:       make([]User, 0),
-		PlayerTurn:  0,
-		ShoePlayers: make(map[string]bool),
-	},
-	Stage:         IdleStage,
-	onStateChange: func(stage GameStage, state GameState, messageId, channelId string) {},
-	onAchievement: func(userId string, achievementName string) bool { return false },
-	action:        make(chan int),
-	mu:            sync.Mutex{},
+// dealers are the dealers of each server, which each manage the game state
+// and control the flow of the server's game. shuttingDown is set by Shutdown
+// so dealers created after it don't host new games either.
+var (
+	dealers      = make(map[string]*Dealer)
+	shuttingDown bool
+	dealersMu    sync.Mutex
+)
+
+// dealerFor returns the server's dealer, creating it the first time
+func dealerFor(guildId string) *Dealer {
+	dealersMu.Lock()
+	defer dealersMu.Unlock()
+
+	if dealer, ok := dealers[guildId]; ok {
+		return dealer
+	}
+
+	dealer := &Dealer{
+		State: GameState{
+			Shoe:        NewShoe(DefaultDeckCount),
+			Hand:        make([]Card, 0),
+			Users:       make([]User, 0),
+			PlayerTurn:  0,
+			ShoePlayers: make(map[string]bool),
+		},
+		Stage:         IdleStage,
+		onStateChange: func(stage GameStage, state GameState, messageId, channelId string) {},
+		onAchievement: func(userId string, achievementName string) bool { return false },
+		action:        make(chan int),
+		draining:      shuttingDown,
+	}
+	dealers[guildId] = dealer
+	return dealer
 }
 
 // initialDeal deals two cards to each player and one to the dealer.
-func initialDeal() {
+func (dealer *Dealer) initialDeal() {
 	dealer.mu.Lock()
 	defer dealer.mu.Unlock()
 
@@ -71,7 +91,7 @@ func checkForBlackjack(user *User) {
 }
 
 // calculatePayouts determines the winnings or losses for each player.
-func calculatePayouts() {
+func (dealer *Dealer) calculatePayouts() {
 	dealer.mu.Lock()
 	defer dealer.mu.Unlock()
 
@@ -96,7 +116,7 @@ func calculatePayouts() {
 }
 
 // processPlayerTurns cycles through each player's turn until all have acted.
-func processPlayerTurns() {
+func (dealer *Dealer) processPlayerTurns() {
 	dealer.State.PlayerTurn = 0
 	for dealer.State.PlayerTurn < len(dealer.State.Users) {
 
@@ -124,11 +144,11 @@ func processPlayerTurns() {
 	}
 
 	// Process the dealer's turn
-	dealerPlay()
+	dealer.dealerPlay()
 }
 
 // dealerPlay simulates the dealer's play according to the house rules.
-func dealerPlay() {
+func (dealer *Dealer) dealerPlay() {
 	for dealer.State.Hand.Score() < DealerStandScore {
 		dealer.State.Hand = append(dealer.State.Hand, dealer.State.Shoe.Draw())
 		dealer.commitState()
@@ -137,7 +157,17 @@ func dealerPlay() {
 }
 
 // executeGameLoop manages the flow of the game from start to finish.
-func executeGameLoop() {
+func (dealer *Dealer) executeGameLoop() {
+	// Finish the game instead of starting another round when shutting down
+	if dealer.closing() {
+		dealer.changeStage(FinishedStage)
+		return
+	}
+
+	dealer.mu.Lock()
+	dealer.State.RoundId = fmt.Sprintf("%d", time.Now().UTC().UnixNano())
+	dealer.mu.Unlock()
+
 	dealer.changeStage(JoinStage)
 
 	time.Sleep(JoinTimeoutDuration)
@@ -147,11 +177,11 @@ func executeGameLoop() {
 	}
 
 	dealer.changeStage(RoundStage)
-	initialDeal()
-	processPlayerTurns()
+	dealer.initialDeal()
+	dealer.processPlayerTurns()
 	time.Sleep(ScoreCountingDelay)
 
-	calculatePayouts()
+	dealer.calculatePayouts()
 	dealer.changeStage(PayoutStage)
 	time.Sleep(PayoutProcessingDelay)
 
@@ -168,5 +198,5 @@ func executeGameLoop() {
 		dealer.State.PlayerTurn = -1
 	}
 
-	executeGameLoop()
+	dealer.executeGameLoop()
 }
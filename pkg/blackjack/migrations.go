@@ -0,0 +1,53 @@
+package blackjack
+
+import (
+	"github.com/aussiebroadwan/tony/database/migrate"
+	"gorm.io/gorm"
+)
+
+// userAchievementsV1 is the achievements table as it was first created by
+// migrations, later changes are made by the migrations after
+type userAchievementsV1 struct {
+	UserId string `gorm:"primaryKey"`
+
+	RoundsPlayed int64
+	LastShoeId   string
+	ShoesPlayed  int64
+	RoundsWon    int64
+	RoundsLost   int64
+
+	RoundsSinceLastWin             int64
+	RoundsSinceLastWinBlackjack    int64
+	RoundsSinceLastWinNonBlackjack int64
+
+	TotalWinnings      int64
+	TotalLosses        int64
+	NumberOfBlackjacks int
+	BlackjackStreak    int
+
+	AchievedFirstWin            bool
+	Achieved100Games            bool
+	Achieved3Blackjacks         bool
+	Achieved1kTotalWinnings     bool
+	AchievedLoss1kInOneRound    bool
+	Achieved7LossesInARowThenBJ bool
+	Achieved21In2Cards21Times   bool
+	Achieved21In7CardsWin       bool
+}
+
+func (userAchievementsV1) TableName() string { return "user_achievements" }
+
+// Migrations are the changes to the blackjack tables, in order
+var Migrations = []migrate.Migration{
+	{
+		Package: "blackjack",
+		Version: 1,
+		Name:    "create achievements table",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&userAchievementsV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&userAchievementsV1{})
+		},
+	},
+}
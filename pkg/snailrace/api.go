@@ -1,16 +1,32 @@
 package snailrace
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	JoinDelay  time.Duration = 30 * time.Second
-	StartDelay time.Duration = 30 * time.Second
+	DefaultJoinDelay time.Duration = 30 * time.Second
+	StartDelay       time.Duration = 30 * time.Second
 
 	PuntersPerRace int = 128
 )
 
+// joinDelay is how long snails can join a race, see SetJoinDelay
+var joinDelay atomic.Int64
+
+func init() {
+	joinDelay.Store(int64(DefaultJoinDelay))
+}
+
+// SetJoinDelay changes how long snails can join the races hosted after it is
+// called.
+func SetJoinDelay(delay time.Duration) {
+	joinDelay.Store(int64(delay))
+}
+
 // HostRace initialises and starts a new race with given parameters. It requires
 // a state change callback, an achievement callback, a message ID, and a channel
 // ID to properly configure the race. If any parameter is invalid, it returns an
@@ -23,9 +39,14 @@ func HostRace(stateCb StateChangeCallback, achievementCb AchievementCallback, me
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
+	if manager.draining {
+		return ErrShuttingDown
+	}
+
 	now := time.Now()
+	delay := time.Duration(joinDelay.Load())
 	race := newRace() // Simplify race creation with a safe newRace function
-	race.StartAt = now.Add(StartDelay).Add(JoinDelay)
+	race.StartAt = now.Add(StartDelay).Add(delay)
 
 	r := &RaceState{
 		Race:           race,
@@ -37,26 +58,88 @@ func HostRace(stateCb StateChangeCallback, achievementCb AchievementCallback, me
 		ChannelId:      channelId,
 		stateCb:        stateCb,
 		achievementCb:  achievementCb,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+		mu:             &sync.Mutex{},
 	}
 
 	manager.races[race.Id] = r
-	go r.Start(now.Add(JoinDelay)) // Start the race after the joining delay
+	go r.Start(now.Add(delay)) // Start the race after the joining delay
 
 	return nil
 }
 
-// GetSnails retrieves all snails owned by a user from the database. If the user
+// ActiveRaces returns how many races have not finished yet.
+func ActiveRaces() int {
+	if manager == nil {
+		return 0
+	}
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	active := 0
+	for _, state := range manager.races {
+		if !state.ended() {
+			active++
+		}
+	}
+	return active
+}
+
+// Shutdown stops new races from being hosted and waits for the running races
+// to end. If the context is done first the races still running are cancelled,
+// which hands them to the state change callback with StateCancelled so their
+// bets can be refunded, and the context's error is returned.
+func Shutdown(ctx context.Context) error {
+	if manager == nil {
+		return nil
+	}
+
+	manager.mu.Lock()
+	manager.draining = true
+	races := make([]*RaceState, 0, len(manager.races))
+	for _, r := range manager.races {
+		races = append(races, r)
+	}
+	manager.mu.Unlock()
+
+	for _, r := range races {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+		}
+	}
+
+	if ctx.Err() == nil {
+		return nil
+	}
+
+	for _, r := range races {
+		select {
+		case <-r.done:
+		default:
+			close(r.stop)
+			<-r.done
+		}
+	}
+	return ctx.Err()
+}
+
+// GetSnails retrieves all snails owned by a user in a server from the database.
+// Each server has its own snails. If the user
 // does not own any snails, a new snail is generated, saved to the database, and
 // returned. It returns a slice of Snail objects or an error if the database
 // query fails.
-func GetSnails(userId string, onNewSnail func(s Snail)) ([]Snail, error) {
+func GetSnails(guildId, userId string, onNewSnail func(s Snail)) ([]Snail, error) {
 	var snails []Snail
-	if err := database.Where(Snail{OwnerId: userId}).Find(&snails).Error; err != nil {
+	if err := database.Where(Snail{GuildId: guildId, OwnerId: userId}).Find(&snails).Error; err != nil {
 		return nil, err
 	}
 
 	if len(snails) < 1 {
 		snail := GenerateSnail()
+		snail.GuildId = guildId
 		snail.OwnerId = userId
 		if err := database.Create(&snail).Error; err != nil {
 			return nil, err
@@ -70,13 +153,13 @@ func GetSnails(userId string, onNewSnail func(s Snail)) ([]Snail, error) {
 }
 
 // JoinRace allows a user to add a snail to a race during the joining phase. The
-// function requires a user ID, a race ID, and a snail ID. It checks the
-// ownership of the snail and the current state of the race. If the race is not
+// function requires a server ID, a user ID, a race ID, and a snail ID. It checks
+// the ownership of the snail in the server and the current state of the race. If the race is not
 // in the joining state, or the snail does not belong to the user, it returns
 // an error.
-func JoinRace(userId, raceId, snailId string) error {
+func JoinRace(guildId, userId, raceId, snailId string) error {
 	snail := Snail{}
-	if err := database.First(&snail, Snail{Id: snailId}).Error; err != nil {
+	if err := database.First(&snail, Snail{Id: snailId, GuildId: guildId}).Error; err != nil {
 		return ErrSnailNotFound
 	}
 
@@ -84,13 +167,13 @@ func JoinRace(userId, raceId, snailId string) error {
 		return ErrNotSnailOwner
 	}
 
-	manager.mu.Lock()
-	defer manager.mu.Unlock()
-	r, ok := manager.races[raceId]
+	r, ok := findRace(raceId)
 	if !ok {
 		return ErrRaceNotFound
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.State != StateJoining {
 		return ErrInvalidRaceState
 	}
@@ -108,13 +191,13 @@ func JoinRace(userId, raceId, snailId string) error {
 // if the race exists. It returns an error if the race is not found or not in
 // the correct state.
 func PlaceBet(userId, raceId string, snailIdx int, amount int64) error {
-	manager.mu.Lock()
-	defer manager.mu.Unlock()
-	r, ok := manager.races[raceId]
+	r, ok := findRace(raceId)
 	if !ok {
 		return ErrRaceNotFound
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.State != StateBetting {
 		return ErrInvalidRaceState
 	}
@@ -126,13 +209,13 @@ func PlaceBet(userId, raceId string, snailIdx int, amount int64) error {
 // DeleteSnailAfterRace marks a snail to be removed from the database after the
 // race is finished.
 func DeleteSnailAfterRace(raceId, snailId string) error {
-	manager.mu.Lock()
-	defer manager.mu.Unlock()
-	r, ok := manager.races[raceId]
+	r, ok := findRace(raceId)
 	if !ok {
 		return ErrRaceNotFound
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.snailsToRemove = append(r.snailsToRemove, snailId)
 
 	return nil
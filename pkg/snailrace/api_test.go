@@ -0,0 +1,172 @@
+package snailrace
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"gorm.io/gorm"
+)
+
+const (
+	exampleGuildId = "1229977032540573766"
+	otherGuildId   = "1229977032540573767"
+	exampleUserId  = "1060681976622891089"
+	otherUserId    = "169015299834642432"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db := dbtest.Open(t, Migrations)
+	if err := SetupSnailraceDB(db); err != nil {
+		t.Fatalf("failed to set up snailrace: %v", err)
+	}
+	return db
+}
+
+func TestSetupPunters(t *testing.T) {
+	db := setupTestDB(t)
+
+	var count int64
+	db.Model(&Punter{}).Count(&count)
+	if count != NumberOfPunters {
+		t.Errorf("expected %d punters, got %d", NumberOfPunters, count)
+	}
+
+	// Setting up again keeps the punters
+	if err := setupPunters(); err != nil {
+		t.Fatalf("failed to set up punters: %v", err)
+	}
+	db.Model(&Punter{}).Count(&count)
+	if count != NumberOfPunters {
+		t.Errorf("expected %d punters after setting up again, got %d", NumberOfPunters, count)
+	}
+}
+
+func TestGetSnails(t *testing.T) {
+	setupTestDB(t)
+
+	created := 0
+	onNewSnail := func(s Snail) { created++ }
+
+	snails, err := GetSnails(exampleGuildId, exampleUserId, onNewSnail)
+	if err != nil || len(snails) != 1 || created != 1 {
+		t.Fatalf("expected a new snail to be generated, got %v (created %d): %v", snails, created, err)
+	}
+
+	again, err := GetSnails(exampleGuildId, exampleUserId, onNewSnail)
+	if err != nil || len(again) != 1 || again[0].Id != snails[0].Id || created != 1 {
+		t.Errorf("expected the same snail to be returned, got %v: %v", again, err)
+	}
+
+	// Each server has its own snails
+	other, err := GetSnails(otherGuildId, exampleUserId, onNewSnail)
+	if err != nil || len(other) != 1 || other[0].Id == snails[0].Id || created != 2 {
+		t.Errorf("expected a new snail in the other server, got %v: %v", other, err)
+	}
+}
+
+func TestJoinRace(t *testing.T) {
+	db := setupTestDB(t)
+
+	var mu sync.Mutex
+	states := []int{}
+	raceIds := make(chan string, 1)
+	stateCb := func(state RaceState, messageId, channelId string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(states) == 0 {
+			raceIds <- state.Race.Id
+		}
+		states = append(states, state.State)
+	}
+
+	if err := HostRace(stateCb, nil, "message", "channel"); err != nil {
+		t.Fatalf("failed to host race: %v", err)
+	}
+
+	var raceId string
+	select {
+	case raceId = <-raceIds:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the race to be started")
+	}
+
+	if active := ActiveRaces(); active != 1 {
+		t.Errorf("expected 1 active race, got %d", active)
+	}
+
+	snails, _ := GetSnails(exampleGuildId, exampleUserId, func(Snail) {})
+	snailId := snails[0].Id
+
+	tests := []struct {
+		name    string
+		guildId string
+		userId  string
+		raceId  string
+		snailId string
+		err     error
+	}{
+		{"unknown snail", exampleGuildId, exampleUserId, raceId, "missing", ErrSnailNotFound},
+		{"snail in another server", otherGuildId, exampleUserId, raceId, snailId, ErrSnailNotFound},
+		{"not the owner", exampleGuildId, otherUserId, raceId, snailId, ErrNotSnailOwner},
+		{"unknown race", exampleGuildId, exampleUserId, "missing", snailId, ErrRaceNotFound},
+		{"joined", exampleGuildId, exampleUserId, raceId, snailId, nil},
+		{"already joined", exampleGuildId, exampleUserId, raceId, snailId, ErrAlreadyJoined},
+	}
+
+	for _, test := range tests {
+		if err := JoinRace(test.guildId, test.userId, test.raceId, test.snailId); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	var links int64
+	db.Model(&SnailRaceLink{}).Where(SnailRaceLink{RaceId: raceId, SnailId: snailId}).Count(&links)
+	if links != 1 {
+		t.Errorf("expected the snail to be linked to the race, got %d links", links)
+	}
+
+	// Shutting down cancels the race, which only has one snail
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	Shutdown(ctx)
+
+	if active := ActiveRaces(); active != 0 {
+		t.Errorf("expected no active races after shutting down, got %d", active)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(states) == 0 || states[len(states)-1] != StateCancelled {
+		t.Errorf("expected the race to be cancelled, got states %v", states)
+	}
+}
+
+func TestRaceHistory(t *testing.T) {
+	db := setupTestDB(t)
+
+	snails, _ := GetSnails(exampleGuildId, exampleUserId, func(Snail) {})
+	loser, _ := GetSnails(exampleGuildId, otherUserId, func(Snail) {})
+
+	r := &RaceState{
+		Snails:         []*Snail{&snails[0], &loser[0]},
+		Place:          map[int]int{0: 1, 1: 2},
+		snailsToRemove: []string{loser[0].Id},
+	}
+	r.updateSnailHistory()
+	r.removeMarkedSnail()
+
+	var winner Snail
+	db.First(&winner, Snail{Id: snails[0].Id})
+	if winner.Prev1Place != 1 {
+		t.Errorf("expected the winner's last place to be 1, got %d", winner.Prev1Place)
+	}
+
+	var count int64
+	db.Model(&Snail{}).Where(Snail{Id: loser[0].Id}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected the marked snail to be deleted")
+	}
+}
@@ -10,4 +10,5 @@ var (
 	ErrSnailNotFound     = errors.New("snail not found")
 	ErrNotSnailOwner     = errors.New("not the owner of the snail")
 	ErrAlreadyJoined     = errors.New("already joined the race")
+	ErrShuttingDown      = errors.New("races are closed while shutting down")
 )
@@ -0,0 +1,14 @@
+package snailrace
+
+// RaceFinished is published by the app hosting the race once it has finished.
+// Place maps the index of each snail in Snails to where it placed.
+type RaceFinished struct {
+	GuildId   string
+	ChannelId string
+	MessageId string
+
+	RaceId   string
+	Snails   []*Snail
+	Place    map[int]int
+	UserBets []UserBet
+}
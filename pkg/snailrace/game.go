@@ -41,7 +41,11 @@ func setupPunters() error {
 // RaceManager manages the races and their states.
 type RaceManager struct {
 	races map[string]*RaceState
-	mu    sync.Mutex
+
+	// draining stops new races from being hosted, see Shutdown
+	draining bool
+
+	mu sync.Mutex
 }
 
 // InitializeRaceManager sets up a new game state with race manager.
@@ -62,13 +66,22 @@ func (rm *RaceManager) raceCleaner() {
 	}
 }
 
-// cleanupRaces removes races that are finished.
+// findRace looks up a race which is being run.
+func findRace(raceId string) (*RaceState, bool) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	r, ok := manager.races[raceId]
+	return r, ok
+}
+
+// cleanupRaces removes races that are finished or cancelled.
 func (rm *RaceManager) cleanupRaces() {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
 
 	for id, state := range rm.races {
-		if state.State == StateFinished {
+		if state.ended() {
 			delete(rm.races, id)
 		}
 	}
@@ -78,10 +91,6 @@ func (rm *RaceManager) cleanupRaces() {
 func SetupSnailraceDB(db *gorm.DB) error {
 	database = db
 
-	if err := database.AutoMigrate(&Punter{}, &Snail{}, &Race{}, &SnailRaceLink{}); err != nil {
-		return err
-	}
-
 	if err := setupPunters(); err != nil {
 		return err
 	}
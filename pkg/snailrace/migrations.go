@@ -0,0 +1,86 @@
+package snailrace
+
+import (
+	"time"
+
+	"github.com/aussiebroadwan/tony/database/migrate"
+	"gorm.io/gorm"
+)
+
+// The snailrace tables as they were first created by migrations, later
+// changes are made by the migrations after
+type punterV1 struct {
+	gorm.Model
+
+	Budget     int64
+	Preference uint16
+}
+
+func (punterV1) TableName() string { return "punters" }
+
+type snailV1 struct {
+	Id      string `gorm:"primaryKey"`
+	GuildId string
+	OwnerId string
+	Name    string
+	Type    int
+
+	Speed        float64
+	Acceleration float64
+	Weight       float64
+	Stamina      int
+	Luck         float64
+
+	Prev1Place int
+	Prev2Place int
+	Prev3Place int
+}
+
+func (snailV1) TableName() string { return "snails" }
+
+type raceV1 struct {
+	Id      string `gorm:"primaryKey"`
+	StartAt time.Time
+	Pool    int64
+}
+
+func (raceV1) TableName() string { return "races" }
+
+type snailRaceLinkV1 struct {
+	gorm.Model
+
+	RaceId  string
+	SnailId string
+	Pool    int64
+}
+
+func (snailRaceLinkV1) TableName() string { return "snail_race_links" }
+
+// Migrations are the changes to the snailrace tables, in order
+var Migrations = []migrate.Migration{
+	{
+		Package: "snailrace",
+		Version: 1,
+		Name:    "create snailrace tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&punterV1{}, &snailV1{}, &raceV1{}, &snailRaceLinkV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&punterV1{}, &snailV1{}, &raceV1{}, &snailRaceLinkV1{})
+		},
+	},
+	{
+		// Snails from before they were kept per server have no server ID
+		Package: "snailrace",
+		Version: 2,
+		Name:    "give legacy snails a server",
+		Up: func(tx *gorm.DB) error {
+			return migrate.BackfillGuild(tx, "snails")
+		},
+		Down: func(tx *gorm.DB) error {
+			// Legacy snails can't be told apart from the server's own
+			// once they are backfilled, so they keep the server
+			return nil
+		},
+	},
+}
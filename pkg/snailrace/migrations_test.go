@@ -0,0 +1,32 @@
+package snailrace
+
+import (
+	"testing"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"github.com/aussiebroadwan/tony/database/migrate"
+)
+
+func TestMigrateLegacySnails(t *testing.T) {
+	// A snail from before they were kept per server
+	db := dbtest.Open(t, Migrations[:1])
+	db.Exec("INSERT INTO snails (id, owner_id, name) VALUES (?, ?, ?)", "legacy", exampleUserId, "Legacy")
+
+	migrator, err := migrate.New(db, Migrations)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	migrator.SetLegacyGuild(exampleGuildId)
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if err := SetupSnailraceDB(db); err != nil {
+		t.Fatalf("failed to set up snailrace: %v", err)
+	}
+
+	onNewSnail := func(s Snail) { t.Errorf("expected the legacy snail to be used, got a new snail %s", s.Name) }
+	snails, err := GetSnails(exampleGuildId, exampleUserId, onNewSnail)
+	if err != nil || len(snails) != 1 || snails[0].Id != "legacy" {
+		t.Errorf("expected the legacy snail in the legacy server, got %v: %v", snails, err)
+	}
+}
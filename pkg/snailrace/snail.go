@@ -47,6 +47,7 @@ func SnailType(t int) string {
 
 type Snail struct {
 	Id      string `gorm:"primaryKey"`
+	GuildId string
 	OwnerId string
 	Name    string
 	Type    int
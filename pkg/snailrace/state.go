@@ -3,6 +3,7 @@ package snailrace
 import (
 	"math/rand"
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -26,7 +27,8 @@ type AchievementCallback func(userId string, achievementName string) bool
 // state.
 type StateChangeCallback func(raceState RaceState, messageId, channelId string)
 
-// RaceState holds all data related to the state of a race.
+// RaceState holds all data related to the state of a race. The callbacks are
+// given a copy, taken while the race is locked.
 type RaceState struct {
 	Race  *Race
 	State int
@@ -41,56 +43,112 @@ type RaceState struct {
 	MessageId string
 	ChannelId string
 
+	// Interrupted is set when the race was cancelled by Shutdown rather than
+	// for not having enough racers
+	Interrupted bool
+
 	stateCb       StateChangeCallback
 	achievementCb AchievementCallback
+
+	// stop cancels the race, done is closed once the race has ended
+	stop chan struct{}
+	done chan struct{}
+
+	// mu guards the fields above which change while the race runs, it is a
+	// pointer so the copies handed to the callbacks share it
+	mu *sync.Mutex
 }
 
 func (r *RaceState) Start(betTime time.Time) {
+	defer close(r.done)
+
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
+	r.mu.Lock()
 	r.stateCb(*r, r.MessageId, r.ChannelId)
+	r.mu.Unlock()
 
-	for range ticker.C {
-		switch r.State {
-		case StateJoining:
-			if time.Now().After(betTime) {
-				if len(r.Snails) < MinRacers {
-					r.transitionState(StateCancelled)
-					return
-				}
-
-				r.puntersPlaceBets()
-				r.transitionState(StateBetting)
-			}
-		case StateBetting:
-			if time.Now().After(r.Race.StartAt) {
-				r.SnailPositions = make(map[int][]float64)
-				r.SimulateRace()
-				r.transitionState(StateInProgress)
-			}
-		case StateInProgress:
-			if r.Step < r.RequriedSteps {
-				r.Step++
-				r.stateCb(*r, r.MessageId, r.ChannelId)
-			} else {
-				r.updateSnailHistory()
-				r.transitionState(StateFinished)
-			}
-		case StateFinished, StateCancelled:
-			r.removeMarkedSnail()
+	for {
+		select {
+		case <-r.stop:
+			r.cancel()
+			return
+		case <-ticker.C:
+		}
+
+		if ended := r.tick(betTime); ended {
 			return
 		}
 	}
 }
 
-// transitionState updates the state of the race and triggers a callback.
+// cancel ends the race early, handing its bets to the callback to be
+// refunded.
+func (r *RaceState) cancel() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.State != StateFinished && r.State != StateCancelled {
+		r.Interrupted = true
+		r.transitionState(StateCancelled)
+	}
+	r.removeMarkedSnail()
+}
+
+// tick moves the race along by one step, returning true once it has ended.
+func (r *RaceState) tick(betTime time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch r.State {
+	case StateJoining:
+		if time.Now().After(betTime) {
+			if len(r.Snails) < MinRacers {
+				r.transitionState(StateCancelled)
+				return true
+			}
+
+			r.puntersPlaceBets()
+			r.transitionState(StateBetting)
+		}
+	case StateBetting:
+		if time.Now().After(r.Race.StartAt) {
+			r.SnailPositions = make(map[int][]float64)
+			r.SimulateRace()
+			r.transitionState(StateInProgress)
+		}
+	case StateInProgress:
+		if r.Step < r.RequriedSteps {
+			r.Step++
+			r.stateCb(*r, r.MessageId, r.ChannelId)
+		} else {
+			r.updateSnailHistory()
+			r.transitionState(StateFinished)
+		}
+	case StateFinished, StateCancelled:
+		r.removeMarkedSnail()
+		return true
+	}
+	return false
+}
+
+// ended checks if the race has finished or been cancelled.
+func (r *RaceState) ended() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.State == StateFinished || r.State == StateCancelled
+}
+
+// transitionState updates the state of the race and triggers a callback. The
+// race must be locked.
 func (r *RaceState) transitionState(newState int) {
 	r.State = newState
 	r.stateCb(*r, r.MessageId, r.ChannelId)
 }
 
-// Join adds a snail to the race.
+// Join adds a snail to the race. The race must be locked.
 func (r *RaceState) Join(snail Snail) error {
 	snailPtr := &snail
 
@@ -0,0 +1,18 @@
+package tradingcards
+
+// CardAssigned is published when a card is given to a user.
+type CardAssigned struct {
+	UserId string
+	Card   Card
+}
+
+// publish is where the trading card events are published, set by
+// SetPublisher
+var publish func(event any) = func(event any) {}
+
+// SetPublisher sets where the trading card events are published, such as the
+// bot's event bus. It is passed in so the package does not depend on the
+// framework.
+func SetPublisher(publisher func(event any)) {
+	publish = publisher
+}
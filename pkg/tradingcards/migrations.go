@@ -0,0 +1,48 @@
+package tradingcards
+
+import (
+	"github.com/aussiebroadwan/tony/database/migrate"
+	"gorm.io/gorm"
+)
+
+// userCardV1 and cardV1 are the trading card tables as they were first
+// created by migrations, later changes are made by the migrations after
+type userCardV1 struct {
+	gorm.Model
+
+	UserId   string
+	CardName string
+	Usages   int
+}
+
+func (userCardV1) TableName() string { return "user_cards" }
+
+type cardV1 struct {
+	Name        string
+	Title       string
+	Description string `gorm:"size:1024"`
+	Application string
+	Rarity      string
+	Usable      bool
+	Tradable    bool
+	Unbreakable bool
+	MaxUsage    int
+	SVG         string
+}
+
+func (cardV1) TableName() string { return "cards" }
+
+// Migrations are the changes to the trading card tables, in order
+var Migrations = []migrate.Migration{
+	{
+		Package: "tradingcards",
+		Version: 1,
+		Name:    "create trading card tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&userCardV1{}, &cardV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&userCardV1{}, &cardV1{})
+		},
+	},
+}
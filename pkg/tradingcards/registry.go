@@ -10,12 +10,10 @@ import (
 
 var lg *log.Entry = log.New().WithField("src", "tradingcards")
 
+// SetupTradingCardsDB sets up the trading cards to use the logger. The
+// tables are created and updated by Migrations.
 func SetupTradingCardsDB(db *gorm.DB, logger *log.Entry) {
 	lg = logger
-
-	if err := db.AutoMigrate(&UserCard{}, &Card{}); err != nil {
-		lg.WithError(err).Fatal("Failed to auto-migrate tradingcards tables")
-	}
 }
 
 // RegisterCard adds a new card to the registry. If the card already exists, it
@@ -70,8 +68,8 @@ func GetUserCard(db *gorm.DB, userId, cardName string) (Card, error) {
 	return card, err
 }
 
-// AssignCard assigns a card to a user. If the card does not exist, it returns
-// an error.
+// AssignCard assigns a card to a user and publishes CardAssigned. If the card
+// does not exist, it returns an error.
 func AssignCard(db *gorm.DB, userId, cardName string) error {
 
 	// Check if card exists
@@ -90,7 +88,13 @@ func AssignCard(db *gorm.DB, userId, cardName string) error {
 		return ErrAlreadyHaveCard
 	}
 
-	return db.Create(&UserCard{UserId: userId, CardName: cardName, Usages: card.MaxUsage}).Error
+	err = db.Create(&UserCard{UserId: userId, CardName: cardName, Usages: card.MaxUsage}).Error
+	if err != nil {
+		return err
+	}
+
+	publish(CardAssigned{UserId: userId, Card: card})
+	return nil
 }
 
 // RevokeCard revokes a card from a user. If the card does not exist, it returns
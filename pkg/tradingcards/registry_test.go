@@ -3,25 +3,16 @@ package tradingcards
 import (
 	"testing"
 
-	"github.com/glebarez/sqlite"
+	"github.com/aussiebroadwan/tony/database/dbtest"
 	"gorm.io/gorm"
 )
 
 func setupTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
-	if err != nil {
-		t.Fatalf("failed to connect to database: %v", err)
-	}
-
-	// Migrate tables
-	SetupTradingCardsDB(db, lg)
-
-	return db
+	return dbtest.Open(t, Migrations)
 }
 
 func TestRegisterCard(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&UserCard{}, &Card{})
 
 	card := Card{
 		Name:        "test_card",
@@ -50,7 +41,6 @@ func TestRegisterCard(t *testing.T) {
 
 func TestCardAssign(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&UserCard{}, &Card{})
 
 	card := Card{
 		Name:        "test_card",
@@ -86,7 +76,6 @@ func TestCardAssign(t *testing.T) {
 
 func TestCardRevoke(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&UserCard{}, &Card{})
 
 	card := Card{
 		Name:        "test_card",
@@ -129,7 +118,6 @@ func TestCardRevoke(t *testing.T) {
 
 func TestTransferCard(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&UserCard{}, &Card{})
 
 	card := Card{
 		Name:        "test_card",
@@ -179,7 +167,6 @@ func TestTransferCard(t *testing.T) {
 
 func TestListUserCards(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&UserCard{}, &Card{})
 
 	card := Card{
 		Name:        "test_card",
@@ -225,7 +212,6 @@ func TestListUserCards(t *testing.T) {
 
 func TestUseCard(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&UserCard{}, &Card{})
 
 	card := Card{
 		Name:        "test_card",
@@ -288,7 +274,6 @@ func TestUseCard(t *testing.T) {
 
 func TestRepairCard(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&UserCard{}, &Card{})
 
 	card := Card{
 		Name:        "test_card",
@@ -0,0 +1,17 @@
+package wallet
+
+// TransactionCreated is published when a transaction is made on a user's
+// wallet, a transfer publishes one for each user. The house account side of
+// a credit or debit and opening balances are not published.
+type TransactionCreated struct {
+	Transaction
+}
+
+// publish is where the wallet's events are published, set by SetPublisher
+var publish func(event any) = func(event any) {}
+
+// SetPublisher sets where the wallet's events are published, such as the bot's
+// event bus. It is passed in so the wallet does not depend on the framework.
+func SetPublisher(publisher func(event any)) {
+	publish = publisher
+}
@@ -0,0 +1,241 @@
+package wallet
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// House accounts are the other side of money which isn't moved between users,
+// such as bets and winnings, or the balances new users start with which come
+// from the mint. They only have balances in the ledger, which can be
+// negative, the mint's is minus all the money it has given out.
+const (
+	HouseBlackjack = "house:blackjack"
+	HouseSnailrace = "house:snailrace"
+	HouseMint      = "house:mint"
+)
+
+// houseAccounts are the house accounts of the applications which have their
+// own, the rest use the mint
+var houseAccounts = map[string]string{
+	"blackjack": HouseBlackjack,
+	"snailrace": HouseSnailrace,
+}
+
+var ErrUnbalancedEntry = errors.New("ledger entry does not balance")
+
+// HouseAccount returns the house account an application's credits come from
+// and its debits go to.
+func HouseAccount(applicationId string) string {
+	if account, ok := houseAccounts[applicationId]; ok {
+		return account
+	}
+	return HouseMint
+}
+
+// IsHouseAccount checks if the wallet is a house account rather than a user's.
+func IsHouseAccount(userId string) bool {
+	return strings.HasPrefix(userId, "house:")
+}
+
+// posting is one side of a ledger entry
+type posting struct {
+	Type        TransactionType
+	Amount      int64
+	Description string
+	UserId      string
+}
+
+// post records the ledger entry made up of the postings, whose credits and
+// debits must add up to the same amount. It returns the transactions created
+// for the postings, in the same order. The wallets' balances are not changed.
+func post(tx *gorm.DB, entry LedgerEntry, postings ...posting) ([]Transaction, error) {
+	var sum int64
+	for _, p := range postings {
+		sum += signed(p.Type, p.Amount)
+	}
+	if sum != 0 || len(postings) < 2 {
+		return nil, ErrUnbalancedEntry
+	}
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	transactions := make([]Transaction, 0, len(postings))
+	for _, p := range postings {
+		transaction, err := createTransaction(tx, entry.ID, p.Type, p.Amount, p.Description, entry.ApplicationId, entry.GuildID, p.UserId)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
+
+// signed returns the amount as it changes the balance, negative for debits
+func signed(transactionType TransactionType, amount int64) int64 {
+	if transactionType == DEBIT {
+		return -amount
+	}
+	return amount
+}
+
+// ledgerBalanceSQL sums the transactions into the balance they add up to
+const ledgerBalanceSQL = "CAST(COALESCE(SUM(CASE WHEN type = 'DEBIT' THEN -amount ELSE amount END), 0) AS BIGINT)"
+
+// ledgerBalances returns the balance of every wallet in the server as the
+// sum of its transactions
+func ledgerBalances(db *gorm.DB, guildId string) (map[string]int64, error) {
+	var rows []struct {
+		UserID  string
+		Balance int64
+	}
+
+	result := db.Model(&Transaction{}).
+		Select("user_id, "+ledgerBalanceSQL+" AS balance").
+		Where("guild_id = ?", guildId).
+		Group("user_id").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	balances := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		balances[row.UserID] = row.Balance
+	}
+	return balances, nil
+}
+
+// HouseBalance retrieves the balance of the house account in the server from
+// the ledger.
+func HouseBalance(db *gorm.DB, guildId, account string) (int64, error) {
+	var balance int64
+	result := db.Model(&Transaction{}).
+		Select(ledgerBalanceSQL).
+		Where("guild_id = ? AND user_id = ?", guildId, account).
+		Scan(&balance)
+	return balance, result.Error
+}
+
+// Mismatch is a wallet whose balance is not what its transactions add up to.
+type Mismatch struct {
+	UserId  string
+	Balance int64 // The wallet's balance
+	Ledger  int64 // The balance from the ledger
+}
+
+// Reconciliation is the result of checking a server's wallets against the
+// ledger.
+type Reconciliation struct {
+	// Number of user wallets checked
+	Wallets int
+
+	// Wallets whose balance differs from the ledger
+	Mismatches []Mismatch
+
+	// IDs of the ledger entries whose credits and debits don't add up
+	UnbalancedEntries []uint
+
+	// Number of transactions which are not part of a ledger entry
+	Unposted int64
+
+	// Balances of the house accounts
+	House map[string]int64
+}
+
+// Balanced checks if the wallets and the ledger agree.
+func (r Reconciliation) Balanced() bool {
+	return len(r.Mismatches) == 0 && len(r.UnbalancedEntries) == 0 && r.Unposted == 0
+}
+
+// Reconcile recomputes the balance of every wallet in the server from the
+// ledger and reports the wallets whose balance differs, along with any ledger
+// entries which don't balance. Nothing is changed, mismatches are logged to be
+// looked into.
+func Reconcile(db *gorm.DB, guildId string) (Reconciliation, error) {
+	report := Reconciliation{House: map[string]int64{}}
+
+	// Read everything at one point in time, so transactions made while
+	// reconciling aren't counted on one side only. SQLite transactions
+	// already see one point in time.
+	opts := []*sql.TxOptions{}
+	if db.Dialector.Name() == "postgres" {
+		opts = append(opts, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		ledger, err := ledgerBalances(tx, guildId)
+		if err != nil {
+			return err
+		}
+
+		var users []WalletUser
+		if err := tx.Where("guild_id = ?", guildId).Order("user_id").Find(&users).Error; err != nil {
+			return err
+		}
+
+		report.Wallets = len(users)
+		for _, user := range users {
+			if user.Balance != ledger[user.UserId] {
+				report.Mismatches = append(report.Mismatches, Mismatch{user.UserId, user.Balance, ledger[user.UserId]})
+			}
+			delete(ledger, user.UserId)
+		}
+
+		// What's left are house accounts, or users with transactions but
+		// no wallet
+		for userId, balance := range ledger {
+			if IsHouseAccount(userId) {
+				report.House[userId] = balance
+			} else if balance != 0 {
+				report.Mismatches = append(report.Mismatches, Mismatch{userId, 0, balance})
+			}
+		}
+
+		sort.Slice(report.Mismatches, func(i, j int) bool {
+			return report.Mismatches[i].UserId < report.Mismatches[j].UserId
+		})
+
+		err = tx.Model(&Transaction{}).
+			Where("guild_id = ? AND entry_id IS NOT NULL AND entry_id <> 0", guildId).
+			Group("entry_id").
+			Having(ledgerBalanceSQL+" <> 0").
+			Order("entry_id").
+			Pluck("entry_id", &report.UnbalancedEntries).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&Transaction{}).
+			Where("guild_id = ? AND (entry_id IS NULL OR entry_id = 0)", guildId).
+			Count(&report.Unposted).Error
+	}, opts...)
+	if err != nil {
+		return report, err
+	}
+
+	for _, mismatch := range report.Mismatches {
+		lg.WithFields(log.Fields{
+			"guild_id": guildId,
+			"user_id":  mismatch.UserId,
+			"balance":  mismatch.Balance,
+			"ledger":   mismatch.Ledger,
+		}).Warn("Wallet balance does not match the ledger")
+	}
+	for _, entryId := range report.UnbalancedEntries {
+		lg.WithFields(log.Fields{
+			"guild_id": guildId,
+			"entry_id": entryId,
+		}).Warn("Ledger entry does not balance")
+	}
+
+	return report, nil
+}
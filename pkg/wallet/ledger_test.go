@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHouseAccounts(t *testing.T) {
+	db := setupTestDB(t)
+
+	Debit(db, ExampleGuildId, ExampleUserId1, 100, "Blackjack bet", "blackjack")
+	Credit(db, ExampleGuildId, ExampleUserId1, 250, "Blackjack returns", "blackjack")
+	Debit(db, ExampleGuildId, ExampleUserId2, 50, "Snailrace Quickbet", "snailrace")
+	Credit(db, ExampleGuildId, ExampleUserId2, 20, "test credit", "app1")
+	Trasfer(db, ExampleGuildId, ExampleUserId1, ExampleUserId2, 30, "sent", "received", "wallet.pay")
+
+	expected := map[string]int64{
+		HouseBlackjack: -150,
+		HouseSnailrace: 50,
+		HouseMint:      -2*DefaultBalance - 20,
+	}
+	for account, balance := range expected {
+		if actual, err := HouseBalance(db, ExampleGuildId, account); err != nil || actual != balance {
+			t.Errorf("Expected %s to have balance %d, got %d: %v", account, balance, actual, err)
+		}
+	}
+
+	// Every posting has a side in another wallet, so all the balances add
+	// up to nothing
+	report, err := Reconcile(db, ExampleGuildId)
+	if err != nil || !report.Balanced() || report.Wallets != 2 {
+		t.Fatalf("Expected the wallets to match the ledger, got %+v: %v", report, err)
+	}
+
+	total := int64(0)
+	for _, balance := range report.House {
+		total += balance
+	}
+	for _, userId := range []string{ExampleUserId1, ExampleUserId2} {
+		balance, _ := Balance(db, ExampleGuildId, userId)
+		total += balance
+	}
+	if total != 0 {
+		t.Errorf("Expected the balances to add up to 0, got %d", total)
+	}
+
+	// House accounts can't be used as a user's wallet
+	if err := Credit(db, ExampleGuildId, HouseMint, 100, "test credit", "app1"); !errors.Is(err, ErrHouseAccount) {
+		t.Errorf("Expected ErrHouseAccount, got %v", err)
+	}
+}
+
+func TestPostUnbalanced(t *testing.T) {
+	db := setupTestDB(t)
+
+	_, err := post(db, LedgerEntry{GuildID: ExampleGuildId, ApplicationId: "app1"},
+		posting{CREDIT, 100, "test credit", ExampleUserId1},
+		posting{DEBIT, 90, "test debit", HouseMint},
+	)
+	if !errors.Is(err, ErrUnbalancedEntry) {
+		t.Errorf("Expected ErrUnbalancedEntry, got %v", err)
+	}
+
+	_, err = post(db, LedgerEntry{GuildID: ExampleGuildId, ApplicationId: "app1"}, posting{CREDIT, 0, "nothing", ExampleUserId1})
+	if !errors.Is(err, ErrUnbalancedEntry) {
+		t.Errorf("Expected ErrUnbalancedEntry for a single posting, got %v", err)
+	}
+}
+
+func TestReconcileMismatches(t *testing.T) {
+	db := setupTestDB(t)
+
+	Credit(db, ExampleGuildId, ExampleUserId1, 100, "test credit", "app1")
+	Credit(db, ExampleGuildId, ExampleUserId2, 100, "test credit", "app1")
+
+	// A balance changed outside the ledger
+	db.Model(&WalletUser{}).Where(WalletUser{GuildId: ExampleGuildId, UserId: ExampleUserId1}).Update("balance", 1000)
+
+	// A posting added to an entry without its other side
+	var entry LedgerEntry
+	db.Last(&entry)
+	db.Create(&Transaction{Type: CREDIT, Amount: 5, GuildID: ExampleGuildId, UserID: ExampleUserId2, EntryID: entry.ID})
+
+	// A transaction which isn't part of an entry
+	db.Create(&Transaction{Type: DEBIT, Amount: 5, GuildID: ExampleGuildId, UserID: ExampleUserId2})
+
+	report, err := Reconcile(db, ExampleGuildId)
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	if report.Balanced() {
+		t.Errorf("Expected the wallets not to match the ledger")
+	}
+
+	expected := Mismatch{UserId: ExampleUserId1, Balance: 1000, Ledger: DefaultBalance + 100}
+	if len(report.Mismatches) != 1 || report.Mismatches[0] != expected {
+		t.Errorf("Expected mismatch %+v, got %+v", expected, report.Mismatches)
+	}
+
+	if len(report.UnbalancedEntries) != 1 || report.UnbalancedEntries[0] != entry.ID {
+		t.Errorf("Expected entry %d to be unbalanced, got %v", entry.ID, report.UnbalancedEntries)
+	}
+
+	if report.Unposted != 1 {
+		t.Errorf("Expected 1 transaction outside an entry, got %d", report.Unposted)
+	}
+}
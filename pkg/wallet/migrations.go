@@ -0,0 +1,254 @@
+package wallet
+
+import (
+	"time"
+
+	"github.com/aussiebroadwan/tony/database/migrate"
+	"gorm.io/gorm"
+)
+
+// walletUserV1 and transactionV1 are the wallet tables as they were first
+// created by migrations, later changes are made by the migrations after
+type walletUserV1 struct {
+	GuildId string `gorm:"primarykey"`
+	UserId  string `gorm:"primarykey"`
+	Balance int64
+}
+
+func (walletUserV1) TableName() string { return "wallet_users" }
+
+type transactionV1 struct {
+	gorm.Model
+
+	Type          string `gorm:"type:string;not null"`
+	Amount        int64
+	Description   string
+	ApplicationId string
+	GuildID       string
+	UserID        string
+}
+
+func (transactionV1) TableName() string { return "transactions" }
+
+// transactionV4 and ledgerEntryV4 are the tables once transactions became
+// postings of ledger entries
+type transactionV4 struct {
+	gorm.Model
+
+	Type          string `gorm:"type:string;not null"`
+	Amount        int64
+	Description   string
+	ApplicationId string
+	GuildID       string
+	UserID        string
+	EntryID       uint `gorm:"index"`
+}
+
+func (transactionV4) TableName() string { return "transactions" }
+
+type ledgerEntryV4 struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	GuildID       string
+	ApplicationId string
+}
+
+func (ledgerEntryV4) TableName() string { return "ledger_entries" }
+
+// ledgerEntryV5 is the ledger entries once they could be made idempotent and
+// reversed
+type ledgerEntryV5 struct {
+	ID             uint `gorm:"primarykey"`
+	CreatedAt      time.Time
+	GuildID        string `gorm:"uniqueIndex:idx_ledger_entries_key,priority:1"`
+	ApplicationId  string
+	IdempotencyKey *string `gorm:"uniqueIndex:idx_ledger_entries_key,priority:2"`
+	ReversesID     *uint   `gorm:"uniqueIndex"`
+}
+
+func (ledgerEntryV5) TableName() string { return "ledger_entries" }
+
+// postLedgerV4 makes the existing transactions into ledger entries. Each is
+// balanced against the house account of its application, and a wallet whose
+// balance isn't what its transactions add up to, such as the balance it
+// started with, gets an opening balance from the mint for the difference.
+func postLedgerV4(tx *gorm.DB) error {
+	house := func(applicationId string) string {
+		switch applicationId {
+		case "blackjack", "snailrace":
+			return "house:" + applicationId
+		}
+		return "house:mint"
+	}
+	opposite := map[string]string{"CREDIT": "DEBIT", "DEBIT": "CREDIT"}
+
+	var transactions []transactionV4
+	if err := tx.Where("entry_id IS NULL OR entry_id = 0").Order("id").Find(&transactions).Error; err != nil {
+		return err
+	}
+
+	for _, t := range transactions {
+		entry := ledgerEntryV4{CreatedAt: t.CreatedAt, GuildID: t.GuildID, ApplicationId: t.ApplicationId}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&t).Update("entry_id", entry.ID).Error; err != nil {
+			return err
+		}
+
+		counterpart := transactionV4{
+			Model:         gorm.Model{CreatedAt: t.CreatedAt, UpdatedAt: t.CreatedAt},
+			Type:          opposite[t.Type],
+			Amount:        t.Amount,
+			Description:   t.Description,
+			ApplicationId: t.ApplicationId,
+			GuildID:       t.GuildID,
+			UserID:        house(t.ApplicationId),
+			EntryID:       entry.ID,
+		}
+		if err := tx.Create(&counterpart).Error; err != nil {
+			return err
+		}
+	}
+
+	var users []struct {
+		GuildId string
+		UserId  string
+		Balance int64
+		Ledger  int64
+	}
+	err := tx.Table("wallet_users").
+		Select("wallet_users.guild_id, wallet_users.user_id, wallet_users.balance, " +
+			"CAST(COALESCE(SUM(CASE WHEN transactions.type = 'DEBIT' THEN -transactions.amount ELSE transactions.amount END), 0) AS BIGINT) AS ledger").
+		Joins("LEFT JOIN transactions ON transactions.guild_id = wallet_users.guild_id AND transactions.user_id = wallet_users.user_id AND transactions.deleted_at IS NULL").
+		Group("wallet_users.guild_id, wallet_users.user_id, wallet_users.balance").
+		Scan(&users).Error
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		difference := user.Balance - user.Ledger
+		if difference == 0 {
+			continue
+		}
+
+		entry := ledgerEntryV4{GuildID: user.GuildId, ApplicationId: "wallet"}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+
+		userType, mintType := "CREDIT", "DEBIT"
+		if difference < 0 {
+			userType, mintType, difference = "DEBIT", "CREDIT", -difference
+		}
+
+		postings := []transactionV4{
+			{Type: userType, Amount: difference, Description: "Opening balance", ApplicationId: "wallet", GuildID: user.GuildId, UserID: user.UserId, EntryID: entry.ID},
+			{Type: mintType, Amount: difference, Description: "Opening balance", ApplicationId: "wallet", GuildID: user.GuildId, UserID: "house:mint", EntryID: entry.ID},
+		}
+		if err := tx.Create(&postings).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Migrations are the changes to the wallet tables, in order
+var Migrations = []migrate.Migration{
+	{
+		Package: "wallet",
+		Version: 1,
+		Name:    "create wallet tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&walletUserV1{}, &transactionV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&walletUserV1{}, &transactionV1{})
+		},
+	},
+	{
+		// Wallets from before balances were kept per server were keyed by
+		// the user alone, AutoMigrate added the server ID without changing
+		// the primary key. SQLite tables are always created with both. The
+		// wallets and their transactions are given the legacy server.
+		Package: "wallet",
+		Version: 2,
+		Name:    "key wallets by server and user",
+		Up: func(tx *gorm.DB) error {
+			if err := migrate.BackfillGuild(tx, "wallet_users", "transactions"); err != nil {
+				return err
+			}
+			if tx.Dialector.Name() != "postgres" {
+				return nil
+			}
+			return tx.Exec("ALTER TABLE wallet_users DROP CONSTRAINT IF EXISTS wallet_users_pkey, ADD PRIMARY KEY (guild_id, user_id)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			// The wallets stay keyed by server and user, a user with a
+			// wallet in more than one server can't be keyed by the user
+			// alone without losing one of them
+			return nil
+		},
+	},
+	{
+		Package: "wallet",
+		Version: 3,
+		Name:    "index transactions by owner",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("CREATE INDEX IF NOT EXISTS idx_transactions_owner ON transactions (guild_id, user_id)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP INDEX IF EXISTS idx_transactions_owner").Error
+		},
+	},
+	{
+		Package: "wallet",
+		Version: 4,
+		Name:    "post transactions to a double-entry ledger",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&ledgerEntryV4{}, &transactionV4{}); err != nil {
+				return err
+			}
+			return postLedgerV4(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			// The opening balances are kept, they are what the balances
+			// started with
+			if err := tx.Unscoped().Where("user_id LIKE ?", "house:%").Delete(&transactionV4{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&transactionV4{}, "EntryID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropColumn(&transactionV4{}, "EntryID"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&ledgerEntryV4{})
+		},
+	},
+	{
+		Package: "wallet",
+		Version: 5,
+		Name:    "add idempotency keys and reversals to ledger entries",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&ledgerEntryV5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, index := range []string{"idx_ledger_entries_key", "idx_ledger_entries_reverses_id"} {
+				if err := m.DropIndex(&ledgerEntryV5{}, index); err != nil {
+					return err
+				}
+			}
+			for _, column := range []string{"IdempotencyKey", "ReversesID"} {
+				if err := m.DropColumn(&ledgerEntryV5{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
@@ -0,0 +1,118 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aussiebroadwan/tony/database/dbtest"
+	"github.com/aussiebroadwan/tony/database/migrate"
+)
+
+func TestMigrations(t *testing.T) {
+	db := dbtest.Open(t, Migrations)
+
+	migrator, err := migrate.New(db, Migrations)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if err := migrator.Check(); err != nil {
+		t.Fatalf("expected the migrations to be applied: %v", err)
+	}
+
+	// The migrated tables work with the models
+	if err := Credit(db, ExampleGuildId, ExampleUserId1, 100, "Test credit", "test"); err != nil {
+		t.Fatalf("failed to credit user: %v", err)
+	}
+	if !db.Migrator().HasIndex(&Transaction{}, "idx_transactions_owner") {
+		t.Errorf("expected the transactions to be indexed by owner")
+	}
+
+	if _, err := migrator.Down(len(Migrations)); err != nil {
+		t.Fatalf("failed to migrate down: %v", err)
+	}
+	if db.Migrator().HasTable(&WalletUser{}) || db.Migrator().HasTable(&Transaction{}) {
+		t.Errorf("expected the wallet tables to be dropped")
+	}
+}
+
+func TestMigrateLedger(t *testing.T) {
+	// Wallets and transactions from before the ledger
+	db := dbtest.Open(t, Migrations[:3])
+
+	db.Create(&walletUserV1{GuildId: ExampleGuildId, UserId: ExampleUserId1, Balance: DefaultBalance - 100 + 300})
+	db.Create(&walletUserV1{GuildId: ExampleGuildId, UserId: ExampleUserId2, Balance: DefaultBalance})
+	db.Create(&[]transactionV1{
+		{Type: "DEBIT", Amount: 100, Description: "Blackjack bet", ApplicationId: "blackjack", GuildID: ExampleGuildId, UserID: ExampleUserId1},
+		{Type: "CREDIT", Amount: 300, Description: "Blackjack returns", ApplicationId: "blackjack", GuildID: ExampleGuildId, UserID: ExampleUserId1},
+	})
+
+	migrator, err := migrate.New(db, Migrations)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	report, err := Reconcile(db, ExampleGuildId)
+	if err != nil || !report.Balanced() {
+		t.Fatalf("expected the migrated wallets to match the ledger, got %+v: %v", report, err)
+	}
+	if report.House[HouseBlackjack] != -200 || report.House[HouseMint] != -2*DefaultBalance {
+		t.Errorf("expected the house accounts to be balanced against, got %v", report.House)
+	}
+
+	// Back to before the ledger
+	if _, err := migrator.Down(len(Migrations) - 3); err != nil {
+		t.Fatalf("failed to migrate down: %v", err)
+	}
+	if db.Migrator().HasTable(&LedgerEntry{}) || db.Migrator().HasColumn(&Transaction{}, "EntryID") {
+		t.Errorf("expected the ledger to be removed")
+	}
+
+	var count int64
+	db.Model(&transactionV1{}).Where("user_id LIKE ?", "house:%").Count(&count)
+	if count != 0 {
+		t.Errorf("expected the house transactions to be removed, got %d", count)
+	}
+}
+
+func TestMigrateLegacyWallets(t *testing.T) {
+	// A wallet and transactions from before balances were kept per server
+	db := dbtest.Open(t, Migrations[:1])
+
+	db.Create(&walletUserV1{GuildId: "", UserId: ExampleUserId1, Balance: DefaultBalance + 300})
+	db.Create(&transactionV1{Type: "CREDIT", Amount: 300, Description: "Blackjack returns", ApplicationId: "blackjack", UserID: ExampleUserId1})
+	db.Exec("UPDATE transactions SET guild_id = NULL")
+
+	migrator, err := migrate.New(db, Migrations)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); !errors.Is(err, migrate.ErrNoLegacyGuild) {
+		t.Fatalf("expected the wallets to need a server, got %v", err)
+	}
+
+	migrator.SetLegacyGuild(ExampleGuildId)
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	if balance, err := Balance(db, ExampleGuildId, ExampleUserId1); err != nil || balance != DefaultBalance+300 {
+		t.Errorf("expected the wallet to be kept in the legacy server, got %d: %v", balance, err)
+	}
+	report, err := Reconcile(db, ExampleGuildId)
+	if err != nil || !report.Balanced() || report.House[HouseBlackjack] != -300 {
+		t.Errorf("expected the legacy transactions to be posted in the legacy server, got %+v: %v", report, err)
+	}
+
+	// Rolling back keeps the wallets in their server
+	if _, err := migrator.Down(len(Migrations) - 1); err != nil {
+		t.Fatalf("failed to migrate down: %v", err)
+	}
+	var wallets []walletUserV1
+	db.Find(&wallets)
+	if len(wallets) != 1 || wallets[0].GuildId != ExampleGuildId || wallets[0].Balance != DefaultBalance+300 {
+		t.Errorf("expected the wallet to be kept, got %+v", wallets)
+	}
+}
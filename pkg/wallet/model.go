@@ -1,8 +1,13 @@
 package wallet
 
-import "gorm.io/gorm"
+import (
+	"time"
 
-// DefaultBalance is the default balance for a new user.
+	"gorm.io/gorm"
+)
+
+// DefaultBalance is the default balance for a new user, unless it has been
+// changed with SetDefaultBalance.
 const DefaultBalance = 500
 
 type TransactionType string
@@ -12,11 +17,15 @@ const (
 	DEBIT  TransactionType = "DEBIT"
 )
 
+// WalletUser is a user's wallet in a server, each server has its own
+// balances.
 type WalletUser struct {
+	GuildId string `gorm:"primarykey"` // Discord Server ID
 	UserId  string `gorm:"primarykey"` // Discord User ID
 	Balance int64
 }
 
+// Transaction is a posting to a wallet, one side of a ledger entry.
 type Transaction struct {
 	gorm.Model
 
@@ -28,7 +37,28 @@ type Transaction struct {
 	Description   string
 	ApplicationId string
 
-	// Owner of the wallet that the transaction is related to
-	UserID string
-	User   WalletUser `gorm:"references:UserId"`
+	// Owner of the wallet that the transaction is related to, either a user
+	// or a house account
+	GuildID string
+	UserID  string
+
+	// Ledger entry the transaction is a posting of
+	EntryID uint `gorm:"index"`
+}
+
+// LedgerEntry is one movement of money, made up of the transactions posted
+// to each wallet involved. The credits and debits of an entry always add up
+// to the same amount.
+type LedgerEntry struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	GuildID       string `gorm:"uniqueIndex:idx_ledger_entries_key,priority:1"`
+	ApplicationId string
+
+	// Key the entry was made with, an operation retried with the same key
+	// in the server is only made once
+	IdempotencyKey *string `gorm:"uniqueIndex:idx_ledger_entries_key,priority:2"`
+
+	// Entry this entry reverses, each entry can only be reversed once
+	ReversesID *uint `gorm:"uniqueIndex"`
 }
@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrAlreadyReversed     = errors.New("transaction has already been reversed")
+)
+
+// Reverse undoes the ledger entry of the transaction in the server, such as
+// to refund a mistake. A new entry linked to the original is posted with the
+// opposite of each of its transactions, whose descriptions give the reason,
+// so the original is kept for the record. Reversing takes the money back out
+// of wallets which were credited, failing with ErrInsufficientBalance if they
+// no longer have it. Each entry can only be reversed once.
+func Reverse(db *gorm.DB, guildId string, transactionId uint, reason string) error {
+	var transactions []Transaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var original Transaction
+		result := tx.Where("guild_id = ? AND id = ?", guildId, transactionId).Limit(1).Find(&original)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || original.EntryID == 0 {
+			return ErrTransactionNotFound
+		}
+
+		var postings []Transaction
+		if err := tx.Where("entry_id = ?", original.EntryID).Order("id").Find(&postings).Error; err != nil {
+			return err
+		}
+
+		// Lock the users' wallets in order, like a transfer does
+		userIds := []string{}
+		for _, p := range postings {
+			if !IsHouseAccount(p.UserID) {
+				userIds = append(userIds, p.UserID)
+			}
+		}
+		sort.Strings(userIds)
+
+		users := map[string]*WalletUser{}
+		for _, userId := range userIds {
+			if _, ok := users[userId]; ok {
+				continue
+			}
+			user, err := lockUser(tx, guildId, userId)
+			if err != nil {
+				return err
+			}
+			users[userId] = &user
+		}
+
+		var reversals int64
+		if err := tx.Model(&LedgerEntry{}).Where("reverses_id = ?", original.EntryID).Count(&reversals).Error; err != nil {
+			return err
+		}
+		if reversals > 0 {
+			return ErrAlreadyReversed
+		}
+
+		reversed := make([]posting, 0, len(postings))
+		for _, p := range postings {
+			opposite := CREDIT
+			if p.Type == CREDIT {
+				opposite = DEBIT
+			}
+			reversed = append(reversed, posting{opposite, p.Amount, fmt.Sprintf("Reversal of #%d: %s", p.ID, reason), p.UserID})
+
+			if user, ok := users[p.UserID]; ok {
+				user.Balance += signed(opposite, p.Amount)
+			}
+		}
+
+		for _, userId := range userIds {
+			user := users[userId]
+			if user.Balance < 0 {
+				return ErrInsufficientBalance
+			}
+			if err := tx.Save(user).Error; err != nil {
+				return err
+			}
+		}
+
+		entry := LedgerEntry{GuildID: guildId, ApplicationId: "wallet.reverse", ReversesID: &original.EntryID}
+		var err error
+		transactions, err = post(tx, entry, reversed...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, transaction := range transactions {
+		if !IsHouseAccount(transaction.UserID) {
+			publish(TransactionCreated{transaction})
+		}
+	}
+	return nil
+}
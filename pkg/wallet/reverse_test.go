@@ -0,0 +1,105 @@
+package wallet
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReverse(t *testing.T) {
+	db := setupTestDB(t)
+
+	// A bet taken twice by mistake
+	Debit(db, ExampleGuildId, ExampleUserId1, 100, "Blackjack bet", "blackjack")
+	Debit(db, ExampleGuildId, ExampleUserId1, 100, "Blackjack bet", "blackjack")
+
+	history, _ := History(db, ExampleGuildId, ExampleUserId1, 1)
+	mistake := history[0]
+
+	var events []TransactionCreated
+	SetPublisher(func(event any) { events = append(events, event.(TransactionCreated)) })
+	defer SetPublisher(func(event any) {})
+
+	if err := Reverse(db, ExampleGuildId, mistake.ID, "bet taken twice"); err != nil {
+		t.Fatalf("Reverse failed: %v", err)
+	}
+
+	if balance, _ := Balance(db, ExampleGuildId, ExampleUserId1); balance != DefaultBalance-100 {
+		t.Errorf("Expected balance %d, got %d", DefaultBalance-100, balance)
+	}
+	if balance, _ := HouseBalance(db, ExampleGuildId, HouseBlackjack); balance != 100 {
+		t.Errorf("Expected the house to have %d, got %d", 100, balance)
+	}
+
+	// The reversal is a new entry linked to the original
+	history, _ = History(db, ExampleGuildId, ExampleUserId1, 1)
+	refund := history[0]
+	if refund.Type != CREDIT || refund.Amount != 100 || !strings.Contains(refund.Description, "bet taken twice") {
+		t.Errorf("Expected the bet to be refunded, got %+v", refund)
+	}
+
+	var entry LedgerEntry
+	db.First(&entry, refund.EntryID)
+	if entry.ReversesID == nil || *entry.ReversesID != mistake.EntryID {
+		t.Errorf("Expected the reversal to be linked to entry %d, got %+v", mistake.EntryID, entry)
+	}
+
+	if len(events) != 1 || events[0].ID != refund.ID {
+		t.Errorf("Expected the refund to be published, got %+v", events)
+	}
+
+	if report, err := Reconcile(db, ExampleGuildId); err != nil || !report.Balanced() {
+		t.Errorf("Expected the wallets to match the ledger, got %+v: %v", report, err)
+	}
+
+	// Reversing either side of the entry again fails
+	var house Transaction
+	db.Where("entry_id = ? AND user_id = ?", mistake.EntryID, HouseBlackjack).First(&house)
+	for _, id := range []uint{mistake.ID, house.ID} {
+		if err := Reverse(db, ExampleGuildId, id, "again"); !errors.Is(err, ErrAlreadyReversed) {
+			t.Errorf("Expected ErrAlreadyReversed, got %v", err)
+		}
+	}
+}
+
+func TestReverseTransfer(t *testing.T) {
+	db := setupTestDB(t)
+
+	Trasfer(db, ExampleGuildId, ExampleUserId1, ExampleUserId2, 200, "sent", "received", "wallet.pay")
+	history, _ := History(db, ExampleGuildId, ExampleUserId2, 1)
+	payment := history[0]
+
+	// The money can't be taken back once it has been spent
+	Debit(db, ExampleGuildId, ExampleUserId2, DefaultBalance+1, "spent", "app1")
+	if err := Reverse(db, ExampleGuildId, payment.ID, "paid the wrong user"); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance, got %v", err)
+	}
+
+	Credit(db, ExampleGuildId, ExampleUserId2, DefaultBalance+1, "earned", "app1")
+	if err := Reverse(db, ExampleGuildId, payment.ID, "paid the wrong user"); err != nil {
+		t.Fatalf("Reverse failed: %v", err)
+	}
+
+	for _, userId := range []string{ExampleUserId1, ExampleUserId2} {
+		if balance, _ := Balance(db, ExampleGuildId, userId); balance != DefaultBalance {
+			t.Errorf("Expected %s to have balance %d, got %d", userId, DefaultBalance, balance)
+		}
+	}
+}
+
+func TestReverseNotFound(t *testing.T) {
+	db := setupTestDB(t)
+
+	Credit(db, ExampleGuildId, ExampleUserId1, 100, "test credit", "app1")
+	history, _ := History(db, ExampleGuildId, ExampleUserId1, 1)
+
+	// Transactions can only be reversed in their own server
+	const OtherGuildId = "1060681976622891000"
+	if err := Reverse(db, OtherGuildId, history[0].ID, "wrong server"); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("Expected ErrTransactionNotFound, got %v", err)
+	}
+
+	if err := Reverse(db, ExampleGuildId, 10000, "missing"); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("Expected ErrTransactionNotFound, got %v", err)
+	}
+}
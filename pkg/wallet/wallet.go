@@ -2,81 +2,190 @@ package wallet
 
 import (
 	"errors"
-	"sync"
+	"sync/atomic"
 
 	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-var mu sync.Mutex = sync.Mutex{}
 var lg *log.Entry = log.New().WithField("src", "wallet")
 
+// newUserBalance is the balance new users start with, see SetDefaultBalance
+var newUserBalance atomic.Int64
+
+func init() {
+	newUserBalance.Store(DefaultBalance)
+}
+
+// SetDefaultBalance changes the balance new users start with, the balances
+// of existing users are not changed.
+func SetDefaultBalance(balance int64) {
+	newUserBalance.Store(balance)
+}
+
 var (
 	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrSameWallet          = errors.New("cannot transfer to the same wallet")
+	ErrHouseAccount        = errors.New("house accounts are not user wallets")
 )
 
-// SetupWalletDB initializes the database with the User and Transaction models. It
-// automatically migrates the database schema to match the models, ensuring the
-// tables are created or updated as needed.
-func SetupWalletDB(db *gorm.DB, logger *log.Entry) {
-	mu = sync.Mutex{}
-	lg = logger
+// Option changes how a credit, debit or transfer is made.
+type Option func(*options)
+
+type options struct {
+	key string
+}
+
+// IdempotencyKey makes the operation only happen once in the server for the
+// key, such as "blackjack:<roundId>:<userId>". Repeating it with the same key,
+// like a retried interaction or a game callback firing twice, does nothing and
+// returns nil.
+func IdempotencyKey(key string) Option {
+	return func(o *options) {
+		o.key = key
+	}
+}
 
-	if err := db.AutoMigrate(&Transaction{}, &WalletUser{}); err != nil {
-		lg.WithError(err).Fatal("Failed to auto-migrate wallet tables")
+// errAlreadyApplied rolls back an operation whose idempotency key has
+// already been used
+var errAlreadyApplied = errors.New("operation already applied")
+
+// newEntry returns the ledger entry for an operation with the options, and
+// checks its idempotency key hasn't been used. It must be called once the
+// wallets are locked, so a repeat made at the same time waits to see the
+// first.
+func newEntry(tx *gorm.DB, guildId, applicationId string, opts []Option) (LedgerEntry, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
 	}
+
+	entry := LedgerEntry{GuildID: guildId, ApplicationId: applicationId}
+	if o.key == "" {
+		return entry, nil
+	}
+
+	applied, err := Applied(tx, guildId, o.key)
+	if err != nil {
+		return entry, err
+	}
+	if applied {
+		lg.WithFields(log.Fields{
+			"guild_id":        guildId,
+			"application_id":  applicationId,
+			"idempotency_key": o.key,
+		}).Info("Skipping operation which has already been made")
+		return entry, errAlreadyApplied
+	}
+
+	entry.IdempotencyKey = &o.key
+	return entry, nil
+}
+
+// Applied checks if an operation has been made in the server with the
+// idempotency key.
+func Applied(db *gorm.DB, guildId, key string) (bool, error) {
+	var count int64
+	err := db.Model(&LedgerEntry{}).Where("guild_id = ? AND idempotency_key = ?", guildId, key).Count(&count).Error
+	return count > 0, err
+}
+
+// SetupWalletDB sets up the wallet to use the logger. The tables are created
+// and updated by Migrations.
+func SetupWalletDB(db *gorm.DB, logger *log.Entry) {
+	lg = logger
 }
 
-// getUser retrieves the user with the given ID. If the user does not exist, it
+// getUser retrieves the user with the given ID in the server. If the user does not exist, it
 // creates a new user with the default balance and returns a user with the
 // default balance.
-func getUser(db *gorm.DB, userId string) (WalletUser, error) {
+func getUser(db *gorm.DB, guildId, userId string) (WalletUser, error) {
+	var user WalletUser
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := createUser(tx, guildId, userId); err != nil {
+			return err
+		}
+
+		return tx.Where(WalletUser{GuildId: guildId, UserId: userId}).First(&user).Error
+	})
+	return user, err
+}
+
+// lockUser retrieves the user like getUser and locks their row until the
+// database transaction tx ends, so no other process can change their balance
+// in the meantime. SQLite has no row locks, the whole database is already
+// locked as transactions begin immediately (_txlock=immediate), and other
+// connections wait for it up to the busy timeout.
+func lockUser(tx *gorm.DB, guildId, userId string) (WalletUser, error) {
 	var user WalletUser
-	result := db.Where(WalletUser{UserId: userId}).Attrs(WalletUser{Balance: DefaultBalance}).FirstOrCreate(&user)
-	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return user, result.Error
+	if err := createUser(tx, guildId, userId); err != nil {
+		return user, err
+	}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(WalletUser{GuildId: guildId, UserId: userId}).First(&user).Error
+	return user, err
+}
+
+// createUser creates the user with the default balance if they don't exist,
+// which is posted to the ledger from the mint. Another process may create the
+// user at the same time, whichever insert loses is ignored.
+func createUser(tx *gorm.DB, guildId, userId string) error {
+	if IsHouseAccount(userId) {
+		return ErrHouseAccount
 	}
 
-	return user, nil
+	user := WalletUser{GuildId: guildId, UserId: userId, Balance: newUserBalance.Load()}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&user)
+	if result.Error != nil || result.RowsAffected == 0 || user.Balance == 0 {
+		return result.Error
+	}
+
+	_, err := post(tx, LedgerEntry{GuildID: guildId, ApplicationId: "wallet"},
+		posting{CREDIT, user.Balance, "Opening balance", userId},
+		posting{DEBIT, user.Balance, "Opening balance", HouseMint},
+	)
+	return err
 }
 
-// createTransaction creates a new transaction with the given type, amount,
-// description, and application ID. It logs and returns any error encountered
-// during the operation.
-func createTransaction(db *gorm.DB, transactionType TransactionType, amount int64, description, applicationId string, userId string) error {
+// createTransaction creates a new transaction in the ledger entry with the
+// given type, amount, description, and application ID. It logs and returns
+// the transaction and any error encountered during the operation.
+func createTransaction(db *gorm.DB, entryId uint, transactionType TransactionType, amount int64, description, applicationId string, guildId, userId string) (Transaction, error) {
 	transaction := Transaction{
 		Type:          transactionType,
 		Amount:        amount,
 		Description:   description,
 		ApplicationId: applicationId,
+		GuildID:       guildId,
 		UserID:        userId,
+		EntryID:       entryId,
 	}
 
 	result := db.Create(&transaction)
 	if result.Error != nil {
-		return result.Error
+		return transaction, result.Error
 	}
 
 	lg.WithFields(log.Fields{
 		"transaction_id": transaction.ID,
+		"entry_id":       transaction.EntryID,
 		"type":           transaction.Type,
 		"amount":         transaction.Amount,
 		"description":    transaction.Description,
 		"application_id": transaction.ApplicationId,
+		"guild_id":       transaction.GuildID,
 		"user_id":        transaction.UserID,
 	}).Info("Transaction created")
 
-	return nil
+	return transaction, nil
 }
 
-// Balance retrieves the balance of a user with the given ID. If the user is
+// Balance retrieves the balance of a user with the given ID in the server. If the user is
 // not found, initialise a new user with the default balance and return the
 // default balance.
-func Balance(db *gorm.DB, userId string) (int64, error) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	user, err := getUser(db, userId)
+func Balance(db *gorm.DB, guildId, userId string) (int64, error) {
+	user, err := getUser(db, guildId, userId)
 	if err != nil {
 		return 0, err
 	}
@@ -85,97 +194,162 @@ func Balance(db *gorm.DB, userId string) (int64, error) {
 }
 
 // Credit adds the specified amount to the balance of the user with the given
-// ID. It logs and returns any error encountered during the operation. If the
-// user does not exist, it creates a new user with the default balance and
-// credits the specified amount.
-func Credit(db *gorm.DB, userId string, amount int64, description, applicationId string) error {
-	mu.Lock()
-	defer mu.Unlock()
-
-	user, err := getUser(db, userId)
-	if err != nil {
+// ID in the server, from the application's house account. It logs and returns
+// any error encountered during the operation. If the user does not exist, it
+// creates a new user with the default balance and credits the specified
+// amount.
+func Credit(db *gorm.DB, guildId, userId string, amount int64, description, applicationId string, opts ...Option) error {
+	var transactions []Transaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, guildId, userId)
+		if err != nil {
+			return err
+		}
+
+		entry, err := newEntry(tx, guildId, applicationId, opts)
+		if err != nil {
+			return err
+		}
+
+		user.Balance += amount
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+
+		transactions, err = post(tx, entry,
+			posting{CREDIT, amount, description, user.UserId},
+			posting{DEBIT, amount, description, HouseAccount(applicationId)},
+		)
 		return err
+	})
+	if errors.Is(err, errAlreadyApplied) {
+		return nil
 	}
-
-	user.Balance += amount
-	if err := db.Save(&user).Error; err != nil {
+	if err != nil {
 		return err
 	}
 
-	return createTransaction(db, CREDIT, amount, description, applicationId, user.UserId)
+	publish(TransactionCreated{transactions[0]})
+	return nil
 }
 
 // Debit subtracts the specified amount from the balance of the user with the
-// given ID. It logs and returns any error encountered during the operation. If
-// the user does not exist, it creates a new user with the default balance and
-// debits the specified amount.
-func Debit(db *gorm.DB, userId string, amount int64, description, applicationId string) error {
-	mu.Lock()
-	defer mu.Unlock()
-
-	user, err := getUser(db, userId)
-	if err != nil {
-		return err
-	}
-
-	if user.Balance < amount {
-		return ErrInsufficientBalance
-	}
+// given ID in the server, to the application's house account. It logs and
+// returns any error encountered during the operation. If the user does not
+// exist, it creates a new user with the default balance and debits the
+// specified amount.
+func Debit(db *gorm.DB, guildId, userId string, amount int64, description, applicationId string, opts ...Option) error {
+	var transactions []Transaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, guildId, userId)
+		if err != nil {
+			return err
+		}
 
-	user.Balance -= amount
-	if err := db.Save(&user).Error; err != nil {
-		return err
-	}
+		entry, err := newEntry(tx, guildId, applicationId, opts)
+		if err != nil {
+			return err
+		}
 
-	return createTransaction(db, DEBIT, amount, description, applicationId, user.UserId)
-}
+		if user.Balance < amount {
+			return ErrInsufficientBalance
+		}
 
-func Trasfer(db *gorm.DB, fromUserId, toUserId string, amount int64, fromDescription, toDescription, applicationId string) error {
-	mu.Lock()
-	defer mu.Unlock()
+		user.Balance -= amount
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
 
-	fromUser, err := getUser(db, fromUserId)
-	if err != nil {
+		transactions, err = post(tx, entry,
+			posting{DEBIT, amount, description, user.UserId},
+			posting{CREDIT, amount, description, HouseAccount(applicationId)},
+		)
 		return err
+	})
+	if errors.Is(err, errAlreadyApplied) {
+		return nil
 	}
-
-	toUser, err := getUser(db, toUserId)
 	if err != nil {
 		return err
 	}
 
-	if fromUser.Balance < amount {
-		return ErrInsufficientBalance
+	publish(TransactionCreated{transactions[0]})
+	return nil
+}
+
+// Trasfer moves the specified amount from one user's wallet to another's in
+// the server, recording a debit and a credit. Both balances are changed in
+// one database transaction, so either both are saved or neither is.
+func Trasfer(db *gorm.DB, guildId, fromUserId, toUserId string, amount int64, fromDescription, toDescription, applicationId string, opts ...Option) error {
+	if fromUserId == toUserId {
+		return ErrSameWallet
 	}
 
-	fromUser.Balance -= amount
-	toUser.Balance += amount
+	var transactions []Transaction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the wallets in the same order whichever way the money goes,
+		// so two opposite transfers can't each wait on the other's lock
+		users := map[string]*WalletUser{}
+		for _, userId := range sortedIds(fromUserId, toUserId) {
+			user, err := lockUser(tx, guildId, userId)
+			if err != nil {
+				return err
+			}
+			users[userId] = &user
+		}
+		fromUser, toUser := users[fromUserId], users[toUserId]
 
-	// Perform the wallet transaction in a single database transaction
-	return db.Transaction(func(tx *gorm.DB) error {
-		if err := db.Save(&fromUser).Error; err != nil {
+		entry, err := newEntry(tx, guildId, applicationId, opts)
+		if err != nil {
 			return err
 		}
 
-		if err := db.Save(&toUser).Error; err != nil {
+		if fromUser.Balance < amount {
+			return ErrInsufficientBalance
+		}
+
+		fromUser.Balance -= amount
+		toUser.Balance += amount
+
+		if err := tx.Save(fromUser).Error; err != nil {
 			return err
 		}
 
-		if err := createTransaction(db, DEBIT, amount, fromDescription, applicationId, fromUser.UserId); err != nil {
+		if err := tx.Save(toUser).Error; err != nil {
 			return err
 		}
 
-		return createTransaction(db, CREDIT, amount, toDescription, applicationId, toUser.UserId)
+		transactions, err = post(tx, entry,
+			posting{DEBIT, amount, fromDescription, fromUser.UserId},
+			posting{CREDIT, amount, toDescription, toUser.UserId},
+		)
+		return err
 	})
+	if errors.Is(err, errAlreadyApplied) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only publish once both sides have been saved
+	publish(TransactionCreated{transactions[0]})
+	publish(TransactionCreated{transactions[1]})
+	return nil
 }
 
-// History retrieves the transaction history of the user with the given ID. It
-// returns the last 'limit' number of transactions. If 'limit' is negative, it
-// returns all transactions.
-func History(db *gorm.DB, userId string, limit int) ([]Transaction, error) {
-	mu.Lock()
-	defer mu.Unlock()
+// sortedIds returns the two IDs in order
+func sortedIds(a, b string) []string {
+	if b < a {
+		return []string{b, a}
+	}
+	return []string{a, b}
+}
 
+// History retrieves the transaction history of the user with the given ID in
+// the server. It returns the last 'limit' number of transactions. If 'limit'
+// is negative, it returns all transactions.
+func History(db *gorm.DB, guildId, userId string, limit int) ([]Transaction, error) {
 	// Default limit to 10 if not provided
 	if limit == 0 {
 		limit = 10
@@ -187,7 +361,7 @@ func History(db *gorm.DB, userId string, limit int) ([]Transaction, error) {
 	}
 
 	var transactions []Transaction
-	result := db.Where(Transaction{UserID: userId}).Order("created_at desc").Limit(limit).Find(&transactions)
+	result := db.Where(Transaction{GuildID: guildId, UserID: userId}).Order("created_at desc").Limit(limit).Find(&transactions)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -196,12 +370,9 @@ func History(db *gorm.DB, userId string, limit int) ([]Transaction, error) {
 }
 
 // CreditHistory retrieves the credit transaction history of the user with the
-// given ID. It returns the last 'limit' number of credit transactions. If
+// given ID in the server. It returns the last 'limit' number of credit transactions. If
 // 'limit' is negative, it returns all credit transactions.
-func CreditHistory(db *gorm.DB, userId string, limit int) ([]Transaction, error) {
-	mu.Lock()
-	defer mu.Unlock()
-
+func CreditHistory(db *gorm.DB, guildId, userId string, limit int) ([]Transaction, error) {
 	// Default limit to 10 if not provided
 	if limit == 0 {
 		limit = 10
@@ -213,7 +384,7 @@ func CreditHistory(db *gorm.DB, userId string, limit int) ([]Transaction, error)
 	}
 
 	var transactions []Transaction
-	result := db.Where(Transaction{Type: CREDIT, UserID: userId}).Order("created_at desc").Limit(limit).Find(&transactions)
+	result := db.Where(Transaction{Type: CREDIT, GuildID: guildId, UserID: userId}).Order("created_at desc").Limit(limit).Find(&transactions)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -222,12 +393,9 @@ func CreditHistory(db *gorm.DB, userId string, limit int) ([]Transaction, error)
 }
 
 // DebitHistory retrieves the debit transaction history of the user with the
-// given ID. It returns the last 'limit' number of debit transactions. If
+// given ID in the server. It returns the last 'limit' number of debit transactions. If
 // 'limit' is negative, it returns all debit transactions.
-func DebitHistory(db *gorm.DB, userId string, limit int) ([]Transaction, error) {
-	mu.Lock()
-	defer mu.Unlock()
-
+func DebitHistory(db *gorm.DB, guildId, userId string, limit int) ([]Transaction, error) {
 	// Default limit to 10 if not provided
 	if limit == 0 {
 		limit = 10
@@ -239,7 +407,7 @@ func DebitHistory(db *gorm.DB, userId string, limit int) ([]Transaction, error)
 	}
 
 	var transactions []Transaction
-	result := db.Where(Transaction{Type: DEBIT, UserID: userId}).Order("created_at desc").Limit(limit).Find(&transactions)
+	result := db.Where(Transaction{Type: DEBIT, GuildID: guildId, UserID: userId}).Order("created_at desc").Limit(limit).Find(&transactions)
 	if result.Error != nil {
 		return nil, result.Error
 	}
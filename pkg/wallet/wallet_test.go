@@ -1,42 +1,37 @@
 package wallet
 
 import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
 	"testing"
 
-	"github.com/glebarez/sqlite"
+	"github.com/aussiebroadwan/tony/database/dbtest"
 	"gorm.io/gorm"
 )
 
 const ExampleUserId1 = "1060681976622891089"
 const ExampleUserId2 = "169015299834642432"
+const ExampleGuildId = "1229977032540573766"
 
 func setupTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("test.db"), &gorm.Config{})
-	if err != nil {
-		t.Fatalf("failed to connect to database: %v", err)
-	}
-
-	if err := db.AutoMigrate(&WalletUser{}, &Transaction{}); err != nil {
-		t.Fatalf("failed to migrate database: %v", err)
-	}
-
-	return db
+	return dbtest.Open(t, Migrations)
 }
 
 func TestGetUser(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&WalletUser{}, &Transaction{})
 
 	// Test case: Retrieve an existing user
-	db.Create(&WalletUser{UserId: ExampleUserId1, Balance: DefaultBalance})
+	db.Create(&WalletUser{GuildId: ExampleGuildId, UserId: ExampleUserId1, Balance: DefaultBalance})
 
-	user, err := getUser(db, ExampleUserId1)
+	user, err := getUser(db, ExampleGuildId, ExampleUserId1)
 	if err != nil || user.UserId != ExampleUserId1 || user.Balance != DefaultBalance {
 		t.Errorf("getUser failed to retrieve existing user: %v", err)
 	}
 
 	// Test case: Create a new user if not exists
-	newUser, err := getUser(db, ExampleUserId2)
+	newUser, err := getUser(db, ExampleGuildId, ExampleUserId2)
 	if err != nil || newUser.UserId != ExampleUserId2 || newUser.Balance != DefaultBalance {
 		t.Errorf("getUser failed to create new user: %v", err)
 	}
@@ -44,19 +39,18 @@ func TestGetUser(t *testing.T) {
 
 func TestCredit(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&WalletUser{}, &Transaction{})
 
-	user := WalletUser{UserId: ExampleUserId1, Balance: DefaultBalance}
+	user := WalletUser{GuildId: ExampleGuildId, UserId: ExampleUserId1, Balance: DefaultBalance}
 	db.Create(&user)
 
 	// Test adding credit
-	err := Credit(db, ExampleUserId1, 100, "test credit", "app1")
+	err := Credit(db, ExampleGuildId, ExampleUserId1, 100, "test credit", "app1")
 	if err != nil {
 		t.Errorf("Credit failed: %v", err)
 	}
 
 	// Check balance update
-	balance, err := Balance(db, ExampleUserId1)
+	balance, err := Balance(db, ExampleGuildId, ExampleUserId1)
 	if err != nil {
 		t.Errorf("Balance failed: %v", err)
 	}
@@ -78,16 +72,15 @@ func TestCredit(t *testing.T) {
 
 func TestCreditNoUser(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&WalletUser{}, &Transaction{})
 
 	// Test case: Create a new user if not exists
-	err := Credit(db, ExampleUserId2, 100, "test credit", "app1")
+	err := Credit(db, ExampleGuildId, ExampleUserId2, 100, "test credit", "app1")
 	if err != nil {
 		t.Errorf("Credit failed: %v", err)
 	}
 
 	// Check balance update
-	balance, err := Balance(db, ExampleUserId2)
+	balance, err := Balance(db, ExampleGuildId, ExampleUserId2)
 	if err != nil {
 		t.Errorf("Balance failed: %v", err)
 	}
@@ -99,19 +92,18 @@ func TestCreditNoUser(t *testing.T) {
 
 func TestDebit(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&WalletUser{}, &Transaction{})
 
-	user := WalletUser{UserId: ExampleUserId1, Balance: DefaultBalance}
+	user := WalletUser{GuildId: ExampleGuildId, UserId: ExampleUserId1, Balance: DefaultBalance}
 	db.Create(&user)
 
 	// Test adding credit
-	err := Debit(db, ExampleUserId1, 100, "test debit", "app1")
+	err := Debit(db, ExampleGuildId, ExampleUserId1, 100, "test debit", "app1")
 	if err != nil {
 		t.Errorf("Debit failed: %v", err)
 	}
 
 	// Check balance update
-	balance, err := Balance(db, ExampleUserId1)
+	balance, err := Balance(db, ExampleGuildId, ExampleUserId1)
 	if err != nil {
 		t.Errorf("Balance failed: %v", err)
 	}
@@ -133,16 +125,15 @@ func TestDebit(t *testing.T) {
 
 func TestDebitNoUser(t *testing.T) {
 	db := setupTestDB(t)
-	defer db.Migrator().DropTable(&WalletUser{}, &Transaction{})
 
 	// Test case: Create a new user if not exists
-	err := Debit(db, ExampleUserId2, 100, "test debit", "app1")
+	err := Debit(db, ExampleGuildId, ExampleUserId2, 100, "test debit", "app1")
 	if err != nil {
 		t.Errorf("Debit failed: %v", err)
 	}
 
 	// Check balance update
-	balance, err := Balance(db, ExampleUserId2)
+	balance, err := Balance(db, ExampleGuildId, ExampleUserId2)
 	if err != nil {
 		t.Errorf("Balance failed: %v", err)
 	}
@@ -156,17 +147,17 @@ func setupTestDBWithTransactions(t *testing.T) *gorm.DB {
 	db := setupTestDB(t) // Assuming setupTestDB is the same function provided in the previous response.
 
 	// Creating test user
-	user := WalletUser{UserId: "user123", Balance: 1000}
+	user := WalletUser{GuildId: ExampleGuildId, UserId: "user123", Balance: 1000}
 	if err := db.Create(&user).Error; err != nil {
 		t.Fatalf("failed to create test user: %v", err)
 	}
 
 	// Creating test transactions
 	transactions := []Transaction{
-		{Type: CREDIT, Amount: 300, UserID: user.UserId},
-		{Type: CREDIT, Amount: 200, UserID: user.UserId},
-		{Type: DEBIT, Amount: 150, UserID: user.UserId},
-		{Type: DEBIT, Amount: 50, UserID: user.UserId},
+		{Type: CREDIT, Amount: 300, GuildID: ExampleGuildId, UserID: user.UserId},
+		{Type: CREDIT, Amount: 200, GuildID: ExampleGuildId, UserID: user.UserId},
+		{Type: DEBIT, Amount: 150, GuildID: ExampleGuildId, UserID: user.UserId},
+		{Type: DEBIT, Amount: 50, GuildID: ExampleGuildId, UserID: user.UserId},
 	}
 	for _, tx := range transactions {
 		if err := db.Create(&tx).Error; err != nil {
@@ -179,16 +170,15 @@ func setupTestDBWithTransactions(t *testing.T) *gorm.DB {
 
 func TestHistory(t *testing.T) {
 	db := setupTestDBWithTransactions(t)
-	defer db.Migrator().DropTable(&WalletUser{}, &Transaction{})
 
 	// Test fetching limited transactions
-	transactions, err := History(db, "user123", 2)
+	transactions, err := History(db, ExampleGuildId, "user123", 2)
 	if err != nil || len(transactions) != 2 {
 		t.Errorf("Expected 2 transactions, got %d, error: %v", len(transactions), err)
 	}
 
 	// Test fetching all transactions with negative limit
-	transactions, err = History(db, "user123", -1)
+	transactions, err = History(db, ExampleGuildId, "user123", -1)
 	if err != nil || len(transactions) != 4 {
 		t.Errorf("Expected 4 transactions, got %d, error: %v", len(transactions), err)
 	}
@@ -196,10 +186,9 @@ func TestHistory(t *testing.T) {
 
 func TestCreditHistory(t *testing.T) {
 	db := setupTestDBWithTransactions(t)
-	defer db.Migrator().DropTable(&WalletUser{}, &Transaction{})
 
 	// Test fetching credit transactions
-	transactions, err := CreditHistory(db, "user123", -1)
+	transactions, err := CreditHistory(db, ExampleGuildId, "user123", -1)
 	if err != nil || len(transactions) != 2 {
 		t.Errorf("Expected 2 credit transactions, got %d, error: %v", len(transactions), err)
 	}
@@ -207,11 +196,271 @@ func TestCreditHistory(t *testing.T) {
 
 func TestDebitHistory(t *testing.T) {
 	db := setupTestDBWithTransactions(t)
-	defer db.Migrator().DropTable(&WalletUser{}, &Transaction{})
 
 	// Test fetching debit transactions
-	transactions, err := DebitHistory(db, "user123", -1)
+	transactions, err := DebitHistory(db, ExampleGuildId, "user123", -1)
 	if err != nil || len(transactions) != 2 {
 		t.Errorf("Expected 2 debit transactions, got %d, error: %v", len(transactions), err)
 	}
 }
+
+func TestWalletsSeparatedByGuild(t *testing.T) {
+	db := setupTestDB(t)
+
+	const OtherGuildId = "1060681976622891000"
+
+	if err := Credit(db, ExampleGuildId, ExampleUserId1, 100, "test credit", "app1"); err != nil {
+		t.Fatalf("Credit failed: %v", err)
+	}
+
+	if balance, _ := Balance(db, ExampleGuildId, ExampleUserId1); balance != DefaultBalance+100 {
+		t.Errorf("Expected balance %d, got %d", DefaultBalance+100, balance)
+	}
+
+	// The same user in another server has their own wallet
+	if balance, _ := Balance(db, OtherGuildId, ExampleUserId1); balance != DefaultBalance {
+		t.Errorf("Expected balance %d in other server, got %d", DefaultBalance, balance)
+	}
+
+	// Only the opening balance of the new wallet is in the other server
+	if transactions, _ := History(db, OtherGuildId, ExampleUserId1, -1); len(transactions) != 1 || transactions[0].Description != "Opening balance" {
+		t.Errorf("Expected only the opening balance in other server, got %+v", transactions)
+	}
+}
+
+func TestTransactionEvents(t *testing.T) {
+	db := setupTestDB(t)
+
+	var events []TransactionCreated
+	SetPublisher(func(event any) { events = append(events, event.(TransactionCreated)) })
+	defer SetPublisher(func(event any) {})
+
+	Credit(db, ExampleGuildId, ExampleUserId1, 100, "test credit", "app1")
+	Trasfer(db, ExampleGuildId, ExampleUserId1, ExampleUserId2, 50, "sent", "received", "app1")
+
+	// Failed transactions are not published
+	Debit(db, ExampleGuildId, ExampleUserId2, 10000, "too much", "app1")
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+
+	expected := []struct {
+		Type   TransactionType
+		UserID string
+	}{{CREDIT, ExampleUserId1}, {DEBIT, ExampleUserId1}, {CREDIT, ExampleUserId2}}
+	for i, event := range events {
+		if event.Type != expected[i].Type || event.UserID != expected[i].UserID || event.GuildID != ExampleGuildId || event.ID == 0 {
+			t.Errorf("Unexpected event %d: %+v", i, event.Transaction)
+		}
+	}
+}
+
+func TestTransferToSelf(t *testing.T) {
+	db := setupTestDB(t)
+
+	if err := Trasfer(db, ExampleGuildId, ExampleUserId1, ExampleUserId1, 100, "sent", "received", "app1"); !errors.Is(err, ErrSameWallet) {
+		t.Errorf("Expected ErrSameWallet, got %v", err)
+	}
+
+	if balance, _ := Balance(db, ExampleGuildId, ExampleUserId1); balance != DefaultBalance {
+		t.Errorf("Expected balance %d, got %d", DefaultBalance, balance)
+	}
+}
+
+func TestConcurrentDebits(t *testing.T) {
+	db := setupTestDB(t)
+
+	// Two instances of Tony sharing the database
+	instances := []*gorm.DB{db, dbtest.Connect(t, db)}
+
+	// Twice as many debits as the balance covers, exactly half should succeed
+	const amount = 10
+	debits := 2 * DefaultBalance / amount
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < debits; i++ {
+		wg.Add(1)
+		go func(db *gorm.DB) {
+			defer wg.Done()
+
+			err := Debit(db, ExampleGuildId, ExampleUserId1, amount, "test debit", "app1")
+			if err != nil && !errors.Is(err, ErrInsufficientBalance) {
+				t.Errorf("Debit failed: %v", err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				succeeded++
+			}
+		}(instances[i%len(instances)])
+	}
+	wg.Wait()
+
+	if succeeded != debits/2 {
+		t.Errorf("Expected %d debits to succeed, got %d", debits/2, succeeded)
+	}
+
+	if balance, _ := Balance(db, ExampleGuildId, ExampleUserId1); balance != 0 {
+		t.Errorf("Expected balance 0, got %d", balance)
+	}
+}
+
+func TestConcurrentTransfers(t *testing.T) {
+	db := setupTestDB(t)
+
+	const users = 5
+	const workers = 10
+	const transfers = 40
+
+	instances := []*gorm.DB{db, dbtest.Connect(t, db)}
+
+	// Workers on both instances move money between the same few users in
+	// both directions, along with debits and credits which cancel out,
+	// creating the wallets as they go
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(db *gorm.DB, seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+
+			for i := 0; i < transfers; i++ {
+				from := fmt.Sprintf("user%d", rng.Intn(users))
+				to := fmt.Sprintf("user%d", rng.Intn(users))
+				amount := int64(rng.Intn(200) + 1)
+
+				var err error
+				switch {
+				case from == to:
+					if err = Debit(db, ExampleGuildId, from, amount, "test debit", "app1"); err == nil {
+						err = Credit(db, ExampleGuildId, from, amount, "test credit", "app1")
+					}
+				default:
+					err = Trasfer(db, ExampleGuildId, from, to, amount, "sent", "received", "app1")
+				}
+
+				if err != nil && !errors.Is(err, ErrInsufficientBalance) {
+					t.Errorf("Transfer failed: %v", err)
+				}
+			}
+		}(instances[w%len(instances)], int64(w))
+	}
+	wg.Wait()
+
+	// No money is created or lost, and each balance matches its transactions
+	var total int64
+	for u := 0; u < users; u++ {
+		userId := fmt.Sprintf("user%d", u)
+
+		balance, err := Balance(db, ExampleGuildId, userId)
+		if err != nil {
+			t.Fatalf("Balance failed: %v", err)
+		}
+		if balance < 0 {
+			t.Errorf("Expected %s to have a positive balance, got %d", userId, balance)
+		}
+		total += balance
+
+		transactions, _ := History(db, ExampleGuildId, userId, -1)
+		expected := int64(0)
+		for _, transaction := range transactions {
+			if transaction.Type == CREDIT {
+				expected += transaction.Amount
+			} else {
+				expected -= transaction.Amount
+			}
+		}
+		if balance != expected {
+			t.Errorf("Expected %s to have balance %d from their transactions, got %d", userId, expected, balance)
+		}
+	}
+
+	if total != users*DefaultBalance {
+		t.Errorf("Expected a total balance of %d, got %d", users*DefaultBalance, total)
+	}
+
+	if report, err := Reconcile(db, ExampleGuildId); err != nil || !report.Balanced() {
+		t.Errorf("Expected the wallets to match the ledger, got %+v: %v", report, err)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	db := setupTestDB(t)
+
+	var events []TransactionCreated
+	SetPublisher(func(event any) { events = append(events, event.(TransactionCreated)) })
+	defer SetPublisher(func(event any) {})
+
+	// Each is repeated, as a game callback firing twice would
+	for i := 0; i < 2; i++ {
+		if err := Credit(db, ExampleGuildId, ExampleUserId1, 100, "Blackjack returns", "blackjack", IdempotencyKey("blackjack:1:"+ExampleUserId1)); err != nil {
+			t.Errorf("Credit failed: %v", err)
+		}
+		if err := Debit(db, ExampleGuildId, ExampleUserId2, 50, "Blackjack bet", "blackjack", IdempotencyKey("blackjack:bet:interaction")); err != nil {
+			t.Errorf("Debit failed: %v", err)
+		}
+		if err := Trasfer(db, ExampleGuildId, ExampleUserId1, ExampleUserId2, 30, "sent", "received", "wallet.pay", IdempotencyKey("wallet.pay:interaction")); err != nil {
+			t.Errorf("Trasfer failed: %v", err)
+		}
+	}
+
+	if balance, _ := Balance(db, ExampleGuildId, ExampleUserId1); balance != DefaultBalance+100-30 {
+		t.Errorf("Expected balance %d, got %d", DefaultBalance+100-30, balance)
+	}
+	if balance, _ := Balance(db, ExampleGuildId, ExampleUserId2); balance != DefaultBalance-50+30 {
+		t.Errorf("Expected balance %d, got %d", DefaultBalance-50+30, balance)
+	}
+	if len(events) != 4 {
+		t.Errorf("Expected 4 events, got %d", len(events))
+	}
+
+	// Keys are only used once in each server
+	const OtherGuildId = "1060681976622891000"
+	Credit(db, OtherGuildId, ExampleUserId1, 100, "Blackjack returns", "blackjack", IdempotencyKey("blackjack:1:"+ExampleUserId1))
+	if balance, _ := Balance(db, OtherGuildId, ExampleUserId1); balance != DefaultBalance+100 {
+		t.Errorf("Expected balance %d in other server, got %d", DefaultBalance+100, balance)
+	}
+
+	// A failed operation can be retried with the same key
+	key := IdempotencyKey("snailrace:bet:interaction")
+	if err := Debit(db, ExampleGuildId, ExampleUserId2, 10000, "Snailrace Quickbet", "snailrace", key); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("Expected ErrInsufficientBalance, got %v", err)
+	}
+	if applied, err := Applied(db, ExampleGuildId, "snailrace:bet:interaction"); err != nil || applied {
+		t.Errorf("Expected the failed operation not to be applied, got %v: %v", applied, err)
+	}
+	if err := Debit(db, ExampleGuildId, ExampleUserId2, 10, "Snailrace Quickbet", "snailrace", key); err != nil {
+		t.Errorf("Debit failed: %v", err)
+	}
+	if applied, err := Applied(db, ExampleGuildId, "snailrace:bet:interaction"); err != nil || !applied {
+		t.Errorf("Expected the operation to be applied, got %v: %v", applied, err)
+	}
+}
+
+func TestConcurrentIdempotencyKey(t *testing.T) {
+	db := setupTestDB(t)
+
+	instances := []*gorm.DB{db, dbtest.Connect(t, db)}
+
+	// The same payout from both instances at once is only made once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(db *gorm.DB) {
+			defer wg.Done()
+			if err := Credit(db, ExampleGuildId, ExampleUserId1, 100, "Snailrace returns", "snailrace", IdempotencyKey("snailrace:race:"+ExampleUserId1)); err != nil {
+				t.Errorf("Credit failed: %v", err)
+			}
+		}(instances[i%len(instances)])
+	}
+	wg.Wait()
+
+	if balance, _ := Balance(db, ExampleGuildId, ExampleUserId1); balance != DefaultBalance+100 {
+		t.Errorf("Expected balance %d, got %d", DefaultBalance+100, balance)
+	}
+}